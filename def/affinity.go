@@ -0,0 +1,71 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package def
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// AffinityRules are workload-level rules on the tasks that can be co-located on the same host.
+// Tasks are referred to by their name or by one of their labels.
+type AffinityRules struct {
+	// Pairs of tasks whose instances are never co-located.
+	AntiAffinity [][]string `json:"antiAffinity"`
+	// Pairs of tasks, the instances of the first of which are placed next to an instance of the second,
+	// provided an instance of the second has been launched.
+	Affinity [][]string `json:"affinity"`
+}
+
+// AffinityRulesFromJSON reads the affinity rules from the given config file.
+func AffinityRulesFromJSON(uri string) (AffinityRules, error) {
+	var rules AffinityRules
+	file, err := os.Open(uri)
+	if err != nil {
+		return rules, errors.Wrap(err, "Error opening file")
+	}
+	defer file.Close()
+	if err := json.NewDecoder(file).Decode(&rules); err != nil {
+		return rules, errors.Wrap(err, "Error unmarshalling")
+	}
+	for _, pairs := range [][][]string{rules.AntiAffinity, rules.Affinity} {
+		for _, pair := range pairs {
+			if len(pair) != 2 || pair[0] == "" || pair[1] == "" {
+				return rules, errors.New(fmt.Sprintf("affinity rule %v should be a pair of tasks", pair))
+			}
+		}
+	}
+	return rules, nil
+}
+
+// Matches returns whether the given name or label refers to the task.
+func (tsk Task) Matches(ref string) bool {
+	if tsk.Name == ref {
+		return true
+	}
+	for _, label := range tsk.Labels {
+		if label == ref {
+			return true
+		}
+	}
+	return false
+}
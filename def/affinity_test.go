@@ -0,0 +1,50 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package def
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAffinityRulesFromJSON(t *testing.T) {
+	rules, err := AffinityRulesFromJSON("../affinityRules_sample.json")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"memory-bound", "memory-bound"}}, rules.AntiAffinity)
+	assert.Equal(t, [][]string{{"dgemm", "minife"}}, rules.Affinity)
+
+	config, err := ioutil.TempFile("", "affinityRules")
+	assert.NoError(t, err)
+	defer os.Remove(config.Name())
+	_, err = config.WriteString(`{"antiAffinity": [["memory-bound"]]}`)
+	assert.NoError(t, err)
+	assert.NoError(t, config.Close())
+	_, err = AffinityRulesFromJSON(config.Name())
+	assert.Error(t, err, "rule that is not a pair accepted")
+}
+
+func TestTask_Matches(t *testing.T) {
+	task := Task{Name: "minife", Labels: []string{"memory-bound"}}
+	assert.True(t, task.Matches("minife"))
+	assert.True(t, task.Matches("memory-bound"))
+	assert.False(t, task.Matches("dgemm"))
+}
@@ -0,0 +1,178 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package def
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/pkg/errors"
+)
+
+// Operators of the constraints on the placement of task instances.
+const (
+	// Value of the attribute is equal to the given value.
+	ConstraintEqual = "=="
+	// Value of the attribute is not equal to the given value.
+	ConstraintNotEqual = "!="
+	// Value of the attribute is one of the given values.
+	ConstraintIn = "in"
+	// Value of the attribute matches the given regular expression.
+	ConstraintMatch = "=~"
+	// At most the given number of instances of the task are placed on the hosts with the same value of the attribute.
+	ConstraintMax = "max"
+)
+
+// Attribute whose value is the hostname of a host.
+const HostAttribute = "host"
+
+var (
+	comparisonConstraint = regexp.MustCompile(`^\s*([^\s=!~]+)\s*(==|!=|=~)\s*(.*?)\s*$`)
+	keywordConstraint    = regexp.MustCompile(`^\s*(\S+)\s+(in|max)\s+(.*?)\s*$`)
+)
+
+// Constraint on the hosts on which the instances of a task can be placed, evaluated against the
+// attributes of the hosts (for example, "class == A", "host in [stratos-001, stratos-002]",
+// "rack != r1", "host =~ ^stratos-00[1-4]$" or "host max 1").
+type Constraint struct {
+	Attribute string
+	Operator  string
+	// Values that the attribute is compared with, if any.
+	Values []string
+	// Regular expression that the attribute is matched with, if any.
+	Regexp *regexp.Regexp
+	// Maximum number of instances per value of the attribute, if any.
+	Max int
+	// Constraint as written in the task definition.
+	expression string
+}
+
+// ParseConstraint parses a constraint of the form "<attribute> <operator> <value>".
+func ParseConstraint(expression string) (Constraint, error) {
+	c := Constraint{expression: expression}
+	match := comparisonConstraint.FindStringSubmatch(expression)
+	if match == nil {
+		match = keywordConstraint.FindStringSubmatch(expression)
+	}
+	if match == nil {
+		return c, errors.New(fmt.Sprintf("malformed constraint \"%s\"", expression))
+	}
+	c.Attribute, c.Operator = match[1], match[2]
+	value := match[3]
+
+	switch c.Operator {
+	case ConstraintEqual, ConstraintNotEqual:
+		if value == "" {
+			return c, errors.New(fmt.Sprintf("constraint \"%s\" needs a value", expression))
+		}
+		c.Values = []string{value}
+	case ConstraintIn:
+		if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+			return c, errors.New(fmt.Sprintf("constraint \"%s\" needs a list of values, such as [a, b]", expression))
+		}
+		for _, v := range strings.Split(value[1:len(value)-1], ",") {
+			if v = strings.TrimSpace(v); v == "" {
+				return c, errors.New(fmt.Sprintf("constraint \"%s\" has an empty value", expression))
+			}
+			c.Values = append(c.Values, v)
+		}
+	case ConstraintMatch:
+		var err error
+		if c.Regexp, err = regexp.Compile(value); err != nil {
+			return c, errors.Wrap(err, fmt.Sprintf("constraint \"%s\" has an invalid regular expression", expression))
+		}
+	case ConstraintMax:
+		var err error
+		if c.Max, err = strconv.Atoi(value); err != nil || c.Max < 1 {
+			return c, errors.New(fmt.Sprintf("constraint \"%s\" needs a positive number of instances", expression))
+		}
+	}
+	return c, nil
+}
+
+func (c Constraint) String() string {
+	return c.expression
+}
+
+func (c *Constraint) UnmarshalJSON(data []byte) error {
+	var expression string
+	if err := json.Unmarshal(data, &expression); err != nil {
+		return errors.Wrap(err, "constraint needs to be a string")
+	}
+	constraint, err := ParseConstraint(expression)
+	if err != nil {
+		return err
+	}
+	*c = constraint
+	return nil
+}
+
+func (c Constraint) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.expression)
+}
+
+// AttributeValue returns the value of the attribute of the host with the given attributes, and
+// whether the host has the attribute. The value of the host attribute is the hostname.
+func AttributeValue(hostname string, attributes []*mesos.Attribute, name string) (string, bool) {
+	if name == HostAttribute {
+		return hostname, true
+	}
+	for _, attribute := range attributes {
+		if attribute.GetName() != name {
+			continue
+		}
+		switch attribute.GetType() {
+		case mesos.Value_TEXT:
+			return attribute.GetText().GetValue(), true
+		case mesos.Value_SCALAR:
+			return strconv.FormatFloat(attribute.GetScalar().GetValue(), 'f', -1, 64), true
+		}
+	}
+	return "", false
+}
+
+// SatisfiedBy returns whether placing an instance of the task on the host of the offer satisfies
+// the constraint. The number of instances of the task that have already been placed on the hosts
+// with a given value of the attribute is needed to evaluate max constraints.
+// Only the equality, in and regular expression constraints require hosts to have the attribute.
+func (c Constraint) SatisfiedBy(offer *mesos.Offer, instances func(value string) int) bool {
+	value, ok := AttributeValue(offer.GetHostname(), offer.GetAttributes(), c.Attribute)
+	switch c.Operator {
+	case ConstraintEqual:
+		return ok && value == c.Values[0]
+	case ConstraintNotEqual:
+		return !ok || value != c.Values[0]
+	case ConstraintIn:
+		for _, v := range c.Values {
+			if ok && value == v {
+				return true
+			}
+		}
+		return false
+	case ConstraintMatch:
+		return ok && c.Regexp.MatchString(value)
+	case ConstraintMax:
+		return !ok || instances(value) < c.Max
+	}
+	return false
+}
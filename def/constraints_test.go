@@ -0,0 +1,104 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package def
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConstraint(t *testing.T) {
+	c, err := ParseConstraint("class == A")
+	assert.NoError(t, err)
+	assert.Equal(t, "class", c.Attribute)
+	assert.Equal(t, ConstraintEqual, c.Operator)
+	assert.Equal(t, []string{"A"}, c.Values)
+
+	c, err = ParseConstraint("host in [stratos-001, stratos-002]")
+	assert.NoError(t, err)
+	assert.Equal(t, ConstraintIn, c.Operator)
+	assert.Equal(t, []string{"stratos-001", "stratos-002"}, c.Values)
+
+	c, err = ParseConstraint("host max 1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, c.Max)
+
+	for _, expression := range []string{
+		"class", "class == ", "host in stratos-001", "host in [stratos-001, ]",
+		"host =~ [", "host max 0", "host max one",
+	} {
+		_, err := ParseConstraint(expression)
+		assert.Error(t, err, "malformed constraint \"%s\" accepted", expression)
+	}
+}
+
+func TestConstraint_SatisfiedBy(t *testing.T) {
+	offer := &mesos.Offer{
+		Hostname: proto.String("stratos-001"),
+		Attributes: []*mesos.Attribute{{
+			Name: proto.String("class"),
+			Type: mesos.Value_TEXT.Enum(),
+			Text: &mesos.Value_Text{Value: proto.String("A")},
+		}},
+	}
+	instances := map[string]int{"stratos-001": 1}
+	satisfied := func(expression string) bool {
+		c, err := ParseConstraint(expression)
+		assert.NoError(t, err)
+		return c.SatisfiedBy(offer, func(value string) int { return instances[value] })
+	}
+
+	assert.True(t, satisfied("class == A"))
+	assert.False(t, satisfied("class != A"))
+	assert.True(t, satisfied("host in [stratos-002, stratos-001]"))
+	assert.True(t, satisfied("host =~ ^stratos-00[1-4]$"))
+	assert.False(t, satisfied("host =~ ^stratos-01"))
+	assert.False(t, satisfied("host max 1"))
+	assert.True(t, satisfied("host max 2"))
+	// Hosts without the attribute.
+	assert.False(t, satisfied("rack == r1"))
+	assert.True(t, satisfied("rack != r1"))
+	assert.True(t, satisfied("rack max 1"))
+}
+
+func TestConstraint_JSON(t *testing.T) {
+	task := Task{Name: "minife", Constraints: []Constraint{}}
+	assert.NoError(t, json.Unmarshal([]byte(`["class == A", "host max 1"]`), &task.Constraints))
+	assert.Len(t, task.Constraints, 2)
+	data, err := json.Marshal(task.Constraints)
+	assert.NoError(t, err)
+	assert.Equal(t, `["class == A","host max 1"]`, string(data))
+
+	// Malformed constraints are rejected when loading the workload.
+	workload, err := ioutil.TempFile("", "workload")
+	assert.NoError(t, err)
+	defer os.Remove(workload.Name())
+	_, err = workload.WriteString(`[{"name": "minife", "cpu": 3.0, "ram": 4096, "image": "rdelvalle/minife:electron1",
+		"inst": 1, "constraints": ["class = A"]}]`)
+	assert.NoError(t, err)
+	assert.NoError(t, workload.Close())
+	_, err = TasksFromJSON(workload.Name())
+	assert.Error(t, err)
+}
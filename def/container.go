@@ -0,0 +1,213 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package def
+
+import (
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+)
+
+// Containerizers that can run the instances of a task.
+const (
+	dockerContainerizer = "docker"
+	// Mesos unified containerizer.
+	mesosContainerizer = "mesos"
+)
+
+// Networking modes of Docker containers.
+var dockerNetworks = map[string]mesos.ContainerInfo_DockerInfo_Network{
+	"bridge": mesos.ContainerInfo_DockerInfo_BRIDGE,
+	"host":   mesos.ContainerInfo_DockerInfo_HOST,
+	"none":   mesos.ContainerInfo_DockerInfo_NONE,
+}
+
+// Modes in which volumes can be mounted.
+var volumeModes = map[string]mesos.Volume_Mode{
+	"RW": mesos.Volume_RW,
+	"RO": mesos.Volume_RO,
+}
+
+// Container spec of a task.
+// If not provided, then the image of the task is run in a Docker container with bridge networking.
+type Container struct {
+	// Containerizer that runs the instances of the task: docker (default) or mesos (unified containerizer).
+	// The unified containerizer runs the image of the task, if any, or runs the command on the host otherwise.
+	Type string `json:"type"`
+	// Networking mode of Docker containers: bridge (default), host or none.
+	// Containers run by the unified containerizer share the network of the host.
+	Network string `json:"network"`
+	// Whether Docker pulls the image even if it is already present on the host.
+	ForcePullImage bool `json:"forcePullImage"`
+	// Parameters passed to docker run (for example, cpuset-cpus).
+	Parameters []Parameter `json:"parameters"`
+	// Labels of the container, which are also set on the instances of the task.
+	Labels map[string]string `json:"labels"`
+	// Volumes mounted into the container.
+	Volumes []Volume `json:"volumes"`
+	// Environment variables of the command.
+	Environment map[string]string `json:"env"`
+	// Files fetched into the sandbox of an instance before its command is run.
+	URIs []URI `json:"uris"`
+}
+
+// Parameter passed to docker run, as --<key>=<value>.
+type Parameter struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Volume mounted into a container.
+type Volume struct {
+	ContainerPath string `json:"containerPath"`
+	// Path on the host that is mounted. If not provided, then a directory in the sandbox is mounted.
+	HostPath string `json:"hostPath"`
+	// RW (default) or RO.
+	Mode string `json:"mode"`
+}
+
+// URI of a file fetched into the sandbox of a task instance.
+type URI struct {
+	Value      string `json:"value"`
+	Executable bool   `json:"executable"`
+	// Whether archives are extracted. Defaults to true.
+	Extract *bool `json:"extract"`
+	// Whether the fetcher cache is used.
+	Cache bool `json:"cache"`
+}
+
+// Containerizer that runs the instances of the task.
+func (t Task) containerizer() string {
+	if (t.Container == nil) || (t.Container.Type == "") {
+		return dockerContainerizer
+	}
+	return t.Container.Type
+}
+
+// CommandInfo returns the command run by the instances of the task.
+func (t Task) CommandInfo() *mesos.CommandInfo {
+	command := &mesos.CommandInfo{Value: proto.String(t.CMD)}
+	if t.Container == nil {
+		return command
+	}
+	if len(t.Container.Environment) > 0 {
+		command.Environment = &mesos.Environment{}
+		for _, name := range sortedKeys(t.Container.Environment) {
+			command.Environment.Variables = append(command.Environment.Variables, &mesos.Environment_Variable{
+				Name:  proto.String(name),
+				Value: proto.String(t.Container.Environment[name]),
+			})
+		}
+	}
+	for _, uri := range t.Container.URIs {
+		command.Uris = append(command.Uris, &mesos.CommandInfo_URI{
+			Value:      proto.String(uri.Value),
+			Executable: proto.Bool(uri.Executable),
+			Extract:    uri.Extract,
+			Cache:      proto.Bool(uri.Cache),
+		})
+	}
+	return command
+}
+
+// ContainerInfo returns the container in which the instances of the task are run.
+func (t Task) ContainerInfo() *mesos.ContainerInfo {
+	spec := t.Container
+	if spec == nil {
+		spec = &Container{}
+	}
+
+	container := &mesos.ContainerInfo{}
+	for _, volume := range spec.Volumes {
+		mode := mesos.Volume_RW
+		if volume.Mode != "" {
+			mode = volumeModes[volume.Mode]
+		}
+		mesosVolume := &mesos.Volume{
+			ContainerPath: proto.String(volume.ContainerPath),
+			Mode:          mode.Enum(),
+		}
+		if volume.HostPath != "" {
+			mesosVolume.HostPath = proto.String(volume.HostPath)
+		}
+		container.Volumes = append(container.Volumes, mesosVolume)
+	}
+
+	if t.containerizer() == mesosContainerizer {
+		container.Type = mesos.ContainerInfo_MESOS.Enum()
+		container.Mesos = &mesos.ContainerInfo_MesosInfo{}
+		if t.Image != "" {
+			container.Mesos.Image = &mesos.Image{
+				Type:   mesos.Image_DOCKER.Enum(),
+				Docker: &mesos.Image_Docker{Name: proto.String(t.Image)},
+			}
+		}
+		return container
+	}
+
+	network := mesos.ContainerInfo_DockerInfo_BRIDGE // Run everything isolated, by default.
+	if spec.Network != "" {
+		network = dockerNetworks[spec.Network]
+	}
+	container.Type = mesos.ContainerInfo_DOCKER.Enum()
+	container.Docker = &mesos.ContainerInfo_DockerInfo{
+		Image:          proto.String(t.Image),
+		Network:        network.Enum(),
+		ForcePullImage: proto.Bool(spec.ForcePullImage),
+	}
+	for _, parameter := range spec.Parameters {
+		container.Docker.Parameters = append(container.Docker.Parameters, &mesos.Parameter{
+			Key:   proto.String(parameter.Key),
+			Value: proto.String(parameter.Value),
+		})
+	}
+	// Docker does not see the labels of the task instances.
+	for _, key := range sortedKeys(spec.Labels) {
+		container.Docker.Parameters = append(container.Docker.Parameters, &mesos.Parameter{
+			Key:   proto.String("label"),
+			Value: proto.String(key + "=" + spec.Labels[key]),
+		})
+	}
+	return container
+}
+
+// ContainerLabels returns the labels of the container of the task, if any.
+func (t Task) ContainerLabels() *mesos.Labels {
+	if (t.Container == nil) || (len(t.Container.Labels) == 0) {
+		return nil
+	}
+	labels := &mesos.Labels{}
+	for _, key := range sortedKeys(t.Container.Labels) {
+		labels.Labels = append(labels.Labels, &mesos.Label{
+			Key:   proto.String(key),
+			Value: proto.String(t.Container.Labels[key]),
+		})
+	}
+	return labels
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,107 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package def
+
+import (
+	"testing"
+
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTask_ContainerInfo(t *testing.T) {
+	task := Task{Name: "minife", Image: "rdelvalle/minife:electron1", CMD: "cd src && mpirun -np 3 miniFE.x"}
+
+	// Docker container with bridge networking by default.
+	container := task.ContainerInfo()
+	assert.Equal(t, mesos.ContainerInfo_DOCKER, container.GetType())
+	assert.Equal(t, "rdelvalle/minife:electron1", container.GetDocker().GetImage())
+	assert.Equal(t, mesos.ContainerInfo_DockerInfo_BRIDGE, container.GetDocker().GetNetwork())
+	assert.False(t, container.GetDocker().GetForcePullImage())
+	assert.Empty(t, container.GetVolumes())
+	assert.Nil(t, task.ContainerLabels())
+	assert.Equal(t, task.CMD, task.CommandInfo().GetValue())
+
+	task.Container = &Container{
+		Network:        "host",
+		ForcePullImage: true,
+		Parameters:     []Parameter{{Key: "cpuset-cpus", Value: "0-3"}},
+		Labels:         map[string]string{"team": "hpc"},
+		Volumes: []Volume{
+			{ContainerPath: "/data", HostPath: "/mnt/data", Mode: "RO"},
+			{ContainerPath: "/scratch"},
+		},
+	}
+	container = task.ContainerInfo()
+	assert.Equal(t, mesos.ContainerInfo_DockerInfo_HOST, container.GetDocker().GetNetwork())
+	assert.True(t, container.GetDocker().GetForcePullImage())
+	parameters := container.GetDocker().GetParameters()
+	assert.Len(t, parameters, 2)
+	assert.Equal(t, "cpuset-cpus", parameters[0].GetKey())
+	assert.Equal(t, "0-3", parameters[0].GetValue())
+	// Labels are passed on to Docker as well.
+	assert.Equal(t, "label", parameters[1].GetKey())
+	assert.Equal(t, "team=hpc", parameters[1].GetValue())
+	assert.Len(t, container.GetVolumes(), 2)
+	assert.Equal(t, "/mnt/data", container.GetVolumes()[0].GetHostPath())
+	assert.Equal(t, mesos.Volume_RO, container.GetVolumes()[0].GetMode())
+	assert.Nil(t, container.GetVolumes()[1].HostPath)
+	assert.Equal(t, mesos.Volume_RW, container.GetVolumes()[1].GetMode())
+	labels := task.ContainerLabels().GetLabels()
+	assert.Len(t, labels, 1)
+	assert.Equal(t, "team", labels[0].GetKey())
+	assert.Equal(t, "hpc", labels[0].GetValue())
+
+	// Unified containerizer.
+	task.Container = &Container{Type: "mesos"}
+	container = task.ContainerInfo()
+	assert.Equal(t, mesos.ContainerInfo_MESOS, container.GetType())
+	assert.Nil(t, container.GetDocker())
+	assert.Equal(t, mesos.Image_DOCKER, container.GetMesos().GetImage().GetType())
+	assert.Equal(t, "rdelvalle/minife:electron1", container.GetMesos().GetImage().GetDocker().GetName())
+	// Running the command on the host.
+	task.Image = ""
+	assert.Nil(t, task.ContainerInfo().GetMesos().GetImage())
+}
+
+func TestTask_CommandInfo(t *testing.T) {
+	extract := false
+	task := Task{Name: "minife", CMD: "./run.sh", Container: &Container{
+		Environment: map[string]string{"OMP_NUM_THREADS": "4", "DATA": "/data"},
+		URIs: []URI{
+			{Value: "http://example.com/run.sh", Executable: true},
+			{Value: "http://example.com/data.tar.gz", Extract: &extract, Cache: true},
+		},
+	}}
+
+	command := task.CommandInfo()
+	assert.Equal(t, "./run.sh", command.GetValue())
+	variables := command.GetEnvironment().GetVariables()
+	assert.Len(t, variables, 2)
+	assert.Equal(t, "DATA", variables[0].GetName())
+	assert.Equal(t, "/data", variables[0].GetValue())
+	assert.Equal(t, "OMP_NUM_THREADS", variables[1].GetName())
+	uris := command.GetUris()
+	assert.Len(t, uris, 2)
+	assert.True(t, uris[0].GetExecutable())
+	// Archives are extracted unless specified otherwise.
+	assert.True(t, uris[0].GetExtract())
+	assert.False(t, uris[1].GetExtract())
+	assert.True(t, uris[1].GetCache())
+}
@@ -0,0 +1,97 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package def
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Prefix of the task IDs of the task instances launched by Elektron.
+const taskIDPrefix = "electron-"
+
+// InstanceID identifies a launch of a task instance.
+// Task instances are launched with the task ID electron-<run ID>-<task name>-<instance>-<attempt>.
+type InstanceID struct {
+	// Identifier of the run of the scheduler in which the instance was launched.
+	RunID string
+	// Name of the task.
+	Task string
+	// Index of the instance, starting from 1. Instances of tasks of the same name that are submitted
+	// in the same run are indexed one after the other, so that no two instances share an index.
+	Instance int
+	// Number of times the instance has been relaunched, 0 for its first launch.
+	Attempt int
+}
+
+// NewRunID returns an identifier for a run of the scheduler.
+// Run identifiers are unique across runs started at different times, and do not contain hyphens.
+func NewRunID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// String returns the task ID with which the instance is launched.
+func (id InstanceID) String() string {
+	return fmt.Sprintf("%s%s-%s-%d-%d", taskIDPrefix, id.RunID, id.Task, id.Instance, id.Attempt)
+}
+
+// ParseInstanceID parses the task ID of a task instance launched by Elektron.
+// Task names can contain hyphens, as the run ID cannot, and the instance and the attempt are numbers.
+func ParseInstanceID(taskID string) (InstanceID, error) {
+	invalid := errors.New("invalid task ID " + taskID)
+	if !strings.HasPrefix(taskID, taskIDPrefix) {
+		return InstanceID{}, invalid
+	}
+	fields := strings.Split(strings.TrimPrefix(taskID, taskIDPrefix), "-")
+	if len(fields) < 4 {
+		return InstanceID{}, invalid
+	}
+	instance, err := strconv.Atoi(fields[len(fields)-2])
+	if err != nil || instance < 1 {
+		return InstanceID{}, invalid
+	}
+	attempt, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || attempt < 0 {
+		return InstanceID{}, invalid
+	}
+	id := InstanceID{
+		RunID:    fields[0],
+		Task:     strings.Join(fields[1:len(fields)-2], "-"),
+		Instance: instance,
+		Attempt:  attempt,
+	}
+	if (id.RunID == "") || (id.Task == "") {
+		return InstanceID{}, invalid
+	}
+	return id, nil
+}
+
+// InstanceID returns the identity of the instance of the task that is launched next in the given run.
+// Instances of a task are launched from the last one to the first one, and retries of failed
+// instances relaunch the instance that failed.
+func (tsk Task) InstanceID(runID string) InstanceID {
+	if tsk.Retry != nil {
+		return InstanceID{RunID: runID, Task: tsk.Name, Instance: tsk.Retry.Instance, Attempt: tsk.Retry.Attempt}
+	}
+	return InstanceID{RunID: runID, Task: tsk.Name, Instance: tsk.InstanceOffset + *tsk.Instances}
+}
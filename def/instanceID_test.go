@@ -0,0 +1,63 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package def
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstanceID(t *testing.T) {
+	id := InstanceID{RunID: "k5x2", Task: "mt-dgemm", Instance: 12, Attempt: 1}
+	assert.Equal(t, "electron-k5x2-mt-dgemm-12-1", id.String())
+	parsed, err := ParseInstanceID(id.String())
+	assert.NoError(t, err)
+	assert.Equal(t, id, parsed)
+
+	// Task IDs that were not built from an instance ID.
+	for _, taskID := range []string{
+		"",
+		"minife-1",
+		"electron-minife-1",
+		"electron-k5x2-minife-1",
+		"electron-k5x2-minife-0-0",
+		"electron-k5x2-minife-1-x",
+		"electron--minife-1-0",
+	} {
+		_, err := ParseInstanceID(taskID)
+		assert.Error(t, err, taskID)
+	}
+
+	assert.NotContains(t, NewRunID(), "-")
+}
+
+func TestTask_InstanceID(t *testing.T) {
+	instances := 3
+	task := Task{Name: "minife", Instances: &instances}
+	assert.Equal(t, InstanceID{RunID: "run", Task: "minife", Instance: 3}, task.InstanceID("run"))
+
+	// Instances of the tasks of the same name that were submitted earlier are indexed first.
+	task.InstanceOffset = 10
+	assert.Equal(t, InstanceID{RunID: "run", Task: "minife", Instance: 13}, task.InstanceID("run"))
+
+	// Retries relaunch the instance that failed.
+	task.Retry = &Retry{Instance: 11, Attempt: 2}
+	assert.Equal(t, InstanceID{RunID: "run", Task: "minife", Instance: 11, Attempt: 2}, task.InstanceID("run"))
+}
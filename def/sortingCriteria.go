@@ -28,4 +28,25 @@ var (
 	SortByCPU   = func(task *Task) float64 { return task.CPU }
 	SortByRAM   = func(task *Task) float64 { return task.RAM }
 	SortByWatts = func(task *Task) float64 { return task.Watts }
+	// Tasks of higher priority are ordered first.
+	SortByPriority = func(task *Task) float64 { return -float64(task.Priority) }
 )
+
+// Ordering of tasks based on one or more sorting criteria.
+// Tasks that are equal as per a sorting criteria are ordered using the next one.
+type Ordering []SortBy
+
+// Whether task1 is to be ordered before task2.
+func (o Ordering) Less(task1 *Task, task2 *Task) bool {
+	for _, sb := range o {
+		if value1, value2 := sb(task1), sb(task2); value1 != value2 {
+			return value1 < value2
+		}
+	}
+	return false
+}
+
+// Ordering in which tasks are ordered by priority first, and by the given sorting criteria second.
+func ByPriority(sb SortBy) Ordering {
+	return Ordering{SortByPriority, sb}
+}
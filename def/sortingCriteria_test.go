@@ -29,3 +29,14 @@ func TestSortingCriteria(t *testing.T) {
 	assert.Equal(t, 1024.0, SortByRAM(task))
 	assert.Equal(t, 50.0, SortByWatts(task))
 }
+
+func TestByPriority(t *testing.T) {
+	ordering := ByPriority(SortByWatts)
+	high := &Task{Priority: 2, Watts: 80.0}
+	low := &Task{Priority: 1, Watts: 50.0}
+	lowHeavy := &Task{Priority: 1, Watts: 90.0}
+	assert.True(t, ordering.Less(high, low), "task of higher priority not ordered first")
+	assert.False(t, ordering.Less(low, high))
+	assert.True(t, ordering.Less(low, lowHeavy), "tasks of the same priority not ordered by watts")
+	assert.False(t, ordering.Less(low, low))
+}
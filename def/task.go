@@ -20,8 +20,10 @@ package def
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/spdfg/elektron/utilities/validation"
 	"os"
+	"time"
 
 	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
 	"github.com/pkg/errors"
@@ -40,6 +42,78 @@ type Task struct {
 	Host         string             `json:"host"`
 	TaskID       string             `json:"taskID"`
 	ClassToWatts map[string]float64 `json:"class_to_watts"`
+	// Amount of each scalar resource other than cpu, memory and watts required by an instance of the
+	// task, keyed by name (for example, disk or gpus).
+	ScalarResources map[string]float64 `json:"scalarResources"`
+	// Number of values of each range resource required by an instance of the task, keyed by name
+	// (for example, ports).
+	RangeResources map[string]int `json:"rangeResources"`
+	// Labels by which the task can be referred to in the affinity rules of the workload.
+	Labels []string `json:"labels"`
+	// Constraints on the hosts on which the instances of the task can be placed.
+	Constraints []Constraint `json:"constraints"`
+	// Team or user that submitted the task. Resources are shared fairly between owners.
+	Owner string `json:"owner"`
+	// Tasks of higher priority are scheduled before tasks of lower priority. Defaults to 0.
+	Priority int `json:"priority"`
+	// Names of the tasks all of whose instances need to finish before this task can be scheduled.
+	DependsOn []string `json:"dependsOn"`
+	// Number of times an instance of the task is retried if it fails, is lost or errors out.
+	MaxRetries int `json:"maxRetries"`
+	// Delay before each retry of a failed instance.
+	RetryBackoff Backoff `json:"retryBackoff"`
+	// Whether the retries of a failed instance need to avoid the hosts on which it failed.
+	AvoidFailedHosts bool `json:"avoidFailedHosts"`
+	// Number of seconds for which an instance of the task is expected to run, if known.
+	ExpectedRuntimeSeconds float64 `json:"expectedRuntimeSeconds"`
+	// Number of seconds after which a running instance of the task is killed, if positive.
+	TimeoutSeconds float64 `json:"timeoutSeconds"`
+	// Container in which the instances of the task are run, along with the environment of their command.
+	Container *Container `json:"container"`
+	// Number of instances of the tasks of the same name that were submitted before the task in the
+	// current run of the scheduler. The instances of the task are indexed after them.
+	InstanceOffset int `json:"-"`
+	// Set if the task corresponds to a retry of a failed instance.
+	Retry *Retry `json:"-"`
+}
+
+// Backoff policy for the retries of failed task instances.
+// The delay before the nth retry is InitialSeconds * Multiplier^(n-1), capped at MaxSeconds if provided.
+type Backoff struct {
+	InitialSeconds float64 `json:"initialSeconds"`
+	// If not provided, then the delay is constant.
+	Multiplier float64 `json:"multiplier"`
+	MaxSeconds float64 `json:"maxSeconds"`
+}
+
+// Delay before the given retry (starting from 1).
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := b.InitialSeconds
+	for i := 1; (i < attempt) && (b.Multiplier > 1.0); i++ {
+		delay *= b.Multiplier
+		if (b.MaxSeconds > 0.0) && (delay >= b.MaxSeconds) {
+			break
+		}
+	}
+	if (b.MaxSeconds > 0.0) && (delay > b.MaxSeconds) {
+		delay = b.MaxSeconds
+	}
+	return time.Duration(delay * float64(time.Second))
+}
+
+// Retry of a failed task instance.
+type Retry struct {
+	// Instance of the task that is retried.
+	Instance int
+	// Number of times the instance has been relaunched, starting from 1.
+	Attempt int
+	// Number of times the instance has been retried after it failed. Instances that are
+	// relaunched because their agent was lost do not count towards the retries.
+	Retries int
+	// Hosts on which the instance failed, if they need to be avoided.
+	FailedHosts []string
+	// Time before which the retry cannot be scheduled.
+	NotBefore time.Time
 }
 
 func TasksFromJSON(uri string) ([]Task, error) {
@@ -57,19 +131,105 @@ func TasksFromJSON(uri string) ([]Task, error) {
 	}
 
 	// Validating task definitions.
+	if err := ValidateTasks(tasks); err != nil {
+		return tasks, err
+	}
+	if err := ValidateDependencies(tasks, nil); err != nil {
+		return tasks, err
+	}
+	// The resource requirements of the tasks are recorded by the scheduler, once their instances
+	// have been indexed in its run.
+	return tasks, nil
+}
+
+// Validate the given task definitions.
+// An error corresponding to the first invalid task definition is returned.
+func ValidateTasks(tasks []Task) error {
 	for _, task := range tasks {
 		err := validation.Validate("invalid task definition",
 			ValidatorForTask(task,
 				withNameValidator(),
 				withImageValidator(),
-				withResourceValidator()))
+				withResourceValidator(),
+				withInstancesValidator(),
+				withRetryValidator(),
+				withExpectedRuntimeValidator(),
+				withTimeoutValidator(),
+				withContainerValidator()))
 		if err != nil {
-			return tasks, err
+			return err
 		}
 	}
+	return nil
+}
 
-	initTaskResourceRequirements(tasks)
-	return tasks, nil
+// Validate the dependencies of the given tasks.
+// Tasks can only depend on the given tasks or on the tasks with the given names (for example,
+// tasks that were submitted earlier). Dependencies cannot be cyclic.
+func ValidateDependencies(tasks []Task, existingTaskNames []string) error {
+	taskNames := make(map[string]bool)
+	for _, name := range existingTaskNames {
+		taskNames[name] = true
+	}
+	tasksByName := make(map[string]Task)
+	for _, task := range tasks {
+		taskNames[task.Name] = true
+		tasksByName[task.Name] = task
+	}
+
+	for _, task := range tasks {
+		for _, parent := range task.DependsOn {
+			if !taskNames[parent] {
+				return errors.New(fmt.Sprintf("invalid task definition: task %s depends on unknown task %s",
+					task.Name, parent))
+			}
+		}
+	}
+
+	// Detecting cycles using a depth first traversal of the dependencies.
+	// Tasks that were submitted earlier cannot depend on the given tasks, and so cannot be part of a cycle.
+	// Tasks that have not been visited yet have no state.
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make(map[string]int)
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return errors.New(fmt.Sprintf("invalid task definition: cyclic dependency on task %s", name))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, parent := range tasksByName[name].DependsOn {
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, task := range tasks {
+		if err := visit(task.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Whether the task corresponds to a retry of a failed instance that needs to avoid the given host.
+func (tsk Task) AvoidsHost(host string) bool {
+	if tsk.Retry == nil {
+		return false
+	}
+	for _, failedHost := range tsk.Retry.FailedHosts {
+		if failedHost == host {
+			return true
+		}
+	}
+	return false
 }
 
 // Update the host on which the task needs to be scheduled.
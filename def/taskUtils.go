@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"sync"
 
 	"github.com/mash/gokmeans"
 	"github.com/montanaflynn/stats"
@@ -162,34 +163,118 @@ func SortTasks(ts []Task, sb SortBy) {
 	})
 }
 
+// Sort tasks as per the given ordering.
+// Tasks that are equal as per the ordering retain their relative order.
+func SortTasksBy(ts []Task, ordering Ordering) {
+	sort.SliceStable(ts, func(i, j int) bool {
+		return ordering.Less(&ts[i], &ts[j])
+	})
+}
+
 // Map taskIDs to resource requirements.
 type TaskResources struct {
 	CPU   float64
 	Ram   float64
 	Watts float64
+	// Owner of the task, to whom the resources are allocated.
+	Owner string
 }
 
-var taskResourceRequirement map[string]*TaskResources
+// Resource requirements are recorded for each instance of a task launched in a run of the scheduler,
+// and are shared by all the launches of the instance in that run.
+type instanceKey struct {
+	runID    string
+	task     string
+	instance int
+}
+
+var taskResourceRequirement map[instanceKey]*TaskResources
+
+// Tasks can be submitted while the resource requirements of other tasks are being retrieved.
+var taskResourceRequirementMutex sync.RWMutex
 
-// Record resource requirements for all the tasks.
-func initTaskResourceRequirements(tasks []Task) {
-	taskResourceRequirement = make(map[string]*TaskResources)
-	baseTaskID := "electron-"
+// Record resource requirements for tasks that are added to the task queue, whose instances are to be
+// launched in the run of the scheduler with the given ID. The instances of the tasks need to have been
+// indexed, and retries are recorded for the instance that they retry.
+func RecordTaskResourceRequirements(runID string, tasks []Task) {
+	taskResourceRequirementMutex.Lock()
+	defer taskResourceRequirementMutex.Unlock()
 	for _, task := range tasks {
+		if task.Retry != nil {
+			recordResourceRequirements(instanceKey{runID: runID, task: task.Name, instance: task.Retry.Instance},
+				task)
+			continue
+		}
 		for i := *task.Instances; i > 0; i-- {
-			taskID := fmt.Sprintf("%s-%d", baseTaskID+task.Name, i)
-			taskResourceRequirement[taskID] = &TaskResources{
-				CPU:   task.CPU,
-				Ram:   task.RAM,
-				Watts: task.Watts,
-			}
+			recordResourceRequirements(instanceKey{runID: runID, task: task.Name,
+				instance: task.InstanceOffset + i}, task)
 		}
 	}
 }
 
+// Record resource requirements for a task instance that is launched with the given TaskID (for example,
+// an instance that was launched before the scheduler restarted).
+func RecordInstanceResourceRequirements(taskID string, task Task) {
+	id, err := ParseInstanceID(taskID)
+	if err != nil {
+		return
+	}
+	taskResourceRequirementMutex.Lock()
+	defer taskResourceRequirementMutex.Unlock()
+	recordResourceRequirements(instanceKey{runID: id.RunID, task: id.Task, instance: id.Instance}, task)
+}
+
+// Forget the resource requirements of a task instance, launched with the given TaskID, that is not
+// going to be launched again.
+func ForgetInstanceResourceRequirements(taskID string) {
+	id, err := ParseInstanceID(taskID)
+	if err != nil {
+		return
+	}
+	taskResourceRequirementMutex.Lock()
+	defer taskResourceRequirementMutex.Unlock()
+	delete(taskResourceRequirement, instanceKey{runID: id.RunID, task: id.Task, instance: id.Instance})
+}
+
+// Forget the resource requirements of the given number of pending instances of a task in the task
+// queue, that are not going to be launched in the run of the scheduler with the given ID.
+// The instances of a task are launched in decreasing order of their index, and so the instances that
+// would have been launched last are forgotten.
+func ForgetTaskResourceRequirements(runID string, task Task, instances int) {
+	taskResourceRequirementMutex.Lock()
+	defer taskResourceRequirementMutex.Unlock()
+	if task.Retry != nil {
+		delete(taskResourceRequirement, instanceKey{runID: runID, task: task.Name, instance: task.Retry.Instance})
+		return
+	}
+	for i := 0; i < instances; i++ {
+		delete(taskResourceRequirement, instanceKey{runID: runID, task: task.Name,
+			instance: task.InstanceOffset + *task.Instances - i})
+	}
+}
+
+// Needs to be called with the resource requirements locked.
+func recordResourceRequirements(key instanceKey, task Task) {
+	if taskResourceRequirement == nil {
+		taskResourceRequirement = make(map[instanceKey]*TaskResources)
+	}
+	taskResourceRequirement[key] = &TaskResources{
+		CPU:   task.CPU,
+		Ram:   task.RAM,
+		Watts: task.Watts,
+		Owner: task.Owner,
+	}
+}
+
 // Retrieve the resource requirement of a task specified by the TaskID
 func GetResourceRequirement(taskID string) (TaskResources, error) {
-	if tr, ok := taskResourceRequirement[taskID]; ok {
+	id, err := ParseInstanceID(taskID)
+	if err != nil {
+		return TaskResources{}, errors.New("Invalid TaskID: " + taskID)
+	}
+	taskResourceRequirementMutex.RLock()
+	defer taskResourceRequirementMutex.RUnlock()
+	if tr, ok := taskResourceRequirement[instanceKey{runID: id.RunID, task: id.Task, instance: id.Instance}]; ok {
 		return *tr, nil
 	} else {
 		// Shouldn't be here.
@@ -30,7 +30,7 @@ var tasks = []Task{
 		CPU:       4.0,
 		RAM:       1024,
 		Watts:     50.0,
-		TaskID:    "electron-task1-1",
+		TaskID:    "electron-run-task1-1-0",
 		Instances: &instances,
 	},
 	{
@@ -38,7 +38,7 @@ var tasks = []Task{
 		CPU:       3.0,
 		RAM:       128,
 		Watts:     55.0,
-		TaskID:    "electron-task2-1",
+		TaskID:    "electron-run-task2-1-0",
 		Instances: &instances,
 	},
 	{
@@ -46,7 +46,7 @@ var tasks = []Task{
 		CPU:       2.0,
 		RAM:       64,
 		Watts:     75.0,
-		TaskID:    "electron-task3-1",
+		TaskID:    "electron-run-task3-1-0",
 		Instances: &instances,
 	},
 	{
@@ -54,7 +54,7 @@ var tasks = []Task{
 		CPU:       1.0,
 		RAM:       3072,
 		Watts:     81.0,
-		TaskID:    "electron-task4-1",
+		TaskID:    "electron-run-task4-1-0",
 		Instances: &instances,
 	},
 }
@@ -116,7 +116,7 @@ func TestSortTasks(t *testing.T) {
 }
 
 func TestGetResourceRequirement(t *testing.T) {
-	initTaskResourceRequirements(tasks)
+	RecordTaskResourceRequirements("run", tasks)
 
 	for _, task := range tasks {
 		resources, err := GetResourceRequirement(task.TaskID)
@@ -125,6 +125,62 @@ func TestGetResourceRequirement(t *testing.T) {
 		assert.Equal(t, resources.Ram, task.RAM)
 		assert.Equal(t, resources.Watts, task.Watts)
 	}
+
+	// Resource requirements are shared by all the launches of an instance in a run, and instances of
+	// tasks of the same name are told apart by their index.
+	resubmittedInstances := 2
+	RecordTaskResourceRequirements("run", []Task{
+		{Name: "task1", CPU: 2.0, Instances: &resubmittedInstances, InstanceOffset: 1},
+	})
+	retryInstances := 1
+	RecordTaskResourceRequirements("other", []Task{
+		{Name: "task1", CPU: 1.0, Instances: &retryInstances, Retry: &Retry{Instance: 2, Attempt: 1}},
+	})
+	for taskID, cpu := range map[string]float64{
+		"electron-run-task1-1-0":   4.0,
+		"electron-run-task1-2-0":   2.0,
+		"electron-run-task1-3-1":   2.0,
+		"electron-other-task1-2-1": 1.0,
+	} {
+		resources, err := GetResourceRequirement(taskID)
+		assert.NoError(t, err)
+		assert.Equal(t, cpu, resources.CPU, taskID)
+	}
+	_, err := GetResourceRequirement("electron-run-task1-4-0")
+	assert.Error(t, err)
+	// Instances of different runs do not share resource requirements.
+	_, err = GetResourceRequirement("electron-other-task1-1-3")
+	assert.Error(t, err)
+	_, err = GetResourceRequirement("electron-task1-1")
+	assert.Error(t, err)
+}
+
+func TestForgetResourceRequirements(t *testing.T) {
+	instances := 3
+	RecordTaskResourceRequirements("forget", []Task{
+		{Name: "task1", CPU: 1.0, Instances: &instances, InstanceOffset: 2},
+	})
+	retryInstances := 1
+	RecordTaskResourceRequirements("forget", []Task{
+		{Name: "task1", CPU: 1.0, Instances: &retryInstances, Retry: &Retry{Instance: 1, Attempt: 1}},
+	})
+
+	// The instances that would have been launched last are forgotten when pending instances are cancelled.
+	pending := 2
+	ForgetTaskResourceRequirements("forget", Task{Name: "task1", Instances: &pending, InstanceOffset: 2}, 1)
+	ForgetTaskResourceRequirements("forget",
+		Task{Name: "task1", Instances: &retryInstances, Retry: &Retry{Instance: 1, Attempt: 1}}, 1)
+	ForgetInstanceResourceRequirements("electron-forget-task1-5-0")
+	ForgetInstanceResourceRequirements("electron-task1-3")
+	for taskID, forgotten := range map[string]bool{
+		"electron-forget-task1-1-1": true,
+		"electron-forget-task1-3-0": false,
+		"electron-forget-task1-4-0": true,
+		"electron-forget-task1-5-0": true,
+	} {
+		_, err := GetResourceRequirement(taskID)
+		assert.Equal(t, forgotten, err != nil, taskID)
+	}
 }
 
 func TestGetTaskDistributionInWindow(t *testing.T) {
@@ -134,3 +190,20 @@ func TestGetTaskDistributionInWindow(t *testing.T) {
 	// The tasks above are evenly distributed hence, task distribution should be 1.0.
 	assert.Equal(t, taskDistribution, 1.0, "task distribution determined is incorrect")
 }
+
+func TestSortTasksBy(t *testing.T) {
+	tasks := []Task{
+		{Name: "task1", Priority: 0, Watts: 50.0},
+		{Name: "task2", Priority: 1, Watts: 75.0},
+		{Name: "task3", Priority: 0, Watts: 40.0},
+		{Name: "task4", Priority: 1, Watts: 55.0},
+		{Name: "task5", Priority: 1, Watts: 55.0},
+	}
+	SortTasksBy(tasks, ByPriority(SortByWatts))
+	var names []string
+	for _, task := range tasks {
+		names = append(names, task.Name)
+	}
+	// Tasks that are equal retain their relative order.
+	assert.Equal(t, []string{"task4", "task5", "task2", "task3", "task1"}, names)
+}
@@ -19,9 +19,12 @@
 package def
 
 import (
+	"fmt"
+
 	"github.com/pkg/errors"
 	"github.com/spdfg/elektron/utilities/validation"
 	"regexp"
+	"strings"
 )
 
 // taskValidator is a validator that validates one or more attributes of a task.
@@ -55,12 +58,16 @@ func withNameValidator() taskValidator {
 			return errors.New("task name cannot contain tabs or spaces")
 		}
 
+		// Task name cannot contain the separators of the fields of the logs in which task IDs are logged.
+		if strings.ContainsAny(t.Name, "=,") {
+			return errors.New("task name cannot contain '=' or ','")
+		}
+
 		return nil
 	}
 }
 
 // withResourceValidator returns a taskValidator that checks whether the resource requirements are valid.
-// Currently, only requirements for traditional resources (cpu and memory) are validated.
 func withResourceValidator() taskValidator {
 	return func(t Task) error {
 		// CPU value cannot be 0.
@@ -73,6 +80,22 @@ func withResourceValidator() taskValidator {
 			return errors.New("memory resource for task cannot be 0")
 		}
 
+		for name, amount := range t.ScalarResources {
+			// cpu, memory and watts have their own fields.
+			if name == "cpus" || name == "mem" || name == "watts" {
+				return errors.New(fmt.Sprintf("%s resource for task needs to be provided using its own field", name))
+			}
+			if amount < 0.0 {
+				return errors.New(fmt.Sprintf("%s resource for task cannot be negative", name))
+			}
+		}
+
+		for name, count := range t.RangeResources {
+			if count < 0 {
+				return errors.New(fmt.Sprintf("%s resource for task cannot be negative", name))
+			}
+		}
+
 		return nil
 	}
 }
@@ -81,11 +104,127 @@ func withResourceValidator() taskValidator {
 // provided for the task.
 func withImageValidator() taskValidator {
 	return func(t Task) error {
-		// Image cannot be empty.
-		if t.Image == "" {
+		// Image cannot be empty, unless the command is run on the host by the unified containerizer.
+		if (t.Image == "") && (t.containerizer() != mesosContainerizer) {
 			return errors.New("valid image needs to be provided for task")
 		}
 
 		return nil
 	}
 }
+
+// withInstancesValidator returns a taskValidator that checks whether the number of instances
+// of the task is valid.
+func withInstancesValidator() taskValidator {
+	return func(t Task) error {
+		// Number of instances needs to be provided and cannot be less than 1.
+		if t.Instances == nil || *t.Instances < 1 {
+			return errors.New("task needs to have at least one instance")
+		}
+
+		return nil
+	}
+}
+
+// withRetryValidator returns a taskValidator that checks whether the retry policy of the task is valid.
+func withRetryValidator() taskValidator {
+	return func(t Task) error {
+		// Number of retries cannot be negative.
+		if t.MaxRetries < 0 {
+			return errors.New("maximum number of retries for task cannot be negative")
+		}
+
+		// Backoff delays cannot be negative.
+		if (t.RetryBackoff.InitialSeconds < 0.0) || (t.RetryBackoff.MaxSeconds < 0.0) {
+			return errors.New("retry backoff for task cannot be negative")
+		}
+
+		// Delays cannot decrease between retries.
+		if (t.RetryBackoff.Multiplier != 0.0) && (t.RetryBackoff.Multiplier < 1.0) {
+			return errors.New("retry backoff multiplier for task cannot be less than 1")
+		}
+
+		return nil
+	}
+}
+
+// withExpectedRuntimeValidator returns a taskValidator that checks whether the expected runtime of the task is valid.
+func withExpectedRuntimeValidator() taskValidator {
+	return func(t Task) error {
+		// Expected runtime cannot be negative.
+		if t.ExpectedRuntimeSeconds < 0.0 {
+			return errors.New("expected runtime for task cannot be negative")
+		}
+
+		return nil
+	}
+}
+
+// withTimeoutValidator returns a taskValidator that checks whether the timeout of the task is valid.
+func withTimeoutValidator() taskValidator {
+	return func(t Task) error {
+		// Timeout cannot be negative.
+		if t.TimeoutSeconds < 0.0 {
+			return errors.New("timeout for task cannot be negative")
+		}
+
+		return nil
+	}
+}
+
+// withContainerValidator returns a taskValidator that checks whether the container spec of the task is valid.
+func withContainerValidator() taskValidator {
+	return func(t Task) error {
+		if t.Container == nil {
+			return nil
+		}
+		container := t.Container
+		switch t.containerizer() {
+		case dockerContainerizer:
+			if _, ok := dockerNetworks[container.Network]; (container.Network != "") && !ok {
+				return errors.New(fmt.Sprintf("invalid network %s for container", container.Network))
+			}
+		case mesosContainerizer:
+			// Options that are specific to Docker cannot be used with the unified containerizer.
+			if (container.Network != "") && (container.Network != "host") {
+				return errors.New("containers run by the mesos containerizer share the network of the host")
+			}
+			if container.ForcePullImage || (len(container.Parameters) > 0) {
+				return errors.New("docker options cannot be used with the mesos containerizer")
+			}
+		default:
+			return errors.New(fmt.Sprintf("invalid container type %s (docker or mesos)", container.Type))
+		}
+
+		for _, parameter := range container.Parameters {
+			if parameter.Key == "" {
+				return errors.New("docker parameters need to have a key")
+			}
+		}
+		for key := range container.Labels {
+			if key == "" {
+				return errors.New("container labels need to have a key")
+			}
+		}
+		for _, volume := range container.Volumes {
+			if volume.ContainerPath == "" {
+				return errors.New("volumes need to have a container path")
+			}
+			if _, ok := volumeModes[volume.Mode]; (volume.Mode != "") && !ok {
+				return errors.New(fmt.Sprintf("invalid mode %s for volume (RW or RO)", volume.Mode))
+			}
+		}
+		for name := range container.Environment {
+			if name == "" {
+				return errors.New("environment variables need to have a name")
+			}
+		}
+		for _, uri := range container.URIs {
+			if uri.Value == "" {
+				return errors.New("URIs to fetch cannot be empty")
+			}
+		}
+
+		return nil
+	}
+}
@@ -60,6 +60,10 @@ func TestValidatorForTask(t *testing.T) {
 	invalidTaskNameWithSpaces := validTask
 	invalidTaskNameWithSpaces.Name = "my task"
 	test(invalidTaskNameWithSpaces, true, "invalid task definition")
+	// Task with name that contains the separators of log fields.
+	invalidTaskNameWithSeparators := validTask
+	invalidTaskNameWithSeparators.Name = "host=minife,1"
+	test(invalidTaskNameWithSeparators, true, "invalid task definition")
 	// Task with invalid image.
 	invalidTaskImage := validTask
 	invalidTaskImage.Image = ""
@@ -73,3 +77,124 @@ func TestValidatorForTask(t *testing.T) {
 	invalidTaskResourcesRAM.RAM = 0
 	test(invalidTaskResourcesRAM, true, "invalid task definition")
 }
+
+func TestWithRetryValidator(t *testing.T) {
+	validator := withRetryValidator()
+
+	inst := 1
+	task := Task{
+		Name:       "minife",
+		Instances:  &inst,
+		MaxRetries: 3,
+		RetryBackoff: Backoff{
+			InitialSeconds: 1,
+			Multiplier:     2,
+			MaxSeconds:     10,
+		},
+	}
+	assert.NoError(t, validator(task))
+	// Task with negative number of retries.
+	invalidTaskRetries := task
+	invalidTaskRetries.MaxRetries = -1
+	assert.Error(t, validator(invalidTaskRetries))
+	// Task with negative backoff.
+	invalidTaskBackoff := task
+	invalidTaskBackoff.RetryBackoff.InitialSeconds = -1
+	assert.Error(t, validator(invalidTaskBackoff))
+	// Task with decreasing backoff.
+	invalidTaskMultiplier := task
+	invalidTaskMultiplier.RetryBackoff.Multiplier = 0.5
+	assert.Error(t, validator(invalidTaskMultiplier))
+}
+
+func TestWithExpectedRuntimeValidator(t *testing.T) {
+	validator := withExpectedRuntimeValidator()
+
+	task := Task{Name: "minife"}
+	assert.NoError(t, validator(task))
+	task.ExpectedRuntimeSeconds = 120.0
+	assert.NoError(t, validator(task))
+	// Task with negative expected runtime.
+	task.ExpectedRuntimeSeconds = -1.0
+	assert.Error(t, validator(task))
+}
+
+func TestWithTimeoutValidator(t *testing.T) {
+	validator := withTimeoutValidator()
+
+	task := Task{Name: "minife"}
+	assert.NoError(t, validator(task))
+	task.TimeoutSeconds = 600.0
+	assert.NoError(t, validator(task))
+	// Task with negative timeout.
+	task.TimeoutSeconds = -1.0
+	assert.Error(t, validator(task))
+}
+
+func TestWithContainerValidator(t *testing.T) {
+	validator := withContainerValidator()
+
+	task := Task{Name: "minife", Image: "rdelvalle/minife:electron1"}
+	assert.NoError(t, validator(task))
+	task.Container = &Container{
+		Network:     "host",
+		Parameters:  []Parameter{{Key: "cpuset-cpus", Value: "0-3"}},
+		Volumes:     []Volume{{ContainerPath: "/data", HostPath: "/mnt/data", Mode: "RO"}},
+		Environment: map[string]string{"OMP_NUM_THREADS": "4"},
+		URIs:        []URI{{Value: "http://example.com/run.sh"}},
+	}
+	assert.NoError(t, validator(task))
+
+	invalid := func(modify func(container *Container)) Task {
+		invalidTask := task
+		container := *task.Container
+		modify(&container)
+		invalidTask.Container = &container
+		return invalidTask
+	}
+	// Invalid container type.
+	assert.Error(t, validator(invalid(func(c *Container) { c.Type = "rkt" })))
+	// Invalid network.
+	assert.Error(t, validator(invalid(func(c *Container) { c.Network = "overlay" })))
+	// Docker options with the unified containerizer.
+	assert.Error(t, validator(invalid(func(c *Container) { c.Type = "mesos" })))
+	assert.NoError(t, validator(invalid(func(c *Container) { c.Type = "mesos"; c.Parameters = nil })))
+	assert.Error(t, validator(invalid(func(c *Container) {
+		c.Type = "mesos"
+		c.Parameters = nil
+		c.Network = "bridge"
+	})))
+	// Docker parameter without a key.
+	assert.Error(t, validator(invalid(func(c *Container) { c.Parameters = []Parameter{{Value: "0-3"}} })))
+	// Volume without a container path, and with an invalid mode.
+	assert.Error(t, validator(invalid(func(c *Container) { c.Volumes = []Volume{{HostPath: "/mnt/data"}} })))
+	assert.Error(t, validator(invalid(func(c *Container) {
+		c.Volumes = []Volume{{ContainerPath: "/data", Mode: "rw"}}
+	})))
+	// Environment variable without a name.
+	assert.Error(t, validator(invalid(func(c *Container) { c.Environment = map[string]string{"": "4"} })))
+	// Empty URI.
+	assert.Error(t, validator(invalid(func(c *Container) { c.URIs = []URI{{Executable: true}} })))
+}
+
+func TestWithResourceValidator(t *testing.T) {
+	validator := withResourceValidator()
+
+	task := Task{Name: "minife", CPU: 3.0, RAM: 4096}
+	assert.NoError(t, validator(task))
+	task.ScalarResources = map[string]float64{"gpus": 1.0, "disk": 1024.0}
+	task.RangeResources = map[string]int{"ports": 2}
+	assert.NoError(t, validator(task))
+
+	// Task with negative range resource.
+	task.RangeResources["ports"] = -1
+	assert.Error(t, validator(task))
+	task.RangeResources["ports"] = 2
+	// Task with negative scalar resource.
+	task.ScalarResources["gpus"] = -1.0
+	assert.Error(t, validator(task))
+	task.ScalarResources["gpus"] = 1.0
+	// Task that provides cpus as a scalar resource.
+	task.ScalarResources["cpus"] = 1.0
+	assert.Error(t, validator(task))
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
 	"github.com/spdfg/elektron/constants"
@@ -141,3 +142,52 @@ func TestCompare(t *testing.T) {
 	// If two tasks have the same Task ID they should be the same task.
 	assert.True(t, Compare(&task1, &task2))
 }
+
+func TestValidateDependencies(t *testing.T) {
+	tasks := []Task{
+		{Name: "preprocess"},
+		{Name: "train", DependsOn: []string{"preprocess"}},
+		{Name: "evaluate", DependsOn: []string{"train", "preprocess"}},
+	}
+	assert.NoError(t, ValidateDependencies(tasks, nil))
+
+	// Dependencies on unknown tasks.
+	tasks = []Task{{Name: "train", DependsOn: []string{"preprocess"}}}
+	assert.Error(t, ValidateDependencies(tasks, nil))
+	// Dependencies on tasks that were submitted earlier.
+	assert.NoError(t, ValidateDependencies(tasks, []string{"preprocess"}))
+
+	// Cyclic dependencies.
+	tasks = []Task{
+		{Name: "preprocess", DependsOn: []string{"evaluate"}},
+		{Name: "train", DependsOn: []string{"preprocess"}},
+		{Name: "evaluate", DependsOn: []string{"train"}},
+	}
+	assert.Error(t, ValidateDependencies(tasks, nil))
+	tasks = []Task{{Name: "train", DependsOn: []string{"train"}}}
+	assert.Error(t, ValidateDependencies(tasks, nil))
+}
+
+func TestBackoff_Delay(t *testing.T) {
+	constant := Backoff{InitialSeconds: 2}
+	assert.Equal(t, 2*time.Second, constant.Delay(1))
+	assert.Equal(t, 2*time.Second, constant.Delay(5))
+
+	exponential := Backoff{InitialSeconds: 1, Multiplier: 2, MaxSeconds: 5}
+	assert.Equal(t, 1*time.Second, exponential.Delay(1))
+	assert.Equal(t, 2*time.Second, exponential.Delay(2))
+	assert.Equal(t, 4*time.Second, exponential.Delay(3))
+	assert.Equal(t, 5*time.Second, exponential.Delay(4))
+	assert.Equal(t, 5*time.Second, exponential.Delay(100))
+
+	assert.Equal(t, time.Duration(0), Backoff{}.Delay(1))
+}
+
+func TestTask_AvoidsHost(t *testing.T) {
+	task := Task{Name: "minife"}
+	assert.False(t, task.AvoidsHost("host1"))
+
+	task.Retry = &Retry{Instance: 1, Attempt: 1, FailedHosts: []string{"host1"}}
+	assert.True(t, task.AvoidsHost("host1"))
+	assert.False(t, task.AvoidsHost("host2"))
+}
@@ -0,0 +1,223 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+// Package httpServer serves an HTTP API to submit tasks to, and inspect and
+// cancel tasks in, the task queue of a long-running scheduler.
+//
+// The following endpoints are served.
+//
+//	GET    /tasks                     - List pending and running tasks.
+//	POST   /tasks                     - Submit a JSON array of task definitions (same format as the workload file).
+//	DELETE /tasks/<name>[?instances=N] - Cancel N (default all) pending instances of a task.
+//	DELETE /tasks/<name>?kill=true     - Cancel all pending instances of a task and kill its launched instances.
+//	DELETE /instances/<taskID>         - Kill a launched task instance.
+//	POST   /drain                     - Stop launching tasks and shut down once the launched instances terminate.
+//	POST   /stop                      - Stop launching tasks, kill the launched instances and shut down.
+package httpServer
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spdfg/elektron/def"
+	elekLog "github.com/spdfg/elektron/logging"
+	. "github.com/spdfg/elektron/logging/types"
+	"github.com/spdfg/elektron/schedulers"
+)
+
+// TaskQueue provides access to the task queue of the scheduler.
+type TaskQueue interface {
+	// Add tasks to the task queue.
+	SubmitTasks(tasks []def.Task) error
+	// Retrieve the tasks that have instances yet to be scheduled.
+	PendingTasks() []def.Task
+	// Retrieve the tasks that are currently running on the cluster.
+	RunningTasks() []schedulers.RunningTask
+	// Cancel pending instances of a task and return the number of instances cancelled.
+	CancelPendingInstances(taskName string, instances int) (int, error)
+	// Kill a launched task instance.
+	KillInstance(taskID string) error
+	// Cancel all pending instances of a task and kill its launched instances.
+	// Return the number of instances cancelled and the number of instances killed.
+	KillTask(taskName string) (int, int, error)
+	// Stop launching tasks and shut down once the launched instances have terminated.
+	Drain()
+	// Stop launching tasks, kill the launched instances and shut down once they have been killed.
+	HardStop()
+}
+
+type server struct {
+	queue TaskQueue
+}
+
+// NewHandler returns a handler that serves the task queue API.
+func NewHandler(queue TaskQueue) http.Handler {
+	s := &server{queue: queue}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tasks", s.handleTasks)
+	mux.HandleFunc("/tasks/", s.handleTask)
+	mux.HandleFunc("/instances/", s.handleInstance)
+	mux.HandleFunc("/drain", s.handleShutdown(queue.Drain))
+	mux.HandleFunc("/stop", s.handleShutdown(queue.HardStop))
+	return mux
+}
+
+// Start serving the task queue API on the given address.
+func Start(addr string, queue TaskQueue) {
+	elekLog.WithField("address", addr).Log(CONSOLE, log.InfoLevel, "Task submission API started")
+	if err := http.ListenAndServe(addr, NewHandler(queue)); err != nil {
+		log.Fatal(errors.Wrap(err, "Task submission API stopped"))
+	}
+}
+
+func (s *server) handleTasks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, struct {
+			Pending []def.Task               `json:"pending"`
+			Running []schedulers.RunningTask `json:"running"`
+		}{
+			Pending: s.queue.PendingTasks(),
+			Running: s.queue.RunningTasks(),
+		})
+	case http.MethodPost:
+		var tasks []def.Task
+		if err := json.NewDecoder(r.Body).Decode(&tasks); err != nil {
+			writeError(w, http.StatusBadRequest, "Error unmarshalling: "+err.Error())
+			return
+		}
+		if len(tasks) == 0 {
+			writeError(w, http.StatusBadRequest, "no tasks provided")
+			return
+		}
+		if err := s.queue.SubmitTasks(tasks); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusAccepted, struct {
+			Submitted int `json:"submitted"`
+		}{Submitted: len(tasks)})
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *server) handleTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	taskName := strings.TrimPrefix(r.URL.Path, "/tasks/")
+	if taskName == "" || strings.Contains(taskName, "/") {
+		writeError(w, http.StatusNotFound, "invalid task name")
+		return
+	}
+
+	if r.URL.Query().Get("kill") == "true" {
+		if r.URL.Query().Get("instances") != "" {
+			writeError(w, http.StatusBadRequest, "instances cannot be provided when killing a task")
+			return
+		}
+		cancelled, killed, err := s.queue.KillTask(taskName)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, struct {
+			Cancelled int `json:"cancelled"`
+			Killed    int `json:"killed"`
+		}{Cancelled: cancelled, Killed: killed})
+		return
+	}
+
+	// All pending instances are cancelled if the number of instances is not provided.
+	instances := 0
+	if value := r.URL.Query().Get("instances"); value != "" {
+		var err error
+		if instances, err = strconv.Atoi(value); err != nil || instances < 1 {
+			writeError(w, http.StatusBadRequest, "instances needs to be a positive integer")
+			return
+		}
+	}
+
+	cancelled, err := s.queue.CancelPendingInstances(taskName, instances)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Cancelled int `json:"cancelled"`
+	}{Cancelled: cancelled})
+}
+
+func (s *server) handleInstance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	taskID := strings.TrimPrefix(r.URL.Path, "/instances/")
+	if taskID == "" || strings.Contains(taskID, "/") {
+		writeError(w, http.StatusNotFound, "invalid task ID")
+		return
+	}
+	if err := s.queue.KillInstance(taskID); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Killed string `json:"killed"`
+	}{Killed: taskID})
+}
+
+// Handler that shuts the scheduler down as per the given mode.
+func (s *server) handleShutdown(shutdown func()) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		shutdown()
+		writeJSON(w, http.StatusAccepted, struct {
+			ShuttingDown bool `json:"shuttingDown"`
+		}{ShuttingDown: true})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		elekLog.Logf(CONSOLE, log.ErrorLevel, "Failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{Error: message})
+}
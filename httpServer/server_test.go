@@ -0,0 +1,240 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package httpServer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/spdfg/elektron/def"
+	"github.com/spdfg/elektron/schedulers"
+	"github.com/stretchr/testify/assert"
+)
+
+type testTaskQueue struct {
+	pending  []def.Task
+	running  []schedulers.RunningTask
+	shutdown string
+}
+
+func (q *testTaskQueue) SubmitTasks(tasks []def.Task) error {
+	if err := def.ValidateTasks(tasks); err != nil {
+		return err
+	}
+	q.pending = append(q.pending, tasks...)
+	return nil
+}
+
+func (q *testTaskQueue) PendingTasks() []def.Task {
+	return q.pending
+}
+
+func (q *testTaskQueue) RunningTasks() []schedulers.RunningTask {
+	return q.running
+}
+
+func (q *testTaskQueue) CancelPendingInstances(taskName string, instances int) (int, error) {
+	for i, task := range q.pending {
+		if task.Name == taskName {
+			cancelled := *task.Instances
+			if instances > 0 && instances < cancelled {
+				cancelled = instances
+			}
+			*task.Instances -= cancelled
+			if *task.Instances == 0 {
+				q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			}
+			return cancelled, nil
+		}
+	}
+	return 0, errors.New("no pending instances of task " + taskName)
+}
+
+func (q *testTaskQueue) KillInstance(taskID string) error {
+	for i, task := range q.running {
+		if task.TaskID == taskID {
+			q.running = append(q.running[:i], q.running[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("no launched instance with task ID " + taskID)
+}
+
+func (q *testTaskQueue) KillTask(taskName string) (int, int, error) {
+	cancelled, _ := q.CancelPendingInstances(taskName, 0)
+	killed := 0
+	running := q.running[:0]
+	for _, task := range q.running {
+		if strings.HasPrefix(task.TaskID, "electron-"+taskName+"-") {
+			killed++
+			continue
+		}
+		running = append(running, task)
+	}
+	q.running = running
+	if cancelled == 0 && killed == 0 {
+		return 0, 0, errors.New("no pending or launched instances of task " + taskName)
+	}
+	return cancelled, killed, nil
+}
+
+func (q *testTaskQueue) Drain() {
+	q.shutdown = "drain"
+}
+
+func (q *testTaskQueue) HardStop() {
+	q.shutdown = "stop"
+}
+
+func serve(handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return recorder
+}
+
+func TestSubmitTasks(t *testing.T) {
+	queue := &testTaskQueue{}
+	handler := NewHandler(queue)
+
+	validTasks := `[{"name": "minife", "cpu": 3.0, "ram": 4096, "watts": 63.141,
+		"image": "rdelvalle/minife:electron1", "cmd": "cd src && mpirun -np 3 miniFE.x", "inst": 10}]`
+	response := serve(handler, http.MethodPost, "/tasks", validTasks)
+	assert.Equal(t, http.StatusAccepted, response.Code)
+	assert.Len(t, queue.pending, 1)
+	assert.Equal(t, 10, *queue.pending[0].Instances)
+
+	// Task definitions that fail validation should be rejected.
+	invalidTasks := `[{"name": "dgemm", "cpu": 3.0, "ram": 32, "image": "", "inst": 10}]`
+	response = serve(handler, http.MethodPost, "/tasks", invalidTasks)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Len(t, queue.pending, 1)
+
+	// Malformed JSON should be rejected.
+	response = serve(handler, http.MethodPost, "/tasks", "{")
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+}
+
+func TestListTasks(t *testing.T) {
+	instances := 5
+	queue := &testTaskQueue{
+		pending: []def.Task{{Name: "minife", Instances: &instances}},
+		running: []schedulers.RunningTask{{TaskID: "electron-minife-6", SlaveID: "agent-1", Hostname: "host1"}},
+	}
+	response := serve(NewHandler(queue), http.MethodGet, "/tasks", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	var tasks struct {
+		Pending []def.Task               `json:"pending"`
+		Running []schedulers.RunningTask `json:"running"`
+	}
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&tasks))
+	assert.Len(t, tasks.Pending, 1)
+	assert.Equal(t, "minife", tasks.Pending[0].Name)
+	assert.Equal(t, 5, *tasks.Pending[0].Instances)
+	assert.Equal(t, queue.running, tasks.Running)
+}
+
+func TestCancelPendingInstances(t *testing.T) {
+	instances := 5
+	queue := &testTaskQueue{pending: []def.Task{{Name: "minife", Instances: &instances}}}
+	handler := NewHandler(queue)
+
+	response := serve(handler, http.MethodDelete, "/tasks/minife?instances=2", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, 3, instances)
+
+	response = serve(handler, http.MethodDelete, "/tasks/minife?instances=zero", "")
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	// Cancelling all the remaining instances.
+	response = serve(handler, http.MethodDelete, "/tasks/minife", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Empty(t, queue.pending)
+
+	response = serve(handler, http.MethodDelete, "/tasks/minife", "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestKillInstance(t *testing.T) {
+	queue := &testTaskQueue{running: []schedulers.RunningTask{
+		{TaskID: "electron-minife-1", SlaveID: "agent1", Hostname: "host1"},
+	}}
+	handler := NewHandler(queue)
+
+	response := serve(handler, http.MethodGet, "/instances/electron-minife-1", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, response.Code)
+
+	response = serve(handler, http.MethodDelete, "/instances/electron-minife-1", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Empty(t, queue.running)
+
+	response = serve(handler, http.MethodDelete, "/instances/electron-minife-1", "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestKillTask(t *testing.T) {
+	instances := 2
+	queue := &testTaskQueue{
+		pending: []def.Task{{Name: "minife", Instances: &instances}},
+		running: []schedulers.RunningTask{
+			{TaskID: "electron-minife-3", SlaveID: "agent1", Hostname: "host1"},
+			{TaskID: "electron-dgemm-1", SlaveID: "agent1", Hostname: "host1"},
+		},
+	}
+	handler := NewHandler(queue)
+
+	response := serve(handler, http.MethodDelete, "/tasks/minife?kill=true&instances=1", "")
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	response = serve(handler, http.MethodDelete, "/tasks/minife?kill=true", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	var killed struct {
+		Cancelled int `json:"cancelled"`
+		Killed    int `json:"killed"`
+	}
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &killed))
+	assert.Equal(t, 2, killed.Cancelled)
+	assert.Equal(t, 1, killed.Killed)
+	assert.Empty(t, queue.pending)
+	assert.Equal(t, "electron-dgemm-1", queue.running[0].TaskID)
+
+	response = serve(handler, http.MethodDelete, "/tasks/minife?kill=true", "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestShutdown(t *testing.T) {
+	queue := &testTaskQueue{}
+	handler := NewHandler(queue)
+
+	response := serve(handler, http.MethodGet, "/drain", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, response.Code)
+	assert.Empty(t, queue.shutdown)
+
+	response = serve(handler, http.MethodPost, "/drain", "")
+	assert.Equal(t, http.StatusAccepted, response.Code)
+	assert.Equal(t, "drain", queue.shutdown)
+
+	response = serve(handler, http.MethodPost, "/stop", "")
+	assert.Equal(t, http.StatusAccepted, response.Code)
+	assert.Equal(t, "stop", queue.shutdown)
+}
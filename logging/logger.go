@@ -134,6 +134,9 @@ func BuildLogger(prefix string, logConfigFilename string) error {
 		spsLog := newSchedPolicySwitchLogger(config, b, SPS, prefix, logger, logDir)
 		schedWindowLog := newSchedWindowLogger(config, b, SCHED_WINDOW, prefix, logger, logDir)
 		tskDistLog := newClsfnTaskDistrOverheadLogger(config, b, CLSFN_TASKDISTR_OVERHEAD, prefix, logger, logDir)
+		tskRetryLog := newTaskRetryLogger(config, b, TASK_RETRY, prefix, logger, logDir)
+		tskWatchdogLog := newTaskWatchdogLogger(config, b, TASK_WATCHDOG, prefix, logger, logDir)
+		tskReportLog := newTaskReportLogger(config, b, TASK_REPORT, prefix, logger, logDir)
 
 		head.setNext(cLog)
 		cLog.setNext(pLog)
@@ -141,6 +144,9 @@ func BuildLogger(prefix string, logConfigFilename string) error {
 		schedTraceLog.setNext(spsLog)
 		spsLog.setNext(schedWindowLog)
 		schedWindowLog.setNext(tskDistLog)
+		tskDistLog.setNext(tskRetryLog)
+		tskRetryLog.setNext(tskWatchdogLog)
+		tskWatchdogLog.setNext(tskReportLog)
 
 	}
 
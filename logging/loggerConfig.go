@@ -63,6 +63,24 @@ type loggerConfig struct {
 		AllowOnConsole    bool   `yaml:"allowOnConsole"`
 	} `yaml:"schedWindow"`
 
+	TaskRetryConfig struct {
+		Enabled           bool   `yaml:"enabled"`
+		FilenameExtension string `yaml:"filenameExtension"`
+		AllowOnConsole    bool   `yaml:"allowOnConsole"`
+	} `yaml:"taskRetry"`
+
+	TaskWatchdogConfig struct {
+		Enabled           bool   `yaml:"enabled"`
+		FilenameExtension string `yaml:"filenameExtension"`
+		AllowOnConsole    bool   `yaml:"allowOnConsole"`
+	} `yaml:"taskWatchdog"`
+
+	TaskReportConfig struct {
+		Enabled           bool   `yaml:"enabled"`
+		FilenameExtension string `yaml:"filenameExtension"`
+		AllowOnConsole    bool   `yaml:"allowOnConsole"`
+	} `yaml:"taskReport"`
+
 	Format []string `yaml:"format"`
 }
 
@@ -0,0 +1,176 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package logging
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Logger of the end-of-run report of the task instances.
+// The report is logged as a JSON array of records, and is written both as JSON and as CSV.
+// The columns of the CSV file are the keys of the records, in sorted order.
+type taskReportLogger struct {
+	baseElektronLogger
+	csvFile *os.File
+}
+
+func newTaskReportLogger(
+	config *loggerConfig,
+	b *baseLogData,
+	logType int,
+	prefix string,
+	logger *log.Logger,
+	logDir *logDirectory) *taskReportLogger {
+
+	sLog := &taskReportLogger{
+		baseElektronLogger: baseElektronLogger{
+			baseLogData: b,
+			config: struct {
+				Enabled           bool
+				FilenameExtension string
+				AllowOnConsole    bool
+			}{
+				Enabled:           config.TaskReportConfig.Enabled,
+				FilenameExtension: config.TaskReportConfig.FilenameExtension,
+				AllowOnConsole:    config.TaskReportConfig.AllowOnConsole,
+			},
+			logType: logType,
+			next:    nil,
+			logger:  logger,
+			logDir:  logDir,
+		},
+	}
+
+	sLog.createLogFile(prefix)
+	return sLog
+}
+
+func (sLog taskReportLogger) Log(logType int, level log.Level, message string) {
+	if sLog.logType == logType {
+		if sLog.isEnabled() {
+			if sLog.isAllowedOnConsole() {
+				sLog.logger.SetOutput(os.Stdout)
+				sLog.logger.WithFields(sLog.data).Log(level, message)
+			}
+
+			if err := sLog.writeReport(message); err != nil {
+				log.Println("Unable to write task report: ", err)
+			}
+		}
+	}
+	// Forwarding to next logger
+	if sLog.next != nil {
+		sLog.next.Log(logType, level, message)
+	} else {
+		// Clearing the fields.
+		sLog.resetFields()
+	}
+}
+
+func (sLog taskReportLogger) Logf(logType int, level log.Level, msgFmtString string, args ...interface{}) {
+	sLog.Log(logType, level, fmt.Sprintf(msgFmtString, args...))
+}
+
+// Write the report to the JSON and CSV files.
+func (sLog taskReportLogger) writeReport(report string) error {
+	if (sLog.logFile == nil) || (sLog.csvFile == nil) {
+		return nil
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(report), "", "\t"); err != nil {
+		return errors.Wrap(err, "report is not valid JSON")
+	}
+	indented.WriteString("\n")
+	if _, err := sLog.logFile.Write(indented.Bytes()); err != nil {
+		return err
+	}
+
+	rows, err := reportToCSV(report)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(sLog.csvFile)
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Convert the JSON array of records into CSV rows, the first of which is the header.
+func reportToCSV(report string) ([][]string, error) {
+	decoder := json.NewDecoder(strings.NewReader(report))
+	decoder.UseNumber()
+	var records []map[string]interface{}
+	if err := decoder.Decode(&records); err != nil {
+		return nil, errors.Wrap(err, "report is not a JSON array of records")
+	}
+
+	columnSet := make(map[string]bool)
+	for _, record := range records {
+		for column := range record {
+			columnSet[column] = true
+		}
+	}
+	columns := make([]string, 0, len(columnSet))
+	for column := range columnSet {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	rows := [][]string{columns}
+	for _, record := range records {
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			if value, ok := record[column]; ok && (value != nil) {
+				row[i] = fmt.Sprint(value)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (sLog *taskReportLogger) createLogFile(prefix string) {
+	if sLog.isEnabled() {
+		filename := strings.Join([]string{prefix, sLog.getFilenameExtension()}, "")
+		dirName := sLog.logDir.getDirName()
+		if dirName != "" {
+			if logFile, err := os.Create(filepath.Join(dirName, filename+".json")); err != nil {
+				log.Fatal("Unable to create logFile: ", err)
+			} else {
+				sLog.logFile = logFile
+			}
+			if csvFile, err := os.Create(filepath.Join(dirName, filename+".csv")); err != nil {
+				log.Fatal("Unable to create logFile: ", err)
+			} else {
+				sLog.csvFile = csvFile
+			}
+		}
+	}
+}
@@ -25,4 +25,7 @@ const (
 	SPS
 	SCHED_WINDOW
 	CLSFN_TASKDISTR_OVERHEAD
+	TASK_RETRY
+	TASK_WATCHDOG
+	TASK_REPORT
 )
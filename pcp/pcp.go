@@ -19,68 +19,66 @@
 package pcp
 
 import (
-	"bufio"
 	"os/exec"
 	"syscall"
 	"time"
 
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	elekLog "github.com/spdfg/elektron/logging"
 	. "github.com/spdfg/elektron/logging/types"
 )
 
-func Start(quit chan struct{}, logging *bool, pcpConfigFile string) {
+// NewPMDumpTextStream starts pmdumptext to record the metrics in the given config file every second,
+// and returns the stream of samples that it records.
+// pmdumptext is stopped 5 seconds after quit is closed, ending the stream.
+func NewPMDumpTextStream(quit chan struct{}, pcpConfigFile string) (*Stream, error) {
 	var pcpCommand string = "pmdumptext -m -l -f '' -t 1.0 -d , -c " + pcpConfigFile
 	cmd := exec.Command("sh", "-c", pcpCommand)
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
 	pipe, err := cmd.StdoutPipe()
 	if err != nil {
-		log.Fatal(err)
+		return nil, errors.Wrap(err, "Failed to read output of pmdumptext")
 	}
-	//cmd.Stdout = stdout
-
-	scanner := bufio.NewScanner(pipe)
-
-	go func(logging *bool) {
-		// Get names of the columns.
-		scanner.Scan()
-
-		// Write to logfile
-		elekLog.Log(PCP, log.InfoLevel, scanner.Text())
-
-		// Throw away first set of results
-		scanner.Scan()
-
-		seconds := 0
-
-		for scanner.Scan() {
-			text := scanner.Text()
-
-			if *logging {
-				elekLog.Log(PCP, log.InfoLevel, text)
-			}
-
-			seconds++
-		}
-	}(logging)
-
-	elekLog.Log(CONSOLE, log.InfoLevel, "PCP logging started")
 
 	if err := cmd.Start(); err != nil {
-		log.Fatal(err)
+		return nil, errors.Wrap(err, "Failed to start pmdumptext")
 	}
 
 	pgid, err := syscall.Getpgid(cmd.Process.Pid)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get process group of pmdumptext")
+	}
 
-	select {
-	case <-quit:
+	go func() {
+		<-quit
 		elekLog.Log(CONSOLE, log.InfoLevel, "Stopping PCP logging in 5 seconds")
 		time.Sleep(5 * time.Second)
 
 		// http://stackoverflow.com/questions/22470193/why-wont-go-kill-a-child-process-correctly
 		// Kill process and all children processes.
 		syscall.Kill(-pgid, 15)
-		return
+	}()
+
+	return &Stream{reader: pipe, discardFirst: true}, nil
+}
+
+// Log writes the samples to the PCP log.
+// Samples are only logged once logging has been turned on.
+func Log(samples <-chan Sample, logging *bool) {
+	elekLog.Log(CONSOLE, log.InfoLevel, "PCP logging started")
+
+	headerLogged := false
+	for sample := range samples {
+		if !headerLogged {
+			// Write names of the columns to logfile.
+			elekLog.Log(PCP, log.InfoLevel, sample.Header)
+			headerLogged = true
+		}
+
+		if *logging {
+			elekLog.Log(PCP, log.InfoLevel, sample.Line)
+		}
 	}
 }
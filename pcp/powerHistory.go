@@ -0,0 +1,89 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package pcp
+
+import (
+	"container/ring"
+	"sort"
+)
+
+// PowerHistory keeps track of the recent power consumption of the hosts and the cluster.
+type PowerHistory struct {
+	hosts   map[string]*ring.Ring
+	cluster *ring.Ring
+}
+
+func NewPowerHistory() *PowerHistory {
+	return &PowerHistory{
+		hosts:   make(map[string]*ring.Ring),
+		cluster: ring.New(5),
+	}
+}
+
+// Record the sample and return the average power consumption of the cluster.
+func (h *PowerHistory) Record(sample Sample) float64 {
+	for host, hostSample := range sample.Hosts {
+		raplWatts := hostSample.RAPLWatts()
+		if len(raplWatts) == 0 {
+			continue
+		}
+		// Only create one ring per host.
+		if _, ok := h.hosts[host]; !ok {
+			// Two PKGS, two DRAM per node, 20 = 5 seconds of tracking.
+			h.hosts[host] = ring.New(20)
+		}
+		for _, power := range raplWatts {
+			h.hosts[host].Value = power
+			h.hosts[host] = h.hosts[host].Next()
+		}
+	}
+
+	h.cluster.Value = sample.Watts()
+	h.cluster = h.cluster.Next()
+	return AverageClusterPowerHistory(h.cluster)
+}
+
+// HostWatts returns the average power consumption of the host, and whether the power consumption
+// of the host has been recorded.
+func (h *PowerHistory) HostWatts(host string) (float64, bool) {
+	history, ok := h.hosts[host]
+	if !ok {
+		return 0.0, false
+	}
+	return AverageNodePowerHistory(history), true
+}
+
+// Victims returns the hosts in non-increasing order of their average power consumption.
+func (h *PowerHistory) Victims() []Victim {
+	hosts := make([]string, 0, len(h.hosts))
+	for host := range h.hosts {
+		hosts = append(hosts, host)
+	}
+	// Sorting the hostnames first, so that ties are broken consistently.
+	sort.Strings(hosts)
+
+	victims := make([]Victim, 0, len(hosts))
+	for _, host := range hosts {
+		victims = append(victims, Victim{Watts: AverageNodePowerHistory(h.hosts[host]), Host: host})
+	}
+	sort.SliceStable(victims, func(i, j int) bool {
+		return victims[i].Watts > victims[j].Watts
+	})
+	return victims
+}
@@ -0,0 +1,42 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package pcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPowerHistory(t *testing.T) {
+	history := NewPowerHistory()
+	sample := Sample{Hosts: map[string]HostSample{
+		"host1": {PKGWatts: []float64{10.0, 10.0}, DRAMWatts: []float64{5.0, 5.0}},
+		"host2": {PKGWatts: []float64{20.0, 20.0}, DRAMWatts: []float64{5.0, 5.0}},
+	}}
+	assert.Equal(t, 80.0, history.Record(sample))
+
+	watts, ok := history.HostWatts("host1")
+	assert.True(t, ok)
+	assert.Equal(t, 30.0, watts)
+	_, ok = history.HostWatts("host3")
+	assert.False(t, ok, "power of unmonitored host reported")
+
+	assert.Equal(t, []Victim{{Watts: 50.0, Host: "host2"}, {Watts: 30.0, Host: "host1"}}, history.Victims())
+}
@@ -0,0 +1,339 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package pcp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Metrics that record the energy consumed by the RAPL domains of a host.
+const (
+	raplPKGMetric  = "RAPL_ENERGY_PKG"
+	raplDRAMMetric = "RAPL_ENERGY_DRAM"
+)
+
+// Number of samples that can be buffered for each subscriber.
+// Samples are dropped for subscribers that fall further behind, unless they are lossless.
+const subscriberBufferSize = 10
+
+// Prefix added to every line of a pcplog file by the PCP logger, along with the
+// format of the timestamp in the prefix.
+var pcplogPrefix = regexp.MustCompile(`^\[[A-Z]+\]: (\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) `)
+
+const pcplogTimestampFormat = "2006-01-02 15:04:05"
+
+// HostSample contains the metrics recorded for a host at an instant.
+type HostSample struct {
+	// Power consumed by each RAPL package domain of the host, in watts.
+	PKGWatts []float64
+	// Power consumed by each RAPL DRAM domain of the host, in watts.
+	DRAMWatts []float64
+	// Value of every numeric metric recorded for the host, keyed by metric name.
+	Metrics map[string]float64
+}
+
+// Watts returns the total power consumed by the host.
+func (h HostSample) Watts() float64 {
+	watts := 0.0
+	for _, pkgWatts := range h.PKGWatts {
+		watts += pkgWatts
+	}
+	for _, dramWatts := range h.DRAMWatts {
+		watts += dramWatts
+	}
+	return watts
+}
+
+// RAPLWatts returns the power consumed by each RAPL domain of the host, in watts.
+// The package domains are followed by the DRAM domains.
+func (h HostSample) RAPLWatts() []float64 {
+	raplWatts := make([]float64, 0, len(h.PKGWatts)+len(h.DRAMWatts))
+	raplWatts = append(raplWatts, h.PKGWatts...)
+	return append(raplWatts, h.DRAMWatts...)
+}
+
+// Sample contains the metrics recorded for all the hosts at an instant.
+type Sample struct {
+	// Time at which the sample was logged, if read from a pcplog file. Zero otherwise.
+	Time time.Time
+	// Names of the columns of the CSV output of pmdumptext.
+	Header string
+	// Line of the CSV output of pmdumptext from which this sample was parsed.
+	Line string
+	// Metrics recorded for each host, keyed by hostname.
+	Hosts map[string]HostSample
+}
+
+// Watts returns the total power consumed by the cluster.
+func (s Sample) Watts() float64 {
+	watts := 0.0
+	for _, hostSample := range s.Hosts {
+		watts += hostSample.Watts()
+	}
+	return watts
+}
+
+// Column of the CSV output of pmdumptext.
+type column struct {
+	host   string
+	metric string
+}
+
+// Stream parses the CSV output of pmdumptext into samples and publishes them to its subscribers.
+// Both the output of pmdumptext and the pcplog files written by the PCP logger can be parsed.
+type Stream struct {
+	reader io.Reader
+	// pmdumptext reports garbage values in the first set of results, which need to be discarded.
+	discardFirst bool
+
+	mutex       sync.Mutex
+	subscribers []*subscriber
+}
+
+// Subscriber to the samples of a stream.
+type subscriber struct {
+	samples chan Sample
+	// Samples to be delivered to a lossless subscriber, which are queued for as long as it lags behind.
+	// Nil if samples are dropped for the subscriber when it lags behind.
+	queue chan Sample
+	// Number of samples dropped for the subscriber, and whether the latest sample was dropped.
+	dropped int
+	lagging bool
+}
+
+// NewStream returns a stream of the samples read from the given reader.
+func NewStream(reader io.Reader) *Stream {
+	return &Stream{reader: reader}
+}
+
+// Subscribe returns a channel on which all the samples of the stream are received.
+// The channel is closed once the stream ends.
+// Subscribers need to subscribe before the stream is run. The stream does not wait for slow
+// subscribers, and so samples are dropped for subscribers that have not received the last
+// subscriberBufferSize samples. This is only for subscribers that can miss samples, such as the power
+// capping policies; see SubscribeLossless otherwise.
+func (s *Stream) Subscribe() <-chan Sample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sub := &subscriber{samples: make(chan Sample, subscriberBufferSize)}
+	s.subscribers = append(s.subscribers, sub)
+	return sub.samples
+}
+
+// SubscribeLossless returns a channel on which all the samples of the stream are received, however far
+// behind the subscriber falls. This is for subscribers that cannot miss any sample, such as the PCP
+// logger. The samples that the subscriber is yet to receive are queued in memory, and so the stream
+// is not held up either.
+// The channel is closed once the stream ends and all the queued samples have been received.
+func (s *Stream) SubscribeLossless() <-chan Sample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sub := &subscriber{samples: make(chan Sample, subscriberBufferSize), queue: make(chan Sample)}
+	s.subscribers = append(s.subscribers, sub)
+	go forward(sub.queue, sub.samples)
+	return sub.samples
+}
+
+// Forward the samples received on in to out, queueing the samples that out is not ready to receive.
+// out is closed once in is closed and all the queued samples have been forwarded.
+func forward(in <-chan Sample, out chan<- Sample) {
+	var queued []Sample
+	for (in != nil) || (len(queued) > 0) {
+		var next chan<- Sample
+		var sample Sample
+		if len(queued) > 0 {
+			next = out
+			sample = queued[0]
+		}
+		select {
+		case received, ok := <-in:
+			if !ok {
+				in = nil
+				continue
+			}
+			queued = append(queued, received)
+		case next <- sample:
+			queued = queued[1:]
+		}
+	}
+	close(out)
+}
+
+// Dropped returns the number of samples that have been dropped for the subscribers that lagged behind.
+func (s *Stream) Dropped() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	dropped := 0
+	for _, sub := range s.subscribers {
+		dropped += sub.dropped
+	}
+	return dropped
+}
+
+// Run reads samples until the end of the stream and publishes them to the subscribers.
+func (s *Stream) Run() error {
+	defer s.close()
+	return s.read(s.publish)
+}
+
+// ReadAll reads all the samples of the stream, without publishing them to the subscribers.
+// Unlike subscribers, no sample is dropped, however long the samples take to be processed.
+func (s *Stream) ReadAll() ([]Sample, error) {
+	var samples []Sample
+	err := s.read(func(sample Sample) {
+		samples = append(samples, sample)
+	})
+	return samples, err
+}
+
+// Read samples until the end of the stream, handling each of them in turn.
+func (s *Stream) read(handle func(Sample)) error {
+	scanner := bufio.NewScanner(s.reader)
+	// Get names of the columns.
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return errors.Wrap(err, "Failed to read header")
+		}
+		return errors.New("empty stream")
+	}
+	header, _ := trimLine(scanner.Text())
+	columns := parseHeader(header)
+
+	if s.discardFirst {
+		// Throw away first set of results.
+		scanner.Scan()
+	}
+
+	for scanner.Scan() {
+		line, loggedAt := trimLine(scanner.Text())
+		if line == "" {
+			continue
+		}
+		sample, err := parseSample(columns, line)
+		if err != nil {
+			// Malformed samples are discarded.
+			continue
+		}
+		sample.Header = header
+		sample.Time = loggedAt
+		handle(sample)
+	}
+	return errors.Wrap(scanner.Err(), "Failed to read samples")
+}
+
+func (s *Stream) publish(sample Sample) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, sub := range s.subscribers {
+		if sub.queue != nil {
+			// Received right away, as the samples are queued.
+			sub.queue <- sample
+			continue
+		}
+		select {
+		case sub.samples <- sample:
+			sub.lagging = false
+		default:
+			// The subscriber is lagging behind, and does not hold up the other subscribers.
+			sub.dropped++
+			if !sub.lagging {
+				log.Printf("PCP subscriber lagging behind, dropping samples (%d dropped so far)", sub.dropped)
+			}
+			sub.lagging = true
+		}
+	}
+}
+
+func (s *Stream) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, sub := range s.subscribers {
+		if sub.queue != nil {
+			close(sub.queue)
+		} else {
+			close(sub.samples)
+		}
+	}
+}
+
+// Remove the prefix added by the PCP logger, if any, and return the time at which the line was logged.
+func trimLine(line string) (string, time.Time) {
+	var loggedAt time.Time
+	if prefix := pcplogPrefix.FindStringSubmatch(line); prefix != nil {
+		loggedAt, _ = time.ParseInLocation(pcplogTimestampFormat, prefix[1], time.Local)
+		line = line[len(prefix[0]):]
+	}
+	return strings.TrimSpace(line), loggedAt
+}
+
+func parseHeader(header string) []column {
+	var columns []column
+	for _, hostMetric := range strings.Split(header, ",") {
+		metricSplit := strings.SplitN(hostMetric, ":", 2)
+		if len(metricSplit) != 2 {
+			columns = append(columns, column{})
+			continue
+		}
+		columns = append(columns, column{host: metricSplit[0], metric: metricSplit[1]})
+	}
+	return columns
+}
+
+func parseSample(columns []column, line string) (Sample, error) {
+	values := strings.Split(line, ",")
+	if len(values) != len(columns) {
+		return Sample{}, errors.New(fmt.Sprintf("expected %d values, but found %d", len(columns), len(values)))
+	}
+
+	sample := Sample{Line: line, Hosts: make(map[string]HostSample)}
+	for i, col := range columns {
+		if col.host == "" {
+			continue
+		}
+		hostSample, ok := sample.Hosts[col.host]
+		if !ok {
+			hostSample = HostSample{Metrics: make(map[string]float64)}
+		}
+
+		sample.Hosts[col.host] = hostSample
+		// pmdumptext reports missing values as '?', which are not recorded.
+		value, err := strconv.ParseFloat(strings.TrimSpace(values[i]), 64)
+		if err != nil {
+			continue
+		}
+		hostSample.Metrics[col.metric] = value
+		if strings.Contains(col.metric, raplPKGMetric) {
+			hostSample.PKGWatts = append(hostSample.PKGWatts, value*RAPLUnits)
+		} else if strings.Contains(col.metric, raplDRAMMetric) {
+			hostSample.DRAMWatts = append(hostSample.DRAMWatts, value*RAPLUnits)
+		}
+		sample.Hosts[col.host] = hostSample
+	}
+	return sample, nil
+}
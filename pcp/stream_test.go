@@ -0,0 +1,168 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package pcp
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 2^32 RAPL units correspond to 1 watt.
+const pcplog = `[INFO]: 2018-09-14 10:00:00 host1:kernel.all.load[5],host1:perfevent.hwcounters.rapl__RAPL_ENERGY_PKG.value,host1:perfevent.hwcounters.rapl__RAPL_ENERGY_DRAM.value,host2:kernel.all.load[5],host2:perfevent.hwcounters.rapl__RAPL_ENERGY_PKG.value  
+[INFO]: 2018-09-14 10:00:01 0.5,429496729600,42949672960,?,214748364800  
+[INFO]: 2018-09-14 10:00:02 0.75,858993459200,42949672960,1.0  
+[INFO]: 2018-09-14 10:00:03 1.0,429496729600,85899345920,2.0,429496729600  
+`
+
+func TestStream_Run(t *testing.T) {
+	stream := NewStream(strings.NewReader(pcplog))
+	subscribers := []<-chan Sample{stream.Subscribe(), stream.Subscribe()}
+
+	// Every subscriber receives every sample.
+	received := make([][]Sample, len(subscribers))
+	var wg sync.WaitGroup
+	for i, subscriber := range subscribers {
+		wg.Add(1)
+		go func(i int, subscriber <-chan Sample) {
+			defer wg.Done()
+			for sample := range subscriber {
+				received[i] = append(received[i], sample)
+			}
+		}(i, subscriber)
+	}
+	assert.NoError(t, stream.Run())
+	wg.Wait()
+	assert.Equal(t, received[0], received[1])
+
+	// The malformed sample is discarded.
+	samples := received[0]
+	assert.Len(t, samples, 2)
+
+	first := samples[0]
+	assert.True(t, strings.HasPrefix(first.Header, "host1:kernel.all.load[5],"))
+	assert.Equal(t, "0.5,429496729600,42949672960,?,214748364800", first.Line)
+	assert.Equal(t, []float64{100.0}, first.Hosts["host1"].PKGWatts)
+	assert.Equal(t, []float64{10.0}, first.Hosts["host1"].DRAMWatts)
+	assert.Equal(t, 110.0, first.Hosts["host1"].Watts())
+	assert.Equal(t, 0.5, first.Hosts["host1"].Metrics["kernel.all.load[5]"])
+	// Missing values are not recorded.
+	assert.NotContains(t, first.Hosts["host2"].Metrics, "kernel.all.load[5]")
+	assert.Equal(t, 50.0, first.Hosts["host2"].Watts())
+	assert.Equal(t, 160.0, first.Watts())
+
+	second := samples[1]
+	assert.Equal(t, []float64{100.0, 20.0}, second.Hosts["host1"].RAPLWatts())
+	assert.Equal(t, 220.0, second.Watts())
+}
+
+func TestStream_RunDiscardFirst(t *testing.T) {
+	output := "host1:perfevent.hwcounters.rapl__RAPL_ENERGY_PKG.value\n" +
+		"1.0\n" +
+		"429496729600\n"
+	stream := &Stream{reader: strings.NewReader(output), discardFirst: true}
+	subscriber := stream.Subscribe()
+	assert.NoError(t, stream.Run())
+
+	sample, ok := <-subscriber
+	assert.True(t, ok)
+	assert.Equal(t, 100.0, sample.Watts())
+	_, ok = <-subscriber
+	assert.False(t, ok, "stream not closed")
+}
+
+func TestStream_ReadAll(t *testing.T) {
+	samples, err := NewStream(strings.NewReader(pcplog)).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, samples, 2)
+	assert.Equal(t, 160.0, samples[0].Watts())
+	assert.Equal(t, 220.0, samples[1].Watts())
+
+	_, err = NewStream(strings.NewReader("")).ReadAll()
+	assert.Error(t, err)
+}
+
+func TestStream_RunEmpty(t *testing.T) {
+	stream := NewStream(strings.NewReader(""))
+	subscriber := stream.Subscribe()
+	assert.Error(t, stream.Run())
+	_, ok := <-subscriber
+	assert.False(t, ok, "stream not closed")
+}
+
+func TestStream_RunMissingRAPLValues(t *testing.T) {
+	output := "host1:perfevent.hwcounters.rapl__RAPL_ENERGY_PKG.value,host1:perfevent.hwcounters.rapl__RAPL_ENERGY_DRAM.value\n" +
+		"?,42949672960\n"
+	stream := NewStream(strings.NewReader(output))
+	subscriber := stream.Subscribe()
+	assert.NoError(t, stream.Run())
+
+	// Missing values are not counted as 0 watts.
+	sample := <-subscriber
+	assert.Empty(t, sample.Hosts["host1"].PKGWatts)
+	assert.Equal(t, []float64{10.0}, sample.Hosts["host1"].DRAMWatts)
+	assert.Equal(t, 10.0, sample.Watts())
+}
+
+func TestStream_RunStalledSubscriber(t *testing.T) {
+	reader, writer := io.Pipe()
+	stream := NewStream(reader)
+	stalled := stream.Subscribe()
+	stalledLossless := stream.SubscribeLossless()
+	active := stream.Subscribe()
+
+	ran := make(chan error)
+	go func() {
+		ran <- stream.Run()
+	}()
+	fmt.Fprintln(writer, "host1:perfevent.hwcounters.rapl__RAPL_ENERGY_PKG.value")
+	// The active subscriber keeps receiving samples while the other subscribers do not receive any.
+	for i := 0; i < 3*subscriberBufferSize; i++ {
+		fmt.Fprintln(writer, "429496729600")
+		select {
+		case sample := <-active:
+			assert.Equal(t, 100.0, sample.Watts())
+		case <-time.After(5 * time.Second):
+			t.Fatal("stream held up by a stalled subscriber")
+		}
+	}
+	writer.Close()
+	assert.NoError(t, <-ran)
+
+	// Samples beyond those buffered are dropped for the stalled subscriber.
+	received := 0
+	for range stalled {
+		received++
+	}
+	assert.Equal(t, subscriberBufferSize, received)
+	assert.Equal(t, 2*subscriberBufferSize, stream.Dropped())
+
+	// No sample is dropped for the lossless subscriber.
+	received = 0
+	for sample := range stalledLossless {
+		assert.Equal(t, 100.0, sample.Watts())
+		received++
+	}
+	assert.Equal(t, 3*subscriberBufferSize, received)
+}
@@ -0,0 +1,192 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package powerCap
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	elekLog "github.com/spdfg/elektron/logging"
+	. "github.com/spdfg/elektron/logging/types"
+	"github.com/spdfg/elektron/pcp"
+	"github.com/spdfg/elektron/rapl"
+)
+
+// Kinds of power capping actions.
+const (
+	// Cap a host that is not capped.
+	Cap = "cap"
+	// Cap an already capped host to a lower value.
+	FurtherCap = "further-cap"
+	// Raise the cap value of a capped host.
+	Uncap = "uncap"
+)
+
+// CapAction is a decision to change the power cap of a host.
+type CapAction struct {
+	Kind string
+	Host string
+	// Power cap as a percentage of the maximum power of the host.
+	Percentage float64
+}
+
+// Policy decides which hosts to power cap or uncap, based on the power consumption reported by PCP.
+// Policies only keep track of the capped states of the hosts, and do not cap hosts themselves.
+type Policy interface {
+	// Record the sample and decide the power capping actions to take, and return the actions that were applied.
+	// Candidate actions are applied in turn using apply. The capped states of the hosts are only updated
+	// once an action has been applied successfully, and the next candidate is tried if it could not be applied.
+	Step(sample pcp.Sample, apply ApplyFunc) []CapAction
+}
+
+// ApplyFunc applies a power capping action, and returns an error if it could not be applied.
+type ApplyFunc func(action CapAction) error
+
+// CapStates keeps track of the power caps that have been applied to the hosts, so that they
+// can be looked up while power capping is running.
+type CapStates struct {
+	mutex sync.RWMutex
+	// Power cap of each capped host, as a percentage of the maximum power of the host.
+	percentages map[string]float64
+}
+
+func NewCapStates() *CapStates {
+	return &CapStates{percentages: make(map[string]float64)}
+}
+
+// Record that the power capping action has been applied.
+func (c *CapStates) Record(action CapAction) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if action.Percentage >= 100.0 {
+		delete(c.percentages, action.Host)
+	} else {
+		c.percentages[action.Host] = action.Percentage
+	}
+}
+
+// Capped returns whether the host is currently power capped.
+func (c *CapStates) Capped(host string) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	_, ok := c.percentages[host]
+	return ok
+}
+
+// Percentage returns the power cap of the host, as a percentage of the maximum power of the host.
+// Hosts that are not capped have a power cap of 100%.
+func (c *CapStates) Percentage(host string) float64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if percentage, ok := c.percentages[host]; ok {
+		return percentage
+	}
+	return 100.0
+}
+
+// Start running the power capping policy on the PCP samples, capping hosts using the given capper.
+// The power caps that are applied are recorded in the given cap states, if any.
+// Power capping only starts once logging has been turned on.
+func Start(policy Policy, samples <-chan pcp.Sample, logging *bool, capper rapl.Capper, states *CapStates) {
+	for sample := range samples {
+		if !*logging {
+			continue
+		}
+
+		for host, hostSample := range sample.Hosts {
+			for _, power := range hostSample.RAPLWatts() {
+				elekLog.WithFields(log.Fields{
+					"Host":  host,
+					"Power": fmt.Sprintf("%f", power),
+				}).Log(CONSOLE, log.InfoLevel, "")
+			}
+		}
+		elekLog.WithField("Total power", fmt.Sprintf("%f", sample.Watts())).Log(CONSOLE, log.InfoLevel, "")
+
+		policy.Step(sample, func(action CapAction) error {
+			if err := capper.Cap(action.Host, action.Percentage); err != nil {
+				elekLog.WithField("Error", err.Error()).Logf(CONSOLE, log.ErrorLevel,
+					"Error capping host %s", action.Host)
+				return err
+			}
+			elekLog.WithField("Action", action.Kind).Logf(CONSOLE, log.InfoLevel,
+				"Capped host[%s] at %f", action.Host, action.Percentage)
+			if states != nil {
+				states.Record(action)
+			}
+			return nil
+		})
+	}
+}
+
+// TimelineEntry is a power capping action taken when replaying a pcplog.
+type TimelineEntry struct {
+	// Index of the sample for which the action was taken.
+	Sample int
+	// Time at which the sample was logged.
+	Time time.Time
+	CapAction
+}
+
+// Replay runs the power capping policy over the samples in a pcplog, and returns the
+// timeline of power capping actions that would have been taken.
+func Replay(policy Policy, pcplog io.Reader) ([]TimelineEntry, error) {
+	// Every sample is replayed, however long the policy takes to decide.
+	samples, err := pcp.NewStream(pcplog).ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to replay pcplog")
+	}
+
+	var timeline []TimelineEntry
+	for index, sample := range samples {
+		// Every action would have been applied successfully.
+		applied := func(CapAction) error { return nil }
+		for _, action := range policy.Step(sample, applied) {
+			timeline = append(timeline, TimelineEntry{Sample: index, Time: sample.Time, CapAction: action})
+		}
+	}
+	return timeline, nil
+}
+
+// WriteTimeline writes the timeline of power capping actions in CSV format.
+func WriteTimeline(w io.Writer, timeline []TimelineEntry) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"sample", "time", "action", "host", "percentage"})
+	for _, entry := range timeline {
+		loggedAt := ""
+		if !entry.Time.IsZero() {
+			loggedAt = entry.Time.Format("2006-01-02 15:04:05")
+		}
+		writer.Write([]string{
+			strconv.Itoa(entry.Sample),
+			loggedAt,
+			entry.Kind,
+			entry.Host,
+			strconv.FormatFloat(entry.Percentage, 'f', 2, 64),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,175 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package powerCap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/spdfg/elektron/pcp"
+	"github.com/stretchr/testify/assert"
+)
+
+// Sample in which each host consumes the given power in its RAPL package domain.
+func sample(hostWatts map[string]float64) pcp.Sample {
+	s := pcp.Sample{Hosts: make(map[string]pcp.HostSample)}
+	for host, watts := range hostWatts {
+		s.Hosts[host] = pcp.HostSample{PKGWatts: []float64{watts}}
+	}
+	return s
+}
+
+// Applies every power capping action successfully.
+func applied(CapAction) error {
+	return nil
+}
+
+// Fails to apply the power capping actions of the given hosts.
+func failingOn(hosts ...string) ApplyFunc {
+	return func(action CapAction) error {
+		for _, host := range hosts {
+			if action.Host == host {
+				return errors.New("failed to cap " + host)
+			}
+		}
+		return nil
+	}
+}
+
+func TestExtrema_Step(t *testing.T) {
+	extrema := NewExtrema(100.0, 50.0)
+	high := sample(map[string]float64{"host1": 80.0, "host2": 40.0})
+	low := sample(map[string]float64{"host1": 0.0, "host2": 0.0})
+
+	// Hosts are capped one at a time, in non-increasing order of power consumption.
+	assert.Equal(t, []CapAction{{Kind: Cap, Host: "host1", Percentage: 50.0}}, extrema.Step(high, applied))
+	assert.Equal(t, []CapAction{{Kind: Cap, Host: "host2", Percentage: 50.0}}, extrema.Step(high, applied))
+	assert.Empty(t, extrema.Step(high, applied), "capped an already capped host")
+
+	// Average cluster power is 90 and 72, which is within the power envelope.
+	assert.Empty(t, extrema.Step(low, applied))
+	assert.Empty(t, extrema.Step(low, applied))
+	// Hosts are uncapped in the reverse order in which they were capped.
+	assert.Equal(t, []CapAction{{Kind: Uncap, Host: "host2", Percentage: 100.0}}, extrema.Step(low, applied))
+	assert.Equal(t, []CapAction{{Kind: Uncap, Host: "host1", Percentage: 100.0}}, extrema.Step(low, applied))
+	assert.Empty(t, extrema.Step(low, applied))
+}
+
+func TestProgressiveExtrema_Step(t *testing.T) {
+	progExtrema := NewProgressiveExtrema(100.0, 50.0, 12.5)
+	high := sample(map[string]float64{"host1": 80.0, "host2": 40.0})
+	low := sample(map[string]float64{"host1": 0.0, "host2": 0.0})
+
+	assert.Equal(t, []CapAction{{Kind: Cap, Host: "host1", Percentage: 50.0}}, progExtrema.Step(high, applied))
+	assert.Equal(t, []CapAction{{Kind: Cap, Host: "host2", Percentage: 50.0}}, progExtrema.Step(high, applied))
+	// Already capped hosts are capped further, until the lower cap limit is reached.
+	assert.Equal(t, []CapAction{{Kind: FurtherCap, Host: "host1", Percentage: 25.0}}, progExtrema.Step(high, applied))
+	assert.Equal(t, []CapAction{{Kind: FurtherCap, Host: "host1", Percentage: 12.5}}, progExtrema.Step(high, applied))
+	assert.Equal(t, []CapAction{{Kind: FurtherCap, Host: "host2", Percentage: 25.0}}, progExtrema.Step(high, applied))
+	assert.Equal(t, []CapAction{{Kind: FurtherCap, Host: "host2", Percentage: 12.5}}, progExtrema.Step(high, applied))
+	assert.Empty(t, progExtrema.Step(high, applied), "capped below the lower cap limit")
+
+	// Average cluster power only falls below the low threshold once 3 of the 5 recorded samples are low.
+	assert.Empty(t, progExtrema.Step(low, applied))
+	assert.Empty(t, progExtrema.Step(low, applied))
+	// The host that is capped the most is uncapped first, with ties broken by hostname.
+	assert.Equal(t, []CapAction{{Kind: Uncap, Host: "host1", Percentage: 25.0}}, progExtrema.Step(low, applied))
+	assert.Equal(t, []CapAction{{Kind: Uncap, Host: "host2", Percentage: 25.0}}, progExtrema.Step(low, applied))
+	assert.Equal(t, []CapAction{{Kind: Uncap, Host: "host1", Percentage: 50.0}}, progExtrema.Step(low, applied))
+	assert.Equal(t, []CapAction{{Kind: Uncap, Host: "host2", Percentage: 50.0}}, progExtrema.Step(low, applied))
+	assert.Equal(t, []CapAction{{Kind: Uncap, Host: "host1", Percentage: 100.0}}, progExtrema.Step(low, applied))
+	assert.Equal(t, []CapAction{{Kind: Uncap, Host: "host2", Percentage: 100.0}}, progExtrema.Step(low, applied))
+	assert.Empty(t, progExtrema.Step(low, applied))
+}
+
+func TestExtrema_StepCapFailure(t *testing.T) {
+	extrema := NewExtrema(100.0, 50.0)
+	high := sample(map[string]float64{"host1": 80.0, "host2": 40.0})
+	low := sample(map[string]float64{"host1": 0.0, "host2": 0.0})
+
+	// The next victim is capped if the best victim could not be capped.
+	assert.Equal(t, []CapAction{{Kind: Cap, Host: "host2", Percentage: 50.0}}, extrema.Step(high, failingOn("host1")))
+	// The host that could not be capped is not considered to be capped.
+	assert.Equal(t, []CapAction{{Kind: Cap, Host: "host1", Percentage: 50.0}}, extrema.Step(high, applied))
+	assert.Empty(t, extrema.Step(high, failingOn("host1", "host2")))
+
+	assert.Empty(t, extrema.Step(low, applied))
+	assert.Empty(t, extrema.Step(low, applied))
+	// A host that could not be uncapped remains capped.
+	assert.Empty(t, extrema.Step(low, failingOn("host1")))
+	assert.Equal(t, []CapAction{{Kind: Uncap, Host: "host1", Percentage: 100.0}}, extrema.Step(low, applied))
+	assert.Equal(t, []CapAction{{Kind: Uncap, Host: "host2", Percentage: 100.0}}, extrema.Step(low, applied))
+}
+
+func TestProgressiveExtrema_StepCapFailure(t *testing.T) {
+	progExtrema := NewProgressiveExtrema(100.0, 50.0, 12.5)
+	high := sample(map[string]float64{"host1": 80.0, "host2": 40.0})
+	low := sample(map[string]float64{"host1": 0.0, "host2": 0.0})
+
+	assert.Equal(t, []CapAction{{Kind: Cap, Host: "host2", Percentage: 50.0}}, progExtrema.Step(high, failingOn("host1")))
+	assert.Equal(t, []CapAction{{Kind: Cap, Host: "host1", Percentage: 50.0}}, progExtrema.Step(high, applied))
+	// The next capped host is capped further if the best one could not be.
+	assert.Equal(t, []CapAction{{Kind: FurtherCap, Host: "host2", Percentage: 25.0}},
+		progExtrema.Step(high, failingOn("host1")))
+	assert.Equal(t, []CapAction{{Kind: FurtherCap, Host: "host1", Percentage: 25.0}}, progExtrema.Step(high, applied))
+	assert.Empty(t, progExtrema.Step(high, failingOn("host1", "host2")))
+
+	assert.Empty(t, progExtrema.Step(low, applied))
+	assert.Empty(t, progExtrema.Step(low, applied))
+	// A host that could not be uncapped keeps its cap value.
+	assert.Empty(t, progExtrema.Step(low, failingOn("host1")))
+	assert.Equal(t, []CapAction{{Kind: Uncap, Host: "host1", Percentage: 50.0}}, progExtrema.Step(low, applied))
+}
+
+func TestReplay(t *testing.T) {
+	// 2^32 RAPL units correspond to 1 watt.
+	pcplog := `[INFO]: 2018-09-14 10:00:00 host1:perfevent.hwcounters.rapl__RAPL_ENERGY_PKG.value,host2:perfevent.hwcounters.rapl__RAPL_ENERGY_PKG.value  
+[INFO]: 2018-09-14 10:00:01 343597383680,171798691840  
+[INFO]: 2018-09-14 10:00:02 343597383680,171798691840  
+[INFO]: 2018-09-14 10:00:03 0,0  
+`
+	timeline, err := Replay(NewExtrema(100.0, 50.0), strings.NewReader(pcplog))
+	assert.NoError(t, err)
+	assert.Len(t, timeline, 2)
+	assert.Equal(t, 0, timeline[0].Sample)
+	assert.Equal(t, CapAction{Kind: Cap, Host: "host1", Percentage: 50.0}, timeline[0].CapAction)
+	assert.Equal(t, 1, timeline[1].Sample)
+	assert.Equal(t, CapAction{Kind: Cap, Host: "host2", Percentage: 50.0}, timeline[1].CapAction)
+
+	var output bytes.Buffer
+	assert.NoError(t, WriteTimeline(&output, timeline))
+	assert.Equal(t, "sample,time,action,host,percentage\n"+
+		"0,2018-09-14 10:00:01,cap,host1,50.00\n"+
+		"1,2018-09-14 10:00:02,cap,host2,50.00\n", output.String())
+}
+
+func TestCapStates(t *testing.T) {
+	states := NewCapStates()
+	states.Record(CapAction{Kind: Cap, Host: "host1", Percentage: 50.0})
+	states.Record(CapAction{Kind: FurtherCap, Host: "host1", Percentage: 25.0})
+	assert.True(t, states.Capped("host1"))
+	assert.Equal(t, 25.0, states.Percentage("host1"))
+	assert.False(t, states.Capped("host2"))
+	assert.Equal(t, 100.0, states.Percentage("host2"))
+
+	states.Record(CapAction{Kind: Uncap, Host: "host1", Percentage: 100.0})
+	assert.False(t, states.Capped("host1"))
+}
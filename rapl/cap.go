@@ -19,43 +19,232 @@
 package rapl
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	elekEnv "github.com/spdfg/elektron/environment"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
-func Cap(host, username string, percentage float64) error {
+// Default user and port used to connect to hosts.
+const (
+	defaultSSHUser = "rapl"
+	defaultSSHPort = 22
+)
+
+// Time to wait to establish a connection to a host.
+const sshDialTimeout = 10 * time.Second
+
+// SSHHostConfig is the configuration used to connect to a host and run the RAPL throttle script.
+type SSHHostConfig struct {
+	User string `json:"user"`
+	Port int    `json:"port"`
+	// Location of the RAPL throttle script on the host.
+	Script string `json:"script"`
+}
+
+// SSHCapperConfig is the configuration of an SSHCapper.
+type SSHCapperConfig struct {
+	// Configuration used for all hosts. Unset fields default to the user "rapl", port 22
+	// and the script location in the RAPL_PKG_THROTTLE_SCRIPT_LOCATION environment variable.
+	Default SSHHostConfig `json:"default"`
+	// Configuration for specific hosts, keyed by hostname. Unset fields default to those in Default.
+	Hosts map[string]SSHHostConfig `json:"hosts"`
+	// Private keys used for authentication.
+	PrivateKeyFiles []string `json:"privateKeyFiles"`
+	// Whether to use the keys of the ssh-agent listening on SSH_AUTH_SOCK for authentication.
+	UseAgent bool `json:"useAgent"`
+	// known_hosts files used to verify the host keys. Defaults to ~/.ssh/known_hosts.
+	KnownHostsFiles []string `json:"knownHostsFiles"`
+}
+
+// SSHCapperConfigFromJSON reads the SSHCapper configuration from the given file.
+func SSHCapperConfigFromJSON(uri string) (SSHCapperConfig, error) {
+	var config SSHCapperConfig
+	file, err := os.Open(uri)
+	if err != nil {
+		return config, errors.Wrap(err, "Error opening file")
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(&config); err != nil {
+		return config, errors.Wrap(err, "Error unmarshalling")
+	}
+	return config, nil
+}
+
+// SSHCapper caps hosts by running the RAPL throttle script on them over SSH.
+// Host keys are verified using known_hosts files. Connections to hosts are reused
+// across power capping decisions.
+type SSHCapper struct {
+	config    SSHCapperConfig
+	sshConfig *ssh.ClientConfig
+	agentConn net.Conn
 
-	if percentage > 100 || percentage < 0 {
-		return errors.New("Percentage is out of range")
+	// Guards the pool of connections.
+	mutex   sync.Mutex
+	clients map[string]*ssh.Client
+}
+
+// NewSSHCapper loads the private keys and known_hosts files in the given configuration,
+// and connects to the ssh-agent if required.
+// Password authentication is not supported.
+func NewSSHCapper(config SSHCapperConfig) (*SSHCapper, error) {
+	c := &SSHCapper{config: config, clients: make(map[string]*ssh.Client)}
+
+	var signers []ssh.Signer
+	for _, keyFile := range config.PrivateKeyFiles {
+		key, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to read private key")
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Failed to parse private key %s", keyFile))
+		}
+		signers = append(signers, signer)
 	}
 
-	sshConfig := &ssh.ClientConfig{
-		User: username,
-		Auth: []ssh.AuthMethod{
-			// TODO: CHANGE and MAKE THIS USE SSH KEY!!!!
-			ssh.Password(os.Getenv(elekEnv.RaplPassword)),
-		},
-		// TODO Do not allow accepting any host key.
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	var auth []ssh.AuthMethod
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+	if config.UseAgent {
+		conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to connect to ssh-agent")
+		}
+		c.agentConn = conn
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+	if len(auth) == 0 {
+		return nil, errors.New("no SSH authentication method configured")
 	}
 
-	connection, err := ssh.Dial("tcp", host+":22", sshConfig)
+	knownHostsFiles := config.KnownHostsFiles
+	if len(knownHostsFiles) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to locate known_hosts")
+		}
+		knownHostsFiles = []string{filepath.Join(home, ".ssh", "known_hosts")}
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFiles...)
 	if err != nil {
-		return errors.Wrap(err, "Failed to dial")
+		return nil, errors.Wrap(err, "Failed to load known_hosts")
 	}
 
-	session, err := connection.NewSession()
-	defer session.Close()
+	c.sshConfig = &ssh.ClientConfig{
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sshDialTimeout,
+	}
+	return c, nil
+}
+
+// Configuration to use for the given host.
+func (c *SSHCapper) hostConfig(host string) SSHHostConfig {
+	config := c.config.Default
+	if hostConfig, ok := c.config.Hosts[host]; ok {
+		if hostConfig.User != "" {
+			config.User = hostConfig.User
+		}
+		if hostConfig.Port != 0 {
+			config.Port = hostConfig.Port
+		}
+		if hostConfig.Script != "" {
+			config.Script = hostConfig.Script
+		}
+	}
+	if config.User == "" {
+		config.User = defaultSSHUser
+	}
+	if config.Port == 0 {
+		config.Port = defaultSSHPort
+	}
+	if config.Script == "" {
+		config.Script = os.Getenv(elekEnv.RaplThrottleScriptLocation)
+	}
+	return config
+}
+
+// Retrieve the connection to the host from the pool, connecting to the host if needed.
+// The pool is not locked while connecting, so that an unreachable host does not hold up the caps
+// of the other hosts.
+func (c *SSHCapper) client(host string, config SSHHostConfig) (*ssh.Client, error) {
+	c.mutex.Lock()
+	client, ok := c.clients[host]
+	c.mutex.Unlock()
+	if ok {
+		return client, nil
+	}
+
+	sshConfig := *c.sshConfig
+	sshConfig.User = config.User
+	client, err := ssh.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(config.Port)), &sshConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to dial")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// Another cap of the host might have connected to it in the meantime.
+	if pooled, ok := c.clients[host]; ok {
+		client.Close()
+		return pooled, nil
+	}
+	c.clients[host] = client
+	return client, nil
+}
+
+// Remove the connection to the host from the pool and close it.
+func (c *SSHCapper) discard(host string, client *ssh.Client) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.clients[host] == client {
+		delete(c.clients, host)
+	}
+	client.Close()
+}
+
+func (c *SSHCapper) Cap(host string, percentage float64) error {
+
+	if err := validatePercentage(percentage); err != nil {
+		return err
+	}
+
+	config := c.hostConfig(host)
+	client, err := c.client(host, config)
 	if err != nil {
-		return errors.Wrap(err, "Failed to create session")
+		return err
 	}
 
-	err = session.Run(strings.Join([]string{"sudo", os.Getenv(elekEnv.RaplThrottleScriptLocation),
+	session, err := client.NewSession()
+	if err != nil {
+		// The connection might have been closed by the host. Reconnecting.
+		c.discard(host, client)
+		if client, err = c.client(host, config); err != nil {
+			return err
+		}
+		if session, err = client.NewSession(); err != nil {
+			c.discard(host, client)
+			return errors.Wrap(err, "Failed to create session")
+		}
+	}
+	defer session.Close()
+
+	err = session.Run(strings.Join([]string{"sudo", config.Script,
 		strconv.FormatFloat(percentage, 'f', 2, 64)}, " "))
 	if err != nil {
 		return errors.Wrap(err, "Failed to run RAPL script")
@@ -63,3 +252,17 @@ func Cap(host, username string, percentage float64) error {
 
 	return nil
 }
+
+// Close the connections to all the hosts.
+func (c *SSHCapper) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for host, client := range c.clients {
+		client.Close()
+		delete(c.clients, host)
+	}
+	if c.agentConn != nil {
+		return c.agentConn.Close()
+	}
+	return nil
+}
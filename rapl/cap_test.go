@@ -0,0 +1,303 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package rapl
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// In-process SSH server that accepts connections authenticated using the given public key,
+// and records the commands that are run.
+type testSSHServer struct {
+	listener net.Listener
+	hostKey  ssh.Signer
+
+	mutex    sync.Mutex
+	conns    int
+	users    []string
+	commands []string
+}
+
+func newTestSSHServer(t *testing.T, authorizedKey ssh.PublicKey) *testSSHServer {
+	s := &testSSHServer{hostKey: newSigner(t)}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), authorizedKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unauthorized key")
+		},
+	}
+	config.AddHostKey(s.hostKey)
+
+	var err error
+	s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() {
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config)
+		}
+	}()
+	return s
+}
+
+func (s *testSSHServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	s.mutex.Lock()
+	s.conns++
+	s.users = append(s.users, serverConn.User())
+	s.mutex.Unlock()
+
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for request := range channelRequests {
+				if request.Type != "exec" {
+					request.Reply(false, nil)
+					continue
+				}
+				var payload struct{ Command string }
+				ssh.Unmarshal(request.Payload, &payload)
+				s.mutex.Lock()
+				s.commands = append(s.commands, payload.Command)
+				s.mutex.Unlock()
+				request.Reply(true, nil)
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				return
+			}
+		}()
+	}
+}
+
+func (s *testSSHServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func newSigner(t *testing.T) ssh.Signer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	assert.NoError(t, err)
+	return signer
+}
+
+// Write a new private key to the given directory, and return its location and public key.
+func writePrivateKey(t *testing.T, dir string) (string, ssh.PublicKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	keyFile := filepath.Join(dir, "id_ecdsa")
+	assert.NoError(t, ioutil.WriteFile(keyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+	publicKey, err := ssh.NewPublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+	return keyFile, publicKey
+}
+
+func writeKnownHosts(t *testing.T, dir string, port int, hostKey ssh.PublicKey) string {
+	knownHostsFile := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize("127.0.0.1:" + strconv.Itoa(port))}, hostKey)
+	assert.NoError(t, ioutil.WriteFile(knownHostsFile, []byte(line+"\n"), 0600))
+	return knownHostsFile
+}
+
+func TestSSHCapper_Cap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sshCapper")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyFile, publicKey := writePrivateKey(t, dir)
+	server := newTestSSHServer(t, publicKey)
+	defer server.listener.Close()
+
+	capper, err := NewSSHCapper(SSHCapperConfig{
+		Default: SSHHostConfig{Script: "/opt/rapl/throttle.py"},
+		Hosts: map[string]SSHHostConfig{
+			"127.0.0.1": {User: "powercap", Port: server.port()},
+		},
+		PrivateKeyFiles: []string{keyFile},
+		KnownHostsFiles: []string{writeKnownHosts(t, dir, server.port(), server.hostKey.PublicKey())},
+	})
+	assert.NoError(t, err)
+	defer capper.Close()
+
+	assert.NoError(t, capper.Cap("127.0.0.1", 50.0))
+	assert.NoError(t, capper.Cap("127.0.0.1", 100.0))
+	assert.Error(t, capper.Cap("127.0.0.1", 150.0))
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	// The connection to the host is reused.
+	assert.Equal(t, 1, server.conns)
+	assert.Equal(t, []string{"powercap"}, server.users)
+	assert.Equal(t, []string{"sudo /opt/rapl/throttle.py 50.00", "sudo /opt/rapl/throttle.py 100.00"},
+		server.commands)
+}
+
+func TestSSHCapper_CapUnknownHostKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sshCapper")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyFile, publicKey := writePrivateKey(t, dir)
+	server := newTestSSHServer(t, publicKey)
+	defer server.listener.Close()
+
+	// known_hosts contains a different host key for the host.
+	capper, err := NewSSHCapper(SSHCapperConfig{
+		Default:         SSHHostConfig{Port: server.port()},
+		PrivateKeyFiles: []string{keyFile},
+		KnownHostsFiles: []string{writeKnownHosts(t, dir, server.port(), newSigner(t).PublicKey())},
+	})
+	assert.NoError(t, err)
+	defer capper.Close()
+
+	assert.Error(t, capper.Cap("127.0.0.1", 50.0))
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	assert.Empty(t, server.commands)
+}
+
+func TestSSHCapper_CapUnreachableHost(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sshCapper")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyFile, publicKey := writePrivateKey(t, dir)
+	server := newTestSSHServer(t, publicKey)
+	defer server.listener.Close()
+
+	// Host that accepts connections, but never completes the SSH handshake.
+	stalled, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	var stalledConns []net.Conn
+	var stalledMutex sync.Mutex
+	go func() {
+		for {
+			conn, err := stalled.Accept()
+			if err != nil {
+				return
+			}
+			stalledMutex.Lock()
+			stalledConns = append(stalledConns, conn)
+			stalledMutex.Unlock()
+		}
+	}()
+
+	capper, err := NewSSHCapper(SSHCapperConfig{
+		Default: SSHHostConfig{Script: "/opt/rapl/throttle.py"},
+		Hosts: map[string]SSHHostConfig{
+			"127.0.0.1": {Port: server.port()},
+			"localhost": {Port: stalled.Addr().(*net.TCPAddr).Port},
+		},
+		PrivateKeyFiles: []string{keyFile},
+		KnownHostsFiles: []string{writeKnownHosts(t, dir, server.port(), server.hostKey.PublicKey())},
+	})
+	assert.NoError(t, err)
+	defer capper.Close()
+
+	stalledCap := make(chan error)
+	go func() {
+		stalledCap <- capper.Cap("localhost", 50.0)
+	}()
+	// Waiting for the connection to the unreachable host to be attempted.
+	for connected := false; !connected; {
+		stalledMutex.Lock()
+		connected = len(stalledConns) > 0
+		stalledMutex.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Caps of other hosts are not held up.
+	capped := make(chan error)
+	go func() {
+		capped <- capper.Cap("127.0.0.1", 50.0)
+	}()
+	select {
+	case err := <-capped:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("cap held up by an unreachable host")
+	}
+
+	// Releasing the cap of the unreachable host.
+	stalled.Close()
+	stalledMutex.Lock()
+	for _, conn := range stalledConns {
+		conn.Close()
+	}
+	stalledMutex.Unlock()
+	assert.Error(t, <-stalledCap)
+}
+
+func TestSSHCapper_HostConfig(t *testing.T) {
+	capper := &SSHCapper{config: SSHCapperConfig{
+		Default: SSHHostConfig{User: "admin", Script: "/opt/rapl/throttle.py"},
+		Hosts: map[string]SSHHostConfig{
+			"host1": {Port: 2222, Script: "/usr/local/bin/throttle.py"},
+		},
+	}}
+	assert.Equal(t, SSHHostConfig{User: "admin", Port: 2222, Script: "/usr/local/bin/throttle.py"},
+		capper.hostConfig("host1"))
+	assert.Equal(t, SSHHostConfig{User: "admin", Port: 22, Script: "/opt/rapl/throttle.py"},
+		capper.hostConfig("host2"))
+}
+
+func TestNewSSHCapper_NoPasswordAuth(t *testing.T) {
+	// The password of earlier deployments is not used to authenticate.
+	os.Setenv("RAPL_PSSWD", "secret")
+	defer os.Unsetenv("RAPL_PSSWD")
+	_, err := NewSSHCapper(SSHCapperConfig{})
+	assert.Error(t, err)
+}
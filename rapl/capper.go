@@ -0,0 +1,76 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package rapl
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Capper sets the RAPL power cap of a host.
+// The power cap is specified as a percentage of the maximum power of the host.
+type Capper interface {
+	Cap(host string, percentage float64) error
+}
+
+func validatePercentage(percentage float64) error {
+	if percentage > 100 || percentage < 0 {
+		return errors.New("Percentage is out of range")
+	}
+	return nil
+}
+
+// CapRecord is a power cap that was requested from a RecordingCapper.
+type CapRecord struct {
+	Time       time.Time
+	Host       string
+	Percentage float64
+}
+
+// RecordingCapper does not cap any host, but records the power caps that were requested.
+// To be used for dry runs.
+type RecordingCapper struct {
+	mutex   sync.Mutex
+	records []CapRecord
+}
+
+func NewRecordingCapper() *RecordingCapper {
+	return &RecordingCapper{}
+}
+
+func (c *RecordingCapper) Cap(host string, percentage float64) error {
+	if err := validatePercentage(percentage); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.records = append(c.records, CapRecord{Time: time.Now(), Host: host, Percentage: percentage})
+	return nil
+}
+
+// Records returns the power caps that have been requested, in order.
+func (c *RecordingCapper) Records() []CapRecord {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	records := make([]CapRecord, len(c.records))
+	copy(records, c.records)
+	return records
+}
@@ -0,0 +1,96 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package rapl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Create a fake powercap tree with the given constraint files and their contents.
+func fakePowercapTree(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "powercap")
+	assert.NoError(t, err)
+	for name, contents := range files {
+		path := filepath.Join(root, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+	return root
+}
+
+func readFile(t *testing.T, path string) string {
+	contents, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	return string(contents)
+}
+
+func TestSysfsCapper_Cap(t *testing.T) {
+	root := fakePowercapTree(t, map[string]string{
+		"intel-rapl:0/constraint_0_max_power_uw":     "100000000\n",
+		"intel-rapl:0/constraint_0_power_limit_uw":   "100000000\n",
+		"intel-rapl:0/constraint_1_max_power_uw":     "120000000\n",
+		"intel-rapl:0/constraint_1_power_limit_uw":   "120000000\n",
+		"intel-rapl:1/constraint_0_max_power_uw":     "85000001\n",
+		"intel-rapl:1/constraint_0_power_limit_uw":   "85000001\n",
+		"intel-rapl:0:0/constraint_0_max_power_uw":   "50000000\n",
+		"intel-rapl:0:0/constraint_0_power_limit_uw": "50000000\n",
+	})
+	defer os.RemoveAll(root)
+
+	capper, err := NewSysfsCapper(root, "host1.cluster")
+	assert.NoError(t, err)
+	assert.NoError(t, capper.Cap("host1", 50.0))
+	assert.Equal(t, "50000000", readFile(t, filepath.Join(root, "intel-rapl:0/constraint_0_power_limit_uw")))
+	assert.Equal(t, "60000000", readFile(t, filepath.Join(root, "intel-rapl:0/constraint_1_power_limit_uw")))
+	// Power limits are rounded up.
+	assert.Equal(t, "42500001", readFile(t, filepath.Join(root, "intel-rapl:1/constraint_0_power_limit_uw")))
+	// Subzones are not capped.
+	assert.Equal(t, "50000000\n", readFile(t, filepath.Join(root, "intel-rapl:0:0/constraint_0_power_limit_uw")))
+
+	// Uncapping.
+	assert.NoError(t, capper.Cap("host1", 100.0))
+	assert.Equal(t, "100000000", readFile(t, filepath.Join(root, "intel-rapl:0/constraint_0_power_limit_uw")))
+
+	assert.Error(t, capper.Cap("host1", 101.0))
+	// Remote hosts cannot be capped.
+	assert.Error(t, capper.Cap("host2", 50.0))
+	assert.Equal(t, "100000000", readFile(t, filepath.Join(root, "intel-rapl:0/constraint_0_power_limit_uw")))
+	missing, err := NewSysfsCapper(filepath.Join(root, "missing"), "host1")
+	assert.NoError(t, err)
+	assert.Error(t, missing.Cap("host1", 50.0))
+}
+
+func TestRecordingCapper_Cap(t *testing.T) {
+	capper := NewRecordingCapper()
+	assert.NoError(t, capper.Cap("host1", 50.0))
+	assert.NoError(t, capper.Cap("host2", 25.0))
+	assert.Error(t, capper.Cap("host1", -1.0))
+
+	records := capper.Records()
+	assert.Len(t, records, 2)
+	assert.Equal(t, "host1", records[0].Host)
+	assert.Equal(t, 50.0, records[0].Percentage)
+	assert.Equal(t, "host2", records[1].Host)
+	assert.Equal(t, 25.0, records[1].Percentage)
+}
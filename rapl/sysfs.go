@@ -0,0 +1,129 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package rapl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Default location of the powercap framework in sysfs.
+const DefaultPowercapRoot = "/sys/class/powercap"
+
+// SysfsCapper caps the machine that it runs on by writing the power limits
+// of the RAPL package domains using the powercap framework in sysfs.
+// Power limits are set as a percentage of the maximum power of each constraint.
+// As only the local machine can be capped, SysfsCapper is meant for testing and for
+// single node clusters in which the scheduler runs on the only agent.
+type SysfsCapper struct {
+	// Directory in which the intel-rapl:* package domains are present.
+	Root string
+	// Name of the local machine. Caps of any other host are rejected.
+	Hostname string
+}
+
+// NewSysfsCapper returns a SysfsCapper for the powercap framework present in the given
+// directory. If root is empty, then DefaultPowercapRoot is used.
+// If hostname is empty, then the hostname reported by the kernel is used.
+func NewSysfsCapper(root, hostname string) (*SysfsCapper, error) {
+	if root == "" {
+		root = DefaultPowercapRoot
+	}
+	if hostname == "" {
+		var err error
+		if hostname, err = os.Hostname(); err != nil {
+			return nil, errors.Wrap(err, "Failed to get hostname")
+		}
+	}
+	return &SysfsCapper{Root: root, Hostname: hostname}, nil
+}
+
+// Cap sets the power limits of the local machine.
+// An error is returned if host is not the local machine, as sysfs cannot be used to cap other hosts.
+func (c *SysfsCapper) Cap(host string, percentage float64) error {
+	if err := validatePercentage(percentage); err != nil {
+		return err
+	}
+	if !c.isLocal(host) {
+		return errors.New(fmt.Sprintf("cannot cap remote host %s using sysfs on %s", host, c.Hostname))
+	}
+
+	domains, err := filepath.Glob(filepath.Join(c.Root, "intel-rapl:*"))
+	if err != nil {
+		return errors.Wrap(err, "Failed to find RAPL domains")
+	}
+
+	capped := false
+	for _, domain := range domains {
+		// Only package domains (intel-rapl:<package>) are capped. Subzones (intel-rapl:<package>:<subzone>)
+		// are limited by the package domain that they are part of.
+		if strings.Count(filepath.Base(domain), ":") != 1 {
+			continue
+		}
+		limits, err := filepath.Glob(filepath.Join(domain, "constraint_*_power_limit_uw"))
+		if err != nil {
+			return errors.Wrap(err, "Failed to find RAPL constraints")
+		}
+		for _, limit := range limits {
+			maxPower := strings.TrimSuffix(limit, "power_limit_uw") + "max_power_uw"
+			if err := capConstraint(limit, maxPower, percentage); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("Failed to cap host %s", host))
+			}
+			capped = true
+		}
+	}
+
+	if !capped {
+		return errors.New(fmt.Sprintf("no RAPL package domains found in %s", c.Root))
+	}
+	return nil
+}
+
+// Whether the given host is the local machine. Hosts can be referred to by either their fully
+// qualified domain name or their short name.
+func (c *SysfsCapper) isLocal(host string) bool {
+	shortName := func(name string) string {
+		return strings.SplitN(name, ".", 2)[0]
+	}
+	return (host == c.Hostname) || (shortName(host) == shortName(c.Hostname))
+}
+
+func capConstraint(limitFile, maxPowerFile string, percentage float64) error {
+	contents, err := ioutil.ReadFile(maxPowerFile)
+	if err != nil {
+		return errors.Wrap(err, "Failed to read max power")
+	}
+	maxPower, err := strconv.ParseFloat(strings.TrimSpace(string(contents)), 64)
+	if err != nil {
+		return errors.Wrap(err, "Failed to parse max power")
+	}
+
+	limit := int64(math.Ceil(maxPower * percentage / 100.0))
+	if err := ioutil.WriteFile(limitFile, []byte(strconv.FormatInt(limit, 10)), 0644); err != nil {
+		return errors.Wrap(err, "Failed to write power limit")
+	}
+	return nil
+}
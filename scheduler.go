@@ -24,18 +24,24 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/golang/protobuf/proto"
 	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
 	sched "github.com/mesos/mesos-go/api/v0/scheduler"
 	log "github.com/sirupsen/logrus"
+	"github.com/spdfg/elektron/constants"
 	"github.com/spdfg/elektron/def"
+	"github.com/spdfg/elektron/httpServer"
 	elekLog "github.com/spdfg/elektron/logging"
 	. "github.com/spdfg/elektron/logging/types"
 	"github.com/spdfg/elektron/pcp"
 	"github.com/spdfg/elektron/powerCap"
+	"github.com/spdfg/elektron/rapl"
 	"github.com/spdfg/elektron/schedulers"
+	"github.com/spdfg/elektron/simulator"
+	"github.com/spdfg/elektron/store"
 )
 
 var master = flag.String("master", "", "Location of leading Mesos master -- <mesos-master>:<port>")
@@ -45,6 +51,11 @@ var pcpConfigFile = flag.String("pcpConfigFile", "config", "PCP config file name
 	"present in the same directory, then provide path).")
 var pcplogPrefix = flag.String("logPrefix", "", "Prefix for pcplog")
 var powerCapPolicy = flag.String("powercap", "", "Power Capping policy. (default (''), extrema, prog-extrema).")
+var capperName = flag.String("capper", "ssh", "Mechanism used to power cap hosts. (default (ssh), sysfs, dry-run).")
+var sshCapperConfigFile = flag.String("sshConfig", "", "JSON file containing the SSH configuration used to power cap hosts, provided the ssh capper is used.")
+var powercapRoot = flag.String("powercapRoot", rapl.DefaultPowercapRoot, "Location of the powercap framework in sysfs, provided the sysfs capper is used.")
+var lowerCapLimit = flag.Float64("lowerCapLimit", constants.LowerCapLimit, "Cap value (percentage) below which progressive extrema does not cap a host any further.")
+var replayPCPLog = flag.String("replayPCPLog", "", "Replay the pcplog through the power capping policy, and print the timeline of power capping actions that would have been taken.")
 var hiThreshold = flag.Float64("hiThreshold", 0.0, "Upperbound for when we should start capping")
 var loThreshold = flag.Float64("loThreshold", 0.0, "Lowerbound for when we should start uncapping")
 var classMapWatts = flag.Bool("classMapWatts", false, "Enable mapping of watts to power class of node")
@@ -57,6 +68,18 @@ var fixSchedWindow = flag.Bool("fixSchedWindow", false, "Fix the size of the sch
 var schedWindowSize = flag.Int("schedWindowSize", 200, "Size of the scheduling window if fixSchedWindow is set.")
 var schedPolSwitchCriteria = flag.String("schedPolSwitchCriteria", "taskDist", "Scheduling policy switching criteria.")
 var logConfigFilename = flag.String("logConfigFilename", "logConfig.yaml", "Log Configuration file name")
+var simulate = flag.Bool("simulate", false, "Schedule the workload on a simulated cluster instead of using a Mesos master.")
+var clusterFile = flag.String("clusterFile", "", "JSON file containing the description of the simulated cluster, provided simulation is enabled.")
+var httpServerAddr = flag.String("httpServer", "", "Address (<host>:<port>) on which to serve the task submission API. If provided, the framework keeps running to schedule submitted tasks.")
+var stateFile = flag.String("stateFile", "", "File in which the changes to the framework ID and the state of the task queue are journaled. If the file is not empty, then the framework recovers from it instead of loading the workload.")
+var failoverTimeout = flag.Float64("failoverTimeout", 604800, "Number of seconds for which Mesos keeps the tasks of the framework running after the scheduler disconnects, provided a state file is used.")
+var requeueOnAgentLoss = flag.Bool("requeueOnAgentLoss", false, "Re-enqueue the task instances that are lost along with their agent, without counting towards their retries.")
+var energyBudget = flag.Float64("energyBudget", 0.0, "Energy, in joules, that the cluster can consume in every horizon. Required by the energy-budget scheduling policy.")
+var energyBudgetHorizon = flag.Float64("energyBudgetHorizon", 3600, "Number of seconds after which the energy budget is renewed.")
+var offerRanking = flag.String("offerRanking", "", "Comma separated names of the comparators, in order of precedence, by which offers are ranked before being consumed by the scheduling policy ("+strings.Join(schedulers.OfferComparatorNames, ", ")+").")
+var affinityRulesFile = flag.String("affinityRules", "", "Config file that contains the affinity and anti-affinity rules of the workload.")
+var stragglerThreshold = flag.Float64("stragglerThreshold", 2.0, "Multiple of the median runtime of the finished instances of a task past which a running instance of the task is flagged as a straggler. Stragglers are not detected if 0.")
+var ownerWeightsFile = flag.String("ownerWeights", "", "Config file that contains the weight of each owner of tasks. If provided, then resources are shared fairly between the owners.")
 
 // Short hand args
 func init() {
@@ -67,6 +90,11 @@ func init() {
 		" the same directory, then provide path) (shorthand).")
 	flag.StringVar(pcplogPrefix, "p", "", "Prefix for pcplog (shorthand)")
 	flag.StringVar(powerCapPolicy, "pc", "", "Power Capping policy. (default (''), extrema, prog-extrema) (shorthand).")
+	flag.StringVar(capperName, "cpr", "ssh", "Mechanism used to power cap hosts. (default (ssh), sysfs, dry-run) (shorthand).")
+	flag.StringVar(sshCapperConfigFile, "sshCfg", "", "JSON file containing the SSH configuration used to power cap hosts, provided the ssh capper is used (shorthand).")
+	flag.StringVar(powercapRoot, "pcr", rapl.DefaultPowercapRoot, "Location of the powercap framework in sysfs, provided the sysfs capper is used (shorthand).")
+	flag.Float64Var(lowerCapLimit, "lcl", constants.LowerCapLimit, "Cap value (percentage) below which progressive extrema does not cap a host any further (shorthand).")
+	flag.StringVar(replayPCPLog, "rpl", "", "Replay the pcplog through the power capping policy, and print the timeline of power capping actions that would have been taken (shorthand).")
 	flag.Float64Var(hiThreshold, "ht", 700.0, "Upperbound for when we should start capping (shorthand)")
 	flag.Float64Var(loThreshold, "lt", 400.0, "Lowerbound for when we should start uncapping (shorthand)")
 	flag.BoolVar(classMapWatts, "cmw", false, "Enable mapping of watts to power class of node (shorthand)")
@@ -79,6 +107,18 @@ func init() {
 	flag.IntVar(schedWindowSize, "swSize", 200, "Size of the scheduling window if fixSchedWindow is set (shorthand).")
 	flag.StringVar(schedPolSwitchCriteria, "spsCriteria", "taskDist", "Scheduling policy switching criteria (shorthand).")
 	flag.StringVar(logConfigFilename, "lgCfg", "logConfig.yaml", "Log Configuration file name (shorthand).")
+	flag.BoolVar(simulate, "sim", false, "Schedule the workload on a simulated cluster instead of using a Mesos master (shorthand).")
+	flag.StringVar(clusterFile, "cf", "", "JSON file containing the description of the simulated cluster, provided simulation is enabled (shorthand).")
+	flag.StringVar(httpServerAddr, "hs", "", "Address (<host>:<port>) on which to serve the task submission API. If provided, the framework keeps running to schedule submitted tasks (shorthand).")
+	flag.StringVar(stateFile, "sf", "", "File in which the changes to the framework ID and the state of the task queue are journaled. If the file is not empty, then the framework recovers from it instead of loading the workload (shorthand).")
+	flag.Float64Var(failoverTimeout, "fot", 604800, "Number of seconds for which Mesos keeps the tasks of the framework running after the scheduler disconnects, provided a state file is used (shorthand).")
+	flag.BoolVar(requeueOnAgentLoss, "rqal", false, "Re-enqueue the task instances that are lost along with their agent, without counting towards their retries (shorthand).")
+	flag.Float64Var(energyBudget, "eb", 0.0, "Energy, in joules, that the cluster can consume in every horizon. Required by the energy-budget scheduling policy (shorthand).")
+	flag.Float64Var(energyBudgetHorizon, "ebh", 3600, "Number of seconds after which the energy budget is renewed (shorthand).")
+	flag.StringVar(offerRanking, "or", "", "Comma separated names of the comparators, in order of precedence, by which offers are ranked before being consumed by the scheduling policy ("+strings.Join(schedulers.OfferComparatorNames, ", ")+") (shorthand).")
+	flag.StringVar(affinityRulesFile, "ar", "", "Config file that contains the affinity and anti-affinity rules of the workload (shorthand).")
+	flag.Float64Var(stragglerThreshold, "stt", 2.0, "Multiple of the median runtime of the finished instances of a task past which a running instance of the task is flagged as a straggler. Stragglers are not detected if 0 (shorthand).")
+	flag.StringVar(ownerWeightsFile, "ow", "", "Config file that contains the weight of each owner of tasks. If provided, then resources are shared fairly between the owners (shorthand).")
 }
 
 func listAllSchedulingPolicies() {
@@ -89,6 +129,21 @@ func listAllSchedulingPolicies() {
 	}
 }
 
+// Replay the pcplog through the power capping policy and print the timeline of power capping actions.
+func replay(capPolicy powerCap.Policy, pcplogFile string) error {
+	file, err := os.Open(pcplogFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	timeline, err := powerCap.Replay(capPolicy, file)
+	if err != nil {
+		return err
+	}
+	return powerCap.WriteTimeline(os.Stdout, timeline)
+}
+
 func main() {
 	flag.Parse()
 
@@ -155,13 +210,50 @@ func main() {
 		schedOptions = append(schedOptions, schedulers.WithWattsAsAResource(*wattsAsAResource))
 		schedOptions = append(schedOptions, schedulers.WithClassMapWatts(*classMapWatts))
 	}
+	schedOptions = append(schedOptions, schedulers.WithRequeueOnAgentLoss(*requeueOnAgentLoss))
+
+	// Fair sharing of resources between the owners of tasks.
+	if *ownerWeightsFile != "" {
+		ownerWeights, err := schedulers.OwnerWeightsFromJSON(*ownerWeightsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		schedOptions = append(schedOptions, schedulers.WithOwnerWeights(ownerWeights))
+	}
+
+	// Affinity and anti-affinity rules.
+	if *affinityRulesFile != "" {
+		affinityRules, err := def.AffinityRulesFromJSON(*affinityRulesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		schedOptions = append(schedOptions, schedulers.WithAffinityRules(affinityRules))
+	}
+
+	// Ranking of offers.
+	if *offerRanking != "" {
+		schedOptions = append(schedOptions, schedulers.WithOfferRanking(strings.Split(*offerRanking, ",")))
+	}
+
+	// Energy budget of the cluster.
+	if *energyBudget > 0.0 {
+		schedOptions = append(schedOptions, schedulers.WithEnergyBudget(*energyBudget, *energyBudgetHorizon))
+	} else if *schedPolicyName == "energy-budget" {
+		log.Fatal("Energy budget not provided.")
+	}
+
+	// Detection of stragglers.
+	schedOptions = append(schedOptions, schedulers.WithStragglerThreshold(*stragglerThreshold))
+
 	// REQUIRED PARAMETERS.
 	// PCP logging, Power capping and High and Low thresholds.
 	schedOptions = append(schedOptions, schedulers.WithRecordPCP(&recordPCP))
 	schedOptions = append(schedOptions, schedulers.WithPCPLog(pcpLog))
-	var noPowercap bool
 	var extrema bool
 	var progExtrema bool
+	var capPolicy powerCap.Policy
+	var capper rapl.Capper
+	var capStates *powerCap.CapStates
 	var powercapValues map[string]struct{} = map[string]struct{}{
 		"":             {},
 		"extrema":      {},
@@ -173,9 +265,7 @@ func main() {
 		// Indicating which power capping algorithm to use, if any.
 		// The pcp-logging with/without power capping will be run after the
 		// scheduler has been configured.
-		if *powerCapPolicy == "" {
-			noPowercap = true
-		} else {
+		if *powerCapPolicy != "" {
 			if *powerCapPolicy == "extrema" {
 				extrema = true
 			} else if *powerCapPolicy == "prog-extrema" {
@@ -192,34 +282,134 @@ func main() {
 						"threshold.")
 				}
 			}
+			if extrema {
+				capPolicy = powerCap.NewExtrema(*hiThreshold, *loThreshold)
+			} else if progExtrema {
+				capPolicy = powerCap.NewProgressiveExtrema(*hiThreshold, *loThreshold, *lowerCapLimit)
+			}
+		}
+	}
+
+	// Replaying a recorded pcplog through the power capping policy, instead of scheduling.
+	if *replayPCPLog != "" {
+		if capPolicy == nil {
+			log.Fatal("Power capping policy needs to be provided to replay a pcplog.")
+		}
+		if err := replay(capPolicy, *replayPCPLog); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	// Mechanism used to power cap hosts.
+	if capPolicy != nil {
+		switch *capperName {
+		case "ssh":
+			var sshConfig rapl.SSHCapperConfig
+			if *sshCapperConfigFile != "" {
+				var err error
+				if sshConfig, err = rapl.SSHCapperConfigFromJSON(*sshCapperConfigFile); err != nil {
+					log.Fatal(err)
+				}
+			}
+			sshCapper, err := rapl.NewSSHCapper(sshConfig)
+			if err != nil {
+				log.Fatal(err)
+			}
+			capper = sshCapper
+		case "sysfs":
+			sysfsCapper, err := rapl.NewSysfsCapper(*powercapRoot, "")
+			if err != nil {
+				log.Fatal(err)
+			}
+			capper = sysfsCapper
+		case "dry-run":
+			capper = rapl.NewRecordingCapper()
+		default:
+			log.Fatal("Incorrect power capping mechanism specified.")
 		}
+		// Power caps are made available to the scheduling policies.
+		capStates = powerCap.NewCapStates()
+		schedOptions = append(schedOptions, schedulers.WithCapStates(capStates))
+	}
+
+	// Recovering from a restart of the scheduler, if the state of the previous run was persisted.
+	recovering := false
+	var journal *store.FileStore
+	if *stateFile != "" {
+		var err error
+		if journal, err = store.NewFileStore(*stateFile); err != nil {
+			log.Fatal(err)
+		}
+		if journal.Len() > 0 {
+			recovering = true
+			log.Println("Recovering from state file " + *stateFile + ". The workload is not loaded again.")
+		}
+		schedOptions = append(schedOptions, schedulers.WithStore(journal))
 	}
 
 	// Tasks
 	// If httpServer is disabled, then path of file containing workload needs to be provided.
-	if *tasksFile == "" {
-		log.Fatal("Tasks specifications file not provided.")
+	// If httpServer is enabled, then the framework keeps running to schedule the tasks that are submitted.
+	if *httpServerAddr == "" {
+		if (*tasksFile == "") && !recovering {
+			log.Fatal("Tasks specifications file not provided.")
+		}
+	} else {
+		schedOptions = append(schedOptions, schedulers.WithLongRunning(true))
 	}
-	tasks, err := def.TasksFromJSON(*tasksFile)
-	if err != nil || len(tasks) == 0 {
-		log.Fatal(err)
+	var err error
+	if (*tasksFile != "") && !recovering {
+		tasks, err := def.TasksFromJSON(*tasksFile)
+		if err != nil || len(tasks) == 0 {
+			log.Fatal(err)
+		}
+		schedOptions = append(schedOptions, schedulers.WithTasks(tasks))
 	}
-	schedOptions = append(schedOptions, schedulers.WithTasks(tasks))
+
+	// Current time, as kept by the driver.
+	// The simulated driver is created after the scheduler, which reads the time through it.
+	now := time.Now
+	schedOptions = append(schedOptions, schedulers.WithClock(func() time.Time {
+		return now()
+	}))
 
 	// Scheduler.
 	scheduler := schedulers.SchedFactory(schedOptions...)
 
 	// Scheduler driver.
-	driver, err := sched.NewMesosSchedulerDriver(sched.DriverConfig{
-		Master: *master,
-		Framework: &mesos.FrameworkInfo{
+	// If simulation is enabled, then resource offers are generated from the cluster description
+	// instead of being received from a Mesos master.
+	var driver sched.SchedulerDriver
+	if *simulate {
+		if *clusterFile == "" {
+			log.Fatal("Cluster description file not provided.")
+		}
+		cluster, err := simulator.ClusterFromJSON(*clusterFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		simDriver := simulator.NewDriver(scheduler, cluster)
+		driver = simDriver
+		now = simDriver.Time
+	} else {
+		framework := &mesos.FrameworkInfo{
 			Name: proto.String("Elektron"),
 			User: proto.String(""),
-		},
-		Scheduler: scheduler,
-	})
-	if err != nil {
-		log.Fatal(fmt.Sprintf("Unable to create scheduler driver: %s", err))
+		}
+		if *stateFile != "" {
+			// Reconnecting as the same framework, whose tasks keep running until the failover timeout.
+			framework.Id = scheduler.(*schedulers.BaseScheduler).FrameworkID()
+			framework.FailoverTimeout = proto.Float64(*failoverTimeout)
+		}
+		driver, err = sched.NewMesosSchedulerDriver(sched.DriverConfig{
+			Master:    *master,
+			Framework: framework,
+			Scheduler: scheduler,
+		})
+		if err != nil {
+			log.Fatal(fmt.Sprintf("Unable to create scheduler driver: %s", err))
+		}
 	}
 
 	// Checking if prefix contains any special characters.
@@ -232,40 +422,69 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// Starting the task submission API.
+	if *httpServerAddr != "" {
+		go httpServer.Start(*httpServerAddr, scheduler.(httpServer.TaskQueue))
+	}
+
 	// Starting PCP logging.
-	if noPowercap {
-		go pcp.Start(pcpLog, &recordPCP, *pcpConfigFile)
-	} else if extrema {
-		go powerCap.StartPCPLogAndExtremaDynamicCap(pcpLog, &recordPCP, *hiThreshold,
-			*loThreshold, *pcpConfigFile)
-	} else if progExtrema {
-		go powerCap.StartPCPLogAndProgressiveExtremaCap(pcpLog, &recordPCP, *hiThreshold,
-			*loThreshold, *pcpConfigFile)
+	// There are no nodes to monitor when running on a simulated cluster.
+	if *simulate {
+		log.Println("Simulation enabled. PCP logging and power capping are disabled.")
+	} else {
+		// PCP samples are shared by the PCP logger, the scheduler and the power capping policy, if any.
+		stream, err := pcp.NewPMDumpTextStream(pcpLog, *pcpConfigFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// The PCP log is replayed and analysed offline, and so it needs every sample.
+		go pcp.Log(stream.SubscribeLossless(), &recordPCP)
+		go scheduler.(*schedulers.BaseScheduler).MonitorPower(stream.Subscribe())
+		if capPolicy != nil {
+			go powerCap.Start(capPolicy, stream.Subscribe(), &recordPCP, capper, capStates)
+		}
+		go func() {
+			if err := stream.Run(); err != nil {
+				log.Println(err)
+			}
+			if dropped := stream.Dropped(); dropped > 0 {
+				log.Printf("%d PCP samples were dropped for lagging subscribers", dropped)
+			}
+		}()
 	}
 
+	// Watching the runtimes of the launched tasks, to kill the tasks that time out and detect stragglers.
+	go scheduler.(*schedulers.BaseScheduler).Watchdog(time.Second)
+
 	// Take a second between starting PCP log and continuing.
 	time.Sleep(1 * time.Second)
 
-	// Attempt to handle SIGINT to not leave pmdumptext running.
-	// Catch interrupt.
+	// Attempt to handle SIGINT and SIGTERM to not leave pmdumptext running.
+	// SIGINT stops right away, leaving the launched tasks running, whereas SIGTERM drains the scheduler,
+	// waiting for the launched tasks to terminate. Another signal while draining stops right away.
+	// The launched tasks are killed only on request (POST /stop).
 	go func() {
 		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt, os.Kill)
-		s := <-c
-		if s != os.Interrupt {
-			close(pcpLog)
-			return
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		baseScheduler := scheduler.(*schedulers.BaseScheduler)
+		if s := <-c; s == syscall.SIGTERM {
+			log.Println("Received SIGTERM... draining")
+			baseScheduler.Drain()
+			<-c
+			log.Println("Received another signal while draining... stopping")
+		} else {
+			log.Println("Received SIGINT... stopping")
 		}
-
-		log.Println("Received SIGINT... stopping")
-		close(done)
+		baseScheduler.Abort()
 	}()
 
 	go func() {
 
 		// Signals we have scheduled every task we have
+		// When long-running, tasks could be submitted at any time. So, we only stop when interrupted.
 		select {
 		case <-shutdown:
+		case <-done:
 			//case <-time.After(shutdownTimeout):
 		}
 
@@ -278,9 +497,18 @@ func main() {
 			//case <-time.After(shutdownTimeout):
 		}
 
+		// Writing the report of the task instances that have terminated.
+		scheduler.(*schedulers.BaseScheduler).LogTaskReport()
+
 		// Done shutting down
 		driver.Stop(false)
 
+		// The framework has been torn down, and so there is nothing left to recover.
+		if journal != nil {
+			journal.Close()
+			os.Remove(*stateFile)
+		}
+
 	}()
 
 	// Starting the scheduler driver.
@@ -21,34 +21,19 @@ package schedulers
 import (
 	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
 	sched "github.com/mesos/mesos-go/api/v0/scheduler"
-	log "github.com/sirupsen/logrus"
 	"github.com/spdfg/elektron/def"
 	"github.com/spdfg/elektron/utilities/mesosUtils"
 	"github.com/spdfg/elektron/utilities/offerUtils"
 )
 
 // Decides if to take an offer or not
-func (s *MaxGreedyMins) takeOffer(spc SchedPolicyContext, offer *mesos.Offer, task def.Task,
-	totalCPU, totalRAM, totalWatts float64) bool {
+func (s *MaxGreedyMins) takeOffer(spc SchedPolicyContext, offer *mesos.Offer, task def.Task) bool {
 	baseSchedRef := spc.(*BaseScheduler)
-	cpus, mem, watts := offerUtils.OfferAgg(offer)
-
-	//TODO: Insert watts calculation here instead of taking them as a parameter
-
-	wattsConsideration, err := def.WattsToConsider(task, baseSchedRef.classMapWatts, offer)
-	if err != nil {
-		// Error in determining wattsConsideration
-		log.Fatal(err)
-	}
-	if (cpus >= (totalCPU + task.CPU)) && (mem >= (totalRAM + task.RAM)) &&
-		(!baseSchedRef.wattsAsAResource || (watts >= (totalWatts + wattsConsideration))) {
-		return true
-	}
-	return false
+	return !baseSchedRef.violatesConstraints(offer, task) && baseSchedRef.fits(offer, task)
 }
 
 type MaxGreedyMins struct {
-	baseSchedPolicyState
+	BaseSchedPolicyState
 }
 
 // Determine if the remaining space inside of the offer is enough for this
@@ -57,19 +42,12 @@ func (s *MaxGreedyMins) CheckFit(
 	spc SchedPolicyContext,
 	i int,
 	task def.Task,
-	wattsConsideration float64,
-	offer *mesos.Offer,
-	totalCPU *float64,
-	totalRAM *float64,
-	totalWatts *float64) (bool, *mesos.TaskInfo) {
+	offer *mesos.Offer) (bool, *mesos.TaskInfo) {
 
 	baseSchedRef := spc.(*BaseScheduler)
 	// Does the task fit
-	if s.takeOffer(spc, offer, task, *totalCPU, *totalRAM, *totalWatts) {
+	if s.takeOffer(spc, offer, task) {
 
-		*totalWatts += wattsConsideration
-		*totalCPU += task.CPU
-		*totalRAM += task.RAM
 		baseSchedRef.LogCoLocatedTasks(offer.GetSlaveId().GoString())
 
 		taskToSchedule := baseSchedRef.newTask(offer, task)
@@ -79,13 +57,8 @@ func (s *MaxGreedyMins) CheckFit(
 		s.numTasksScheduled++
 
 		if *task.Instances <= 0 {
-			// All instances of task have been scheduled, remove it
-			baseSchedRef.tasks = append(baseSchedRef.tasks[:i], baseSchedRef.tasks[i+1:]...)
-
-			if len(baseSchedRef.tasks) <= 0 {
-				baseSchedRef.LogTerminateScheduler()
-				close(baseSchedRef.Shutdown)
-			}
+			// All instances of task have been scheduled, remove it.
+			baseSchedRef.removeTask(i)
 		}
 
 		return true, taskToSchedule
@@ -97,9 +70,9 @@ func (s *MaxGreedyMins) CheckFit(
 func (s *MaxGreedyMins) ConsumeOffers(spc SchedPolicyContext, driver sched.SchedulerDriver, offers []*mesos.Offer) {
 	baseSchedRef := spc.(*BaseScheduler)
 	if baseSchedRef.schedPolSwitchEnabled {
-		SortNTasks(baseSchedRef.tasks, baseSchedRef.numTasksInSchedWindow, def.SortByWatts)
+		SortNTasks(baseSchedRef.tasks, baseSchedRef.numTasksInSchedWindow, def.ByPriority(def.SortByWatts))
 	} else {
-		def.SortTasks(baseSchedRef.tasks, def.SortByWatts)
+		def.SortTasksBy(baseSchedRef.tasks, def.ByPriority(def.SortByWatts))
 	}
 	baseSchedRef.LogOffersReceived(offers)
 
@@ -117,72 +90,64 @@ func (s *MaxGreedyMins) ConsumeOffers(spc SchedPolicyContext, driver sched.Sched
 		tasks := []*mesos.TaskInfo{}
 
 		offerTaken := false
-		totalWatts := 0.0
-		totalCPU := 0.0
-		totalRAM := 0.0
-
-		// Assumes s.tasks is ordered in non-decreasing median max peak order
-
-		// Attempt to schedule a single instance of the heaviest workload available first
-		// Start from the back until one fits
-		for i := len(baseSchedRef.tasks) - 1; i >= 0; i-- {
-			// If scheduling policy switching enabled, then
-			// stop scheduling if the #baseSchedRef.schedWindowSize tasks have been scheduled.
-			if baseSchedRef.schedPolSwitchEnabled && (s.numTasksScheduled >= baseSchedRef.schedWindowSize) {
-				break // Offers will automatically get declined.
-			}
-			task := baseSchedRef.tasks[i]
-			wattsConsideration, err := def.WattsToConsider(task, baseSchedRef.classMapWatts, offer)
-			if err != nil {
-				// Error in determining wattsConsideration
-				log.Fatal(err)
-			}
-
-			// Don't take offer if it doesn't match our task's host requirement
-			if offerUtils.HostMismatch(*offer.Hostname, task.Host) {
-				continue
-			}
-
-			// TODO: Fix this so index doesn't need to be passed
-			taken, taskToSchedule := s.CheckFit(spc, i, task, wattsConsideration, offer,
-				&totalCPU, &totalRAM, &totalWatts)
-
-			if taken {
-				offerTaken = true
-				tasks = append(tasks, taskToSchedule)
-				break
-			}
-		}
-
-		// Pack the rest of the offer with the smallest tasks
-		for i := 0; i < len(baseSchedRef.tasks); i++ {
-			task := baseSchedRef.tasks[i]
-			wattsConsideration, err := def.WattsToConsider(task, baseSchedRef.classMapWatts, offer)
-			if err != nil {
-				// Error in determining wattsConsideration
-				log.Fatal(err)
-			}
 
-			// Don't take offer if it doesn't match our task's host requirement
-			if offerUtils.HostMismatch(*offer.Hostname, task.Host) {
-				continue
-			}
+		// Assumes s.tasks is ordered by priority, and then in non-decreasing median max peak order.
+		// Tasks of higher priority are scheduled first, and the offer is then packed with tasks of
+		// lower priority.
+		for _, priority := range taskPriorities(baseSchedRef.tasks) {
+			lo, hi := priorityBand(baseSchedRef.tasks, priority)
 
-			for *task.Instances > 0 {
+			// Attempt to schedule a single instance of the heaviest workload available first
+			// Start from the back until one fits
+			for i := hi - 1; i >= lo; i-- {
 				// If scheduling policy switching enabled, then
 				// stop scheduling if the #baseSchedRef.schedWindowSize tasks have been scheduled.
 				if baseSchedRef.schedPolSwitchEnabled && (s.numTasksScheduled >= baseSchedRef.schedWindowSize) {
 					break // Offers will automatically get declined.
 				}
+				task := baseSchedRef.tasks[i]
+
+				// Don't take offer if it doesn't match our task's host requirement
+				// Retries of failed instances also avoid the hosts on which they failed, if required.
+				if offerUtils.HostMismatch(*offer.Hostname, task.Host) || task.AvoidsHost(*offer.Hostname) {
+					continue
+				}
+
 				// TODO: Fix this so index doesn't need to be passed
-				taken, taskToSchedule := s.CheckFit(spc, i, task, wattsConsideration, offer,
-					&totalCPU, &totalRAM, &totalWatts)
+				taken, taskToSchedule := s.CheckFit(spc, i, task, offer)
 
 				if taken {
 					offerTaken = true
 					tasks = append(tasks, taskToSchedule)
-				} else {
-					break // Continue on to next task
+					break
+				}
+			}
+
+			// Pack the rest of the offer with the smallest tasks
+			for i := lo; i < lo+bandSize(baseSchedRef.tasks, priority); i++ {
+				task := baseSchedRef.tasks[i]
+
+				// Don't take offer if it doesn't match our task's host requirement
+				// Retries of failed instances also avoid the hosts on which they failed, if required.
+				if offerUtils.HostMismatch(*offer.Hostname, task.Host) || task.AvoidsHost(*offer.Hostname) {
+					continue
+				}
+
+				for *task.Instances > 0 {
+					// If scheduling policy switching enabled, then
+					// stop scheduling if the #baseSchedRef.schedWindowSize tasks have been scheduled.
+					if baseSchedRef.schedPolSwitchEnabled && (s.numTasksScheduled >= baseSchedRef.schedWindowSize) {
+						break // Offers will automatically get declined.
+					}
+					// TODO: Fix this so index doesn't need to be passed
+					taken, taskToSchedule := s.CheckFit(spc, i, task, offer)
+
+					if taken {
+						offerTaken = true
+						tasks = append(tasks, taskToSchedule)
+					} else {
+						break // Continue on to next task
+					}
 				}
 			}
 		}
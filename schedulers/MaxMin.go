@@ -21,34 +21,19 @@ package schedulers
 import (
 	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
 	sched "github.com/mesos/mesos-go/api/v0/scheduler"
-	log "github.com/sirupsen/logrus"
 	"github.com/spdfg/elektron/def"
 	"github.com/spdfg/elektron/utilities/mesosUtils"
 	"github.com/spdfg/elektron/utilities/offerUtils"
 )
 
 // Decides if to take an offer or not
-func (s *MaxMin) takeOffer(spc SchedPolicyContext, offer *mesos.Offer, task def.Task,
-	totalCPU, totalRAM, totalWatts float64) bool {
+func (s *MaxMin) takeOffer(spc SchedPolicyContext, offer *mesos.Offer, task def.Task) bool {
 	baseSchedRef := spc.(*BaseScheduler)
-	cpus, mem, watts := offerUtils.OfferAgg(offer)
-
-	//TODO: Insert watts calculation here instead of taking them as a parameter
-
-	wattsConsideration, err := def.WattsToConsider(task, baseSchedRef.classMapWatts, offer)
-	if err != nil {
-		// Error in determining wattsConsideration
-		log.Fatal(err)
-	}
-	if (cpus >= (totalCPU + task.CPU)) && (mem >= (totalRAM + task.RAM)) &&
-		(!baseSchedRef.wattsAsAResource || (watts >= (totalWatts + wattsConsideration))) {
-		return true
-	}
-	return false
+	return !baseSchedRef.violatesConstraints(offer, task) && baseSchedRef.fits(offer, task)
 }
 
 type MaxMin struct {
-	baseSchedPolicyState
+	BaseSchedPolicyState
 }
 
 // Determine if the remaining space inside of the offer is enough for this
@@ -57,19 +42,12 @@ func (s *MaxMin) CheckFit(
 	spc SchedPolicyContext,
 	i int,
 	task def.Task,
-	wattsConsideration float64,
-	offer *mesos.Offer,
-	totalCPU *float64,
-	totalRAM *float64,
-	totalWatts *float64) (bool, *mesos.TaskInfo) {
+	offer *mesos.Offer) (bool, *mesos.TaskInfo) {
 
 	baseSchedRef := spc.(*BaseScheduler)
 	// Does the task fit.
-	if s.takeOffer(spc, offer, task, *totalCPU, *totalRAM, *totalWatts) {
+	if s.takeOffer(spc, offer, task) {
 
-		*totalWatts += wattsConsideration
-		*totalCPU += task.CPU
-		*totalRAM += task.RAM
 		baseSchedRef.LogCoLocatedTasks(offer.GetSlaveId().GoString())
 
 		taskToSchedule := baseSchedRef.newTask(offer, task)
@@ -80,12 +58,7 @@ func (s *MaxMin) CheckFit(
 
 		if *task.Instances <= 0 {
 			// All instances of task have been scheduled, remove it.
-			baseSchedRef.tasks = append(baseSchedRef.tasks[:i], baseSchedRef.tasks[i+1:]...)
-
-			if len(baseSchedRef.tasks) <= 0 {
-				baseSchedRef.LogTerminateScheduler()
-				close(baseSchedRef.Shutdown)
-			}
+			baseSchedRef.removeTask(i)
 		}
 
 		return true, taskToSchedule
@@ -96,9 +69,9 @@ func (s *MaxMin) CheckFit(
 func (s *MaxMin) ConsumeOffers(spc SchedPolicyContext, driver sched.SchedulerDriver, offers []*mesos.Offer) {
 	baseSchedRef := spc.(*BaseScheduler)
 	if baseSchedRef.schedPolSwitchEnabled {
-		SortNTasks(baseSchedRef.tasks, baseSchedRef.numTasksInSchedWindow, def.SortByWatts)
+		SortNTasks(baseSchedRef.tasks, baseSchedRef.numTasksInSchedWindow, def.ByPriority(def.SortByWatts))
 	} else {
-		def.SortTasks(baseSchedRef.tasks, def.SortByWatts)
+		def.SortTasksBy(baseSchedRef.tasks, def.ByPriority(def.SortByWatts))
 	}
 	baseSchedRef.LogOffersReceived(offers)
 
@@ -116,64 +89,60 @@ func (s *MaxMin) ConsumeOffers(spc SchedPolicyContext, driver sched.SchedulerDri
 		tasks := []*mesos.TaskInfo{}
 
 		offerTaken := false
-		totalWatts := 0.0
-		totalCPU := 0.0
-		totalRAM := 0.0
-
-		// Assumes s.tasks is ordered in non-decreasing median max-peak order
-
-		// Attempt to schedule a single instance of the heaviest workload available first.
-		// Start from the back until one fits.
-
-		direction := false // True = Min Max, False = Max Min
-		var index int
-		start := true // If false then index has changed and need to keep it that way
-		for i := 0; i < len(baseSchedRef.tasks); i++ {
-			// If scheduling policy switching enabled, then
-			// stop scheduling if the #baseSchedRef.schedWindowSize tasks have been scheduled.
-			if baseSchedRef.schedPolSwitchEnabled &&
-				(s.numTasksScheduled >= baseSchedRef.schedWindowSize) {
-				break // Offers will automatically get declined.
-			}
-			// We need to pick a min task or a max task
-			// depending on the value of direction.
-			if direction && start {
-				index = 0
-			} else if start {
-				index = len(baseSchedRef.tasks) - i - 1
-			}
-			task := baseSchedRef.tasks[index]
 
-			wattsConsideration, err := def.WattsToConsider(task, baseSchedRef.classMapWatts, offer)
-			if err != nil {
-				// Error in determining wattsConsideration.
-				log.Fatal(err)
-			}
+		// Assumes s.tasks is ordered by priority, and then in non-decreasing median max-peak order.
+		// Tasks of higher priority are scheduled first, and the offer is then packed with tasks of
+		// lower priority.
+		for _, priority := range taskPriorities(baseSchedRef.tasks) {
+			lo, _ := priorityBand(baseSchedRef.tasks, priority)
+
+			// Attempt to schedule a single instance of the heaviest workload available first.
+			// Start from the back until one fits.
+
+			direction := false // True = Min Max, False = Max Min
+			var index int
+			start := true // If false then index has changed and need to keep it that way
+			for i := 0; i < bandSize(baseSchedRef.tasks, priority); i++ {
+				// If scheduling policy switching enabled, then
+				// stop scheduling if the #baseSchedRef.schedWindowSize tasks have been scheduled.
+				if baseSchedRef.schedPolSwitchEnabled &&
+					(s.numTasksScheduled >= baseSchedRef.schedWindowSize) {
+					break // Offers will automatically get declined.
+				}
+				// We need to pick a min task or a max task
+				// depending on the value of direction.
+				if direction && start {
+					index = lo
+				} else if start {
+					index = lo + bandSize(baseSchedRef.tasks, priority) - i - 1
+				}
+				task := baseSchedRef.tasks[index]
 
-			// Don't take offer if it doesn't match our task's host requirement.
-			if offerUtils.HostMismatch(*offer.Hostname, task.Host) {
-				continue
-			}
+				// Don't take offer if it doesn't match our task's host requirement.
+				// Retries of failed instances also avoid the hosts on which they failed, if required.
+				if offerUtils.HostMismatch(*offer.Hostname, task.Host) || task.AvoidsHost(*offer.Hostname) {
+					continue
+				}
+
+				taken, taskToSchedule := s.CheckFit(spc, index, task, offer)
 
-			taken, taskToSchedule := s.CheckFit(spc, index, task, wattsConsideration, offer,
-				&totalCPU, &totalRAM, &totalWatts)
-
-			if taken {
-				offerTaken = true
-				tasks = append(tasks, taskToSchedule)
-				// Need to change direction and set start to true.
-				// Setting start to true would ensure that index be set accurately again.
-				direction = !direction
-				start = true
-				i--
-			} else {
-				// Need to move index depending on the value of direction.
-				if direction {
-					index++
-					start = false
+				if taken {
+					offerTaken = true
+					tasks = append(tasks, taskToSchedule)
+					// Need to change direction and set start to true.
+					// Setting start to true would ensure that index be set accurately again.
+					direction = !direction
+					start = true
+					i--
 				} else {
-					index--
-					start = false
+					// Need to move index depending on the value of direction.
+					if direction {
+						index++
+						start = false
+					} else {
+						index--
+						start = false
+					}
 				}
 			}
 		}
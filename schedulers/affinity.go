@@ -0,0 +1,82 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/spdfg/elektron/def"
+)
+
+// Tasks of the instances that are running on the agent, along with the instances that have been
+// launched on the agent and are yet to run, including the ones accepted in the current offer cycle.
+func (s *BaseScheduler) coLocatedTasks(slaveID string) []def.Task {
+	var tasks []def.Task
+	s.TasksRunningMutex.Lock()
+	for taskID := range s.Running[slaveID] {
+		if instance, ok := s.launchedInstances[taskID]; ok {
+			tasks = append(tasks, instance.task)
+		}
+	}
+	for taskID, instance := range s.launchedInstances {
+		if instance.slaveID == slaveID && !s.Running[slaveID][taskID] {
+			tasks = append(tasks, instance.task)
+		}
+	}
+	s.TasksRunningMutex.Unlock()
+	return tasks
+}
+
+// Whether any of the tasks is referred to by the given name or label.
+func anyMatches(tasks []def.Task, ref string) bool {
+	for _, task := range tasks {
+		if task.Matches(ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether placing an instance of the task on the agent of the offer would violate any of the
+// affinity rules of the workload.
+func (s *BaseScheduler) violatesAffinityRules(offer *mesos.Offer, task def.Task) bool {
+	if len(s.affinityRules.AntiAffinity) == 0 && len(s.affinityRules.Affinity) == 0 {
+		return false
+	}
+	coLocated := s.coLocatedTasks(offer.GetSlaveId().GetValue())
+
+	for _, pair := range s.affinityRules.AntiAffinity {
+		if (task.Matches(pair[0]) && anyMatches(coLocated, pair[1])) ||
+			(task.Matches(pair[1]) && anyMatches(coLocated, pair[0])) {
+			return true
+		}
+	}
+
+	for _, pair := range s.affinityRules.Affinity {
+		if !task.Matches(pair[0]) || anyMatches(coLocated, pair[1]) {
+			continue
+		}
+		// Instances are placed anywhere until an instance of the task that they are placed next to is launched.
+		for _, instance := range s.launchedInstances {
+			if instance.task.Matches(pair[1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
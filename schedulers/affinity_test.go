@@ -0,0 +1,61 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/spdfg/elektron/def"
+	"github.com/stretchr/testify/assert"
+)
+
+func agentOffer(slaveID string) *mesos.Offer {
+	return &mesos.Offer{
+		SlaveId:  &mesos.SlaveID{Value: proto.String(slaveID)},
+		Hostname: proto.String(slaveID),
+	}
+}
+
+func TestBaseScheduler_ViolatesAffinityRules(t *testing.T) {
+	minife := def.Task{Name: "minife", Labels: []string{"memory-bound"}}
+	stream := def.Task{Name: "stream", Labels: []string{"memory-bound"}}
+	dgemm := def.Task{Name: "dgemm"}
+
+	s := &BaseScheduler{}
+	s.init(WithAffinityRules(def.AffinityRules{
+		AntiAffinity: [][]string{{"memory-bound", "memory-bound"}},
+		Affinity:     [][]string{{"dgemm", "minife"}},
+	}))
+	// Instances are placed anywhere until an instance of minife is launched.
+	assert.False(t, s.violatesAffinityRules(agentOffer("agent1"), dgemm))
+
+	// minife is running on agent1.
+	s.launchedInstances["electron-minife-1"] = launchedInstance{task: minife, instance: 1, slaveID: "agent1"}
+	s.Running["agent1"] = map[string]bool{"electron-minife-1": true}
+	assert.True(t, s.violatesAffinityRules(agentOffer("agent1"), stream))
+	assert.False(t, s.violatesAffinityRules(agentOffer("agent2"), stream))
+	assert.False(t, s.violatesAffinityRules(agentOffer("agent1"), dgemm))
+	assert.True(t, s.violatesAffinityRules(agentOffer("agent2"), dgemm))
+
+	// Instances accepted in the current offer cycle are also co-located.
+	s.launchedInstances["electron-stream-1"] = launchedInstance{task: stream, instance: 1, slaveID: "agent2"}
+	assert.True(t, s.violatesAffinityRules(agentOffer("agent2"), minife))
+}
@@ -0,0 +1,155 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/spdfg/elektron/utilities"
+	"github.com/spdfg/elektron/utilities/offerUtils"
+)
+
+// Update the state of the scheduler once a launched task instance has terminated.
+// Status updates of instances that have already terminated (for example, instances that were
+// marked lost along with their agent) are ignored.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) instanceTerminated(status *mesos.TaskStatus) {
+	instance, ok := s.launchedInstances[status.GetTaskId().GetValue()]
+	if !ok {
+		return
+	}
+	// Update resource availability.
+	// Instances recovered from the journal that did not run again have not been accounted for.
+	if !instance.recovered {
+		utilities.ResourceAvailabilityUpdate("ON_TASK_TERMINAL_STATE",
+			*status.TaskId, *status.SlaveId)
+	}
+	s.TasksRunningMutex.Lock()
+	if _, ok := s.Running[status.GetSlaveId().GetValue()][status.GetTaskId().GetValue()]; ok {
+		delete(s.Running[status.GetSlaveId().GetValue()], status.GetTaskId().GetValue())
+		s.tasksRunning--
+	}
+	s.TasksRunningMutex.Unlock()
+	s.updateTaskCompletion(status)
+}
+
+// Handle the loss of an agent.
+// The task instances launched on the agent are marked lost, and the agent is no longer
+// considered to be a part of the cluster.
+func (s *BaseScheduler) agentLost(slaveID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for host, id := range s.HostNameToSlaveID {
+		if id == slaveID {
+			delete(s.HostNameToSlaveID, host)
+			delete(s.hostAttributes, host)
+			offerUtils.RemoveFromEnvironment(host)
+			s.LogAgentRemoved(host, slaveID)
+		}
+	}
+
+	// Task instances launched on the agent, including the ones that were not yet running.
+	var lostTaskIDs []string
+	for taskID, instance := range s.launchedInstances {
+		if instance.slaveID == slaveID {
+			lostTaskIDs = append(lostTaskIDs, taskID)
+		}
+	}
+	sort.Strings(lostTaskIDs)
+
+	s.TasksRunningMutex.Lock()
+	s.tasksRunning -= len(s.Running[slaveID])
+	delete(s.Running, slaveID)
+	s.TasksRunningMutex.Unlock()
+
+	for _, taskID := range lostTaskIDs {
+		status := &mesos.TaskStatus{
+			TaskId:  &mesos.TaskID{Value: proto.String(taskID)},
+			SlaveId: &mesos.SlaveID{Value: proto.String(slaveID)},
+			State:   mesos.TaskState_TASK_LOST.Enum(),
+			Source:  mesos.TaskStatus_SOURCE_MASTER.Enum(),
+			Reason:  mesos.TaskStatus_REASON_SLAVE_REMOVED.Enum(),
+		}
+		s.LogTaskStatusUpdate(status)
+		// The resources allocated to the owner of the instance need to be released, which is done
+		// before the resources of the agent stop being tracked.
+		if !s.launchedInstances[taskID].recovered {
+			if err := utilities.ResourceAvailabilityUpdate("ON_TASK_TERMINAL_STATE",
+				*status.TaskId, *status.SlaveId); err != nil {
+				s.LogElectronError(err)
+			}
+		}
+		s.updateTaskCompletion(status)
+	}
+	utilities.RemoveResourceAvailability(slaveID)
+	s.shutdownIfSchedulingComplete()
+	s.compactJournalIfNecessary()
+	s.closeDoneIfComplete()
+}
+
+// Handle the loss of an executor.
+// The command executor of a task instance has the same ID as the instance, which is marked lost.
+func (s *BaseScheduler) executorLost(executorID string, slaveID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	instance, ok := s.launchedInstances[executorID]
+	if !ok || (instance.slaveID != slaveID) {
+		return
+	}
+	status := &mesos.TaskStatus{
+		TaskId:  &mesos.TaskID{Value: proto.String(executorID)},
+		SlaveId: &mesos.SlaveID{Value: proto.String(slaveID)},
+		State:   mesos.TaskState_TASK_LOST.Enum(),
+		Source:  mesos.TaskStatus_SOURCE_SLAVE.Enum(),
+		Reason:  mesos.TaskStatus_REASON_EXECUTOR_TERMINATED.Enum(),
+	}
+	s.LogTaskStatusUpdate(status)
+	s.instanceTerminated(status)
+	s.shutdownIfSchedulingComplete()
+	s.compactJournalIfNecessary()
+	s.closeDoneIfComplete()
+}
+
+// Once the scheduler is shutting down, it is done when no task instances are running anymore.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) closeDoneIfComplete() {
+	if s.tasksRunning != 0 {
+		return
+	}
+	// Instances restored from the journal could still be running.
+	for _, instance := range s.launchedInstances {
+		if instance.recovered {
+			return
+		}
+	}
+	select {
+	case <-s.Shutdown:
+		select {
+		case <-s.Done:
+			// Already done.
+		default:
+			close(s.Done)
+		}
+	default:
+	}
+}
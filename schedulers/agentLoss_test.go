@@ -0,0 +1,158 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/mesos/mesos-go/api/v0/mesosutil"
+	"github.com/spdfg/elektron/def"
+	"github.com/spdfg/elektron/utilities"
+	"github.com/stretchr/testify/assert"
+)
+
+// Scheduler that has launched three instances of a task owned by the given owner. The first two
+// instances are launched on agent1 and the third one on agent2, where the agent IDs are prefixed by
+// the given prefix. The first and the third instances are running.
+// Returns the scheduler and the task IDs of the instances.
+func agentLossScheduler(t *testing.T, prefix string, owner string,
+	requeueOnAgentLoss bool) (*BaseScheduler, []string) {
+	buildTestLogger(t)
+	instances := 3
+	stream := def.Task{
+		Name:      "stream",
+		CPU:       2.0,
+		RAM:       1024,
+		Image:     "rdelvalle/stream:electron1",
+		Instances: &instances,
+		Owner:     owner,
+	}
+	offer := func(host string) *mesos.Offer {
+		return &mesos.Offer{
+			Id:       &mesos.OfferID{Value: proto.String(prefix + "offer-" + host)},
+			SlaveId:  &mesos.SlaveID{Value: proto.String(prefix + "agent-" + host)},
+			Hostname: proto.String(prefix + host),
+			Resources: []*mesos.Resource{
+				mesosutil.NewScalarResource("cpus", 8.0),
+				mesosutil.NewScalarResource("mem", 8192.0),
+			},
+		}
+	}
+	utilities.RecordTotalResourceAvailability([]*mesos.Offer{offer("host1"), offer("host2")})
+
+	recordPCP := true
+	s := &BaseScheduler{RecordPCP: &recordPCP, Shutdown: make(chan struct{}), Done: make(chan struct{})}
+	s.init(WithTasks([]def.Task{stream}), WithRequeueOnAgentLoss(requeueOnAgentLoss))
+	var taskIDs []string
+	for _, host := range []string{"host1", "host1", "host2"} {
+		s.HostNameToSlaveID[prefix+host] = prefix + "agent-" + host
+		taskInfo := s.newTask(offer(host), s.tasks[0])
+		*s.tasks[0].Instances--
+		if *s.tasks[0].Instances <= 0 {
+			s.removeTask(0)
+		}
+		utilities.ResourceAvailabilityUpdate("ON_TASK_ACTIVE_STATE", *taskInfo.TaskId, *taskInfo.SlaveId)
+		taskIDs = append(taskIDs, taskInfo.TaskId.GetValue())
+	}
+	for _, i := range []int{0, 2} {
+		s.instanceRunning(&mesos.TaskStatus{
+			TaskId:  &mesos.TaskID{Value: proto.String(taskIDs[i])},
+			SlaveId: &mesos.SlaveID{Value: proto.String(s.launchedInstances[taskIDs[i]].slaveID)},
+			State:   mesos.TaskState_TASK_RUNNING.Enum(),
+		})
+	}
+	assert.Equal(t, 2, s.tasksRunning)
+	return s, taskIDs
+}
+
+// Whether the channel has been closed.
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestBaseScheduler_AgentLost(t *testing.T) {
+	s, taskIDs := agentLossScheduler(t, "agentLost-", "agentLostOwner", false)
+
+	// Both the running and the not yet running instances on the agent are lost, and the task fails
+	// as its instances cannot be retried.
+	s.agentLost("agentLost-agent-host1")
+	assert.Equal(t, 1, s.tasksRunning)
+	assert.NotContains(t, s.Running, "agentLost-agent-host1")
+	assert.NotContains(t, s.HostNameToSlaveID, "agentLost-host1")
+	assert.NotContains(t, utilities.GetPerHostResourceAvailability(), "agentLost-agent-host1")
+	assert.Equal(t, []string{taskIDs[2]}, s.launchedInstancesOf("stream"))
+	assert.Empty(t, s.tasks)
+	assert.True(t, s.failedTasks["stream"])
+	// Only the resources of the instance that is still running remain allocated to its owner.
+	allocation := utilities.GetPerOwnerAllocation()["agentLostOwner"]
+	assert.Equal(t, 2.0, allocation.CPU)
+	assert.Equal(t, 1024.0, allocation.Ram)
+	assert.True(t, isClosed(s.Shutdown))
+	assert.False(t, isClosed(s.Done), "done while an instance is still running")
+
+	// Status updates of the lost instances that are received afterwards are not counted again.
+	s.StatusUpdate(nil, &mesos.TaskStatus{
+		TaskId:  &mesos.TaskID{Value: proto.String(taskIDs[0])},
+		SlaveId: &mesos.SlaveID{Value: proto.String("agentLost-agent-host1")},
+		State:   mesos.TaskState_TASK_LOST.Enum(),
+	})
+	assert.Equal(t, 1, s.tasksRunning)
+
+	// Executors are only lost on the agent on which their instance was launched.
+	s.executorLost(taskIDs[2], "agentLost-agent-host1")
+	assert.Equal(t, 1, s.tasksRunning)
+	s.executorLost(taskIDs[2], "agentLost-agent-host2")
+	assert.Equal(t, 0, s.tasksRunning)
+	assert.Empty(t, s.launchedInstances)
+	assert.Equal(t, 0.0, utilities.GetPerOwnerAllocation()["agentLostOwner"].CPU)
+	assert.True(t, isClosed(s.Done))
+}
+
+func TestBaseScheduler_AgentLostRequeued(t *testing.T) {
+	s, taskIDs := agentLossScheduler(t, "agentLostRequeued-", "agentLostRequeuedOwner", true)
+
+	lostInstances := []int{s.launchedInstances[taskIDs[0]].instance, s.launchedInstances[taskIDs[1]].instance}
+
+	// The instances lost along with the agent are retried right away, without counting towards the
+	// maximum number of retries of the task.
+	s.agentLost("agentLostRequeued-agent-host1")
+	assert.Equal(t, 1, s.tasksRunning)
+	assert.Equal(t, []string{taskIDs[2]}, s.launchedInstancesOf("stream"))
+	var retriedInstances []int
+	for _, retry := range s.tasks {
+		retriedInstances = append(retriedInstances, retry.Retry.Instance)
+		assert.Equal(t, 1, retry.Retry.Attempt)
+		assert.Equal(t, 0, retry.Retry.Retries)
+	}
+	assert.ElementsMatch(t, lostInstances, retriedInstances)
+	assert.False(t, s.failedTasks["stream"])
+	assert.False(t, isClosed(s.Shutdown), "shutting down while instances are yet to be retried")
+
+	// Losing the agent again does not lose any more instances.
+	s.agentLost("agentLostRequeued-agent-host1")
+	assert.Equal(t, 1, s.tasksRunning)
+	assert.Len(t, s.tasks, 2)
+}
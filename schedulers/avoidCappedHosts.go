@@ -0,0 +1,46 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	sched "github.com/mesos/mesos-go/api/v0/scheduler"
+	"github.com/spdfg/elektron/utilities/mesosUtils"
+)
+
+// Scheduling policy that schedules tasks in first-fit order on the hosts that are not power capped,
+// so that tasks are not slowed down by the power caps.
+// Offers from hosts that are power capped are declined, and the hosts are offered again once uncapped.
+type AvoidCappedHosts struct {
+	FirstFit
+}
+
+func (s *AvoidCappedHosts) ConsumeOffers(spc SchedPolicyContext, driver sched.SchedulerDriver, offers []*mesos.Offer) {
+	baseSchedRef := spc.(*BaseScheduler)
+	uncappedOffers := make([]*mesos.Offer, 0, len(offers))
+	for _, offer := range offers {
+		if baseSchedRef.HostCapped(offer.GetHostname()) {
+			baseSchedRef.LogHostCappedDeclineOffer(offer)
+			driver.DeclineOffer(offer.Id, mesosUtils.DefaultFilter)
+			continue
+		}
+		uncappedOffers = append(uncappedOffers, offer)
+	}
+	s.FirstFit.ConsumeOffers(spc, driver, uncappedOffers)
+}
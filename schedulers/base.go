@@ -21,18 +21,23 @@ package schedulers
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/golang/protobuf/proto"
 	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
-	"github.com/mesos/mesos-go/api/v0/mesosutil"
 	sched "github.com/mesos/mesos-go/api/v0/scheduler"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spdfg/elektron/def"
 	elekLog "github.com/spdfg/elektron/logging"
 	. "github.com/spdfg/elektron/logging/types"
+	"github.com/spdfg/elektron/pcp"
+	"github.com/spdfg/elektron/powerCap"
+	"github.com/spdfg/elektron/store"
 	"github.com/spdfg/elektron/utilities"
+	"github.com/spdfg/elektron/utilities/offerUtils"
 	"github.com/spdfg/elektron/utilities/schedUtils"
 )
 
@@ -52,6 +57,10 @@ type BaseScheduler struct {
 	TasksRunningMutex                 sync.Mutex
 	HostNameToSlaveID                 map[string]string
 	totalResourceAvailabilityRecorded bool
+	// Attributes of each host, as received in its latest offer, keyed by hostname.
+	hostAttributes map[string][]*mesos.Attribute
+	// Rules on the tasks that can be co-located on the same host.
+	affinityRules def.AffinityRules
 
 	// First set of PCP values are garbage values, signal to logger to start recording when we're
 	// about to schedule a new task
@@ -69,8 +78,78 @@ type BaseScheduler struct {
 
 	schedTrace *log.Logger
 
+	// Guards the task queue, as tasks can be submitted and cancelled while resource offers
+	// are being consumed.
 	mutex sync.Mutex
 
+	// Tasks that are held back from scheduling until the tasks that they depend on have completed.
+	blockedTasks []def.Task
+	// Number of instances of each task that are yet to finish.
+	unfinishedInstances map[string]int
+	// Tasks all of whose instances have finished.
+	completedTasks map[string]bool
+	// Tasks that have failed, either because one of their instances did not finish successfully,
+	// or because a task that they depend on failed.
+	failedTasks map[string]bool
+	// Identifier of the current run of the scheduler, which is part of the task IDs of the instances
+	// launched in the run.
+	runID string
+	// Number of instances of each task that have been submitted in the current run, keyed by task name.
+	submittedInstances map[string]int
+	// Task instances that have been launched and have not yet terminated, keyed by task ID.
+	launchedInstances map[string]launchedInstance
+	// Launched task instances that have been requested to be killed, keyed by task ID.
+	killedInstances map[string]bool
+	// Runtimes of the instances of each task that have finished, keyed by task name.
+	runtimes map[string][]time.Duration
+	// Multiple of the median runtime of the finished instances of a task past which a running
+	// instance of the task is flagged as a straggler. Stragglers are not detected if not positive.
+	stragglerThreshold float64
+	// Current time, as kept by the driver.
+	now func() time.Time
+	// Energy attributed to the running task instances, as reported by PCP.
+	energyAttribution *energyAttribution
+	// Reports of the task instances that have terminated.
+	instanceReports []instanceReport
+	// Retries of failed task instances that are waiting for their backoff to elapse.
+	backedOffRetries []def.Task
+
+	// Journal of the changes to the state of the scheduler, if any, so that the framework can survive
+	// a restart of the scheduler.
+	store store.Store
+	// ID of the framework, once registered or restored from the journal.
+	frameworkID *mesos.FrameworkID
+	// Driver through which task instances are killed, once the framework has registered.
+	driver sched.SchedulerDriver
+
+	// Weights of the owners of tasks, if resources are to be shared fairly between owners.
+	ownerWeights map[string]float64
+	// Owners whose tasks could not be scheduled in the offer cycle in which they were last selected.
+	ownersSkipped map[string]bool
+	// Task queue, while the scheduling policy is only shown the tasks of the owner selected
+	// for the offer cycle.
+	allTasks []def.Task
+
+	// Energy budget of the cluster, if the energy consumed by the cluster is to be limited.
+	energyBudget *energyBudget
+	// Recent power consumption of the hosts, as reported by PCP.
+	powerHistory      *pcp.PowerHistory
+	powerHistoryMutex sync.Mutex
+	// Power caps applied to the hosts, if power capping is enabled.
+	capStates *powerCap.CapStates
+	// Ranking by which offers are sorted before being consumed by the scheduling policy, if any.
+	offerRanking offerUtils.OfferRanking
+	// Resources left in each offer of the current offer cycle after placing task instances on it,
+	// keyed by offer ID.
+	offerAllocations map[string]*offerUtils.Allocation
+
+	// Whether to re-enqueue the task instances that are lost along with their agent.
+	requeueOnAgentLoss bool
+
+	// Whether to keep running after all the tasks in the task queue have been scheduled,
+	// so that tasks submitted later can be scheduled.
+	longRunning bool
+
 	// Whether switching of scheduling policies at runtime has been enabled
 	schedPolSwitchEnabled bool
 	// Name of the first scheduling policy to be deployed, if provided.
@@ -105,22 +184,87 @@ func (s *BaseScheduler) init(opts ...SchedulerOptions) {
 			log.Fatal(err)
 		}
 	}
+	if s.now == nil {
+		s.now = time.Now
+	}
 	s.TasksRunningMutex.Lock()
 	s.Running = make(map[string]map[string]bool)
 	s.TasksRunningMutex.Unlock()
 	s.HostNameToSlaveID = make(map[string]string)
+	s.hostAttributes = make(map[string][]*mesos.Attribute)
 	s.mutex = sync.Mutex{}
+	s.runID = def.NewRunID()
+	s.submittedInstances = make(map[string]int)
+	s.unfinishedInstances = make(map[string]int)
+	s.completedTasks = make(map[string]bool)
+	s.failedTasks = make(map[string]bool)
+	s.launchedInstances = make(map[string]launchedInstance)
+	s.killedInstances = make(map[string]bool)
+	s.runtimes = make(map[string][]time.Duration)
+	s.ownersSkipped = make(map[string]bool)
+	s.powerHistory = pcp.NewPowerHistory()
+	s.energyAttribution = newEnergyAttribution()
+	s.offerAllocations = make(map[string]*offerUtils.Allocation)
+	restored := false
+	if s.store != nil {
+		var err error
+		if restored, err = s.restoreState(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if !restored {
+		// Holding back the tasks whose dependencies have not yet completed.
+		tasks := s.tasks
+		s.tasks = nil
+		s.indexInstances(tasks)
+		def.RecordTaskResourceRequirements(s.runID, tasks)
+		if err := s.appendEntry(tasksEnqueuedEntry, toPersistedTasks(tasks)); err != nil {
+			log.Fatal(err)
+		}
+		s.addTasks(tasks)
+	}
 	s.schedWindowResStrategy = schedUtils.SchedWindowResizingCritToStrategy["fillNextOfferCycle"]
 	// Initially no resource offers would have been received.
 	s.hasReceivedResourceOffers = false
 }
 
 func (s *BaseScheduler) SwitchSchedPol(newSchedPol SchedPolicyState) {
+	s.journal(schedPolicySwitchedEntry, schedPolicyName(newSchedPol))
 	s.curSchedPolicy = newSchedPol
 }
 
+// Index the instances of the submitted tasks after the instances of the tasks of the same name that
+// were submitted earlier in the run, so that every instance launched in the run has a unique task ID.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) indexInstances(tasks []def.Task) {
+	for i := range tasks {
+		tasks[i].InstanceOffset = s.submittedInstances[tasks[i].Name]
+		s.submittedInstances[tasks[i].Name] += *tasks[i].Instances
+	}
+}
+
+// Account for the instances of the tasks restored from the journal, so that the instances of the
+// tasks that are submitted later in the run are indexed after them.
+func (s *BaseScheduler) countSubmittedInstances(tasks []def.Task) {
+	for _, task := range tasks {
+		s.countSubmittedInstance(task.Name, task.InstanceID(s.runID).Instance)
+	}
+}
+
+// Account for an instance of a task restored from the journal. Instances that were launched before
+// the restart keep their resource requirements, which are shared by all the instances with the same index.
+func (s *BaseScheduler) countSubmittedInstance(taskName string, instance int) {
+	if instance > s.submittedInstances[taskName] {
+		s.submittedInstances[taskName] = instance
+	}
+}
+
 func (s *BaseScheduler) newTask(offer *mesos.Offer, task def.Task) *mesos.TaskInfo {
-	taskName := fmt.Sprintf("%s-%d", task.Name, *task.Instances)
+	id := task.InstanceID(s.runID)
+	instance := id.Instance
+	taskName := fmt.Sprintf("%s-%d", task.Name, instance)
+	// Every launch of an instance has a different task ID.
+	taskID := id.String()
 	s.tasksCreated++
 
 	if !*s.RecordPCP {
@@ -129,39 +273,91 @@ func (s *BaseScheduler) newTask(offer *mesos.Offer, task def.Task) *mesos.TaskIn
 		time.Sleep(1 * time.Second) // Make sure we're recording by the time the first task starts
 	}
 
-	resources := []*mesos.Resource{
-		mesosutil.NewScalarResource("cpus", task.CPU),
-		mesosutil.NewScalarResource("mem", task.RAM),
+	demand := s.demand(task, offer)
+	if s.wattsAsAResource {
+		s.LogTaskWattsConsideration(task, *offer.Hostname, demand.Scalars["watts"])
+	}
+	resources := s.allocation(offer).Allocate(demand)
+
+	s.journal(instanceLaunchedEntry, persistedInstance{
+		TaskID:   taskID,
+		Task:     toPersistedTask(task),
+		Instance: instance,
+		SlaveID:  offer.GetSlaveId().GetValue(),
+		Host:     offer.GetHostname(),
+	})
+	// The power considered for the instance is 0 if the power consumption of the task is not known.
+	watts, _ := def.WattsToConsider(task, s.classMapWatts, offer)
+	s.launchedInstances[taskID] = launchedInstance{
+		task:       task,
+		instance:   instance,
+		slaveID:    offer.GetSlaveId().GetValue(),
+		host:       offer.GetHostname(),
+		powerClass: offerUtils.PowerClass(offer),
+		watts:      watts,
 	}
+	if s.energyBudget != nil {
+		s.energyBudget.instanceLaunched(s.now(), taskID, s.estimatedWatts(task, offer),
+			task.ExpectedRuntimeSeconds)
+	}
+
+	return &mesos.TaskInfo{
+		Name: proto.String(taskName),
+		TaskId: &mesos.TaskID{
+			Value: proto.String(taskID),
+		},
+		SlaveId:   offer.SlaveId,
+		Resources: resources,
+		Command:   task.CommandInfo(),
+		Container: task.ContainerInfo(),
+		Labels:    task.ContainerLabels(),
+	}
+}
 
+// Resources required by an instance of the task on the host of the offer.
+func (s *BaseScheduler) demand(task def.Task, offer *mesos.Offer) offerUtils.Demand {
+	demand := offerUtils.Demand{
+		Scalars: map[string]float64{"cpus": task.CPU, "mem": task.RAM},
+		Ranges:  task.RangeResources,
+	}
+	for name, amount := range task.ScalarResources {
+		demand.Scalars[name] = amount
+	}
 	if s.wattsAsAResource {
 		if wattsToConsider, err := def.WattsToConsider(task, s.classMapWatts, offer); err == nil {
-			s.LogTaskWattsConsideration(task, *offer.Hostname, wattsToConsider)
-			resources = append(resources, mesosutil.NewScalarResource("watts", wattsToConsider))
+			demand.Scalars["watts"] = wattsToConsider
 		} else {
 			// Error in determining wattsConsideration
 			s.LogElectronError(err)
 		}
 	}
+	return demand
+}
 
-	return &mesos.TaskInfo{
-		Name: proto.String(taskName),
-		TaskId: &mesos.TaskID{
-			Value: proto.String("electron-" + taskName),
-		},
-		SlaveId:   offer.SlaveId,
-		Resources: resources,
-		Command: &mesos.CommandInfo{
-			Value: proto.String(task.CMD),
-		},
-		Container: &mesos.ContainerInfo{
-			Type: mesos.ContainerInfo_DOCKER.Enum(),
-			Docker: &mesos.ContainerInfo_DockerInfo{
-				Image:   proto.String(task.Image),
-				Network: mesos.ContainerInfo_DockerInfo_BRIDGE.Enum(), // Run everything isolated
-			},
-		},
+// Resources left in the offer after placing task instances on it in the current offer cycle.
+func (s *BaseScheduler) allocation(offer *mesos.Offer) *offerUtils.Allocation {
+	allocation, ok := s.offerAllocations[offer.GetId().GetValue()]
+	if !ok {
+		allocation = offerUtils.NewAllocation(offer)
+		s.offerAllocations[offer.GetId().GetValue()] = allocation
 	}
+	return allocation
+}
+
+// Whether an instance of the task fits in the resources left in the offer.
+func (s *BaseScheduler) fits(offer *mesos.Offer, task def.Task) bool {
+	return s.allocation(offer).Fits(s.demand(task, offer))
+}
+
+// Power that an instance of the task is estimated to consume on the host of the offer.
+func (s *BaseScheduler) estimatedWatts(task def.Task, offer *mesos.Offer) float64 {
+	watts, err := def.WattsToConsider(task, s.classMapWatts, offer)
+	if err != nil {
+		// Error in determining the power consumption of the task.
+		s.LogElectronError(err)
+		return task.Watts
+	}
+	return watts
 }
 
 func (s *BaseScheduler) OfferRescinded(_ sched.SchedulerDriver, offerID *mesos.OfferID) {
@@ -169,10 +365,12 @@ func (s *BaseScheduler) OfferRescinded(_ sched.SchedulerDriver, offerID *mesos.O
 }
 func (s *BaseScheduler) SlaveLost(_ sched.SchedulerDriver, slaveID *mesos.SlaveID) {
 	s.LogSlaveLost(slaveID)
+	s.agentLost(slaveID.GetValue())
 }
 func (s *BaseScheduler) ExecutorLost(_ sched.SchedulerDriver, executorID *mesos.ExecutorID,
 	slaveID *mesos.SlaveID, status int) {
 	s.LogExecutorLost(executorID, slaveID)
+	s.executorLost(executorID.GetValue(), slaveID.GetValue())
 }
 
 func (s *BaseScheduler) Error(_ sched.SchedulerDriver, err string) {
@@ -188,14 +386,26 @@ func (s *BaseScheduler) FrameworkMessage(
 }
 
 func (s *BaseScheduler) Registered(
-	_ sched.SchedulerDriver,
+	driver sched.SchedulerDriver,
 	frameworkID *mesos.FrameworkID,
 	masterInfo *mesos.MasterInfo) {
 	s.LogFrameworkRegistered(frameworkID, masterInfo)
+	s.mutex.Lock()
+	s.driver = driver
+	s.frameworkID = frameworkID
+	s.journal(frameworkRegisteredEntry, frameworkID.GetValue())
+	// All the tasks restored from the journal could have already been scheduled.
+	s.shutdownIfSchedulingComplete()
+	s.mutex.Unlock()
+	s.reconcileTasks(driver)
 }
 
-func (s *BaseScheduler) Reregistered(_ sched.SchedulerDriver, masterInfo *mesos.MasterInfo) {
+func (s *BaseScheduler) Reregistered(driver sched.SchedulerDriver, masterInfo *mesos.MasterInfo) {
 	s.LogFrameworkReregistered(masterInfo)
+	s.mutex.Lock()
+	s.driver = driver
+	s.mutex.Unlock()
+	s.reconcileTasks(driver)
 }
 
 func (s *BaseScheduler) Disconnected(sched.SchedulerDriver) {
@@ -203,48 +413,266 @@ func (s *BaseScheduler) Disconnected(sched.SchedulerDriver) {
 }
 
 func (s *BaseScheduler) ResourceOffers(driver sched.SchedulerDriver, offers []*mesos.Offer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.driver = driver
 	// Recording the total amount of resources available across the cluster.
 	utilities.RecordTotalResourceAvailability(offers)
 	for _, offer := range offers {
 		if _, ok := s.HostNameToSlaveID[offer.GetHostname()]; !ok {
 			s.HostNameToSlaveID[offer.GetHostname()] = *offer.SlaveId.Value
 		}
+		s.hostAttributes[offer.GetHostname()] = offer.GetAttributes()
 	}
+	s.offerAllocations = make(map[string]*offerUtils.Allocation)
+	// Offers are consumed from the highest ranked to the lowest ranked.
+	if s.offerRanking != nil {
+		s.offerRanking.Sort(offers)
+	}
+	// Retries of failed task instances can be scheduled once their backoff has elapsed.
+	s.releaseRetries()
+	// Task instances that have run for too long are detected every offer cycle.
+	s.checkRuntimes(s.now())
 	// Switch just before consuming the resource offers.
 	s.curSchedPolicy.SwitchIfNecessary(s)
 	//	s.Log(elecLogDef.GENERAL, fmt.Sprintf("SchedWindowSize[%d], #TasksInWindow[%d]",
 	//		s.schedWindowSize, s.numTasksInSchedWindow))
-	s.curSchedPolicy.ConsumeOffers(s, driver, offers)
+	if owner, ok := s.selectOwner(); ok {
+		// Sharing the resources fairly between the owners of tasks.
+		tasksCreated := s.tasksCreated
+		s.showTasksOfOwner(owner)
+		s.curSchedPolicy.ConsumeOffers(s, driver, offers)
+		s.showTasksOfAllOwners()
+		if s.tasksCreated == tasksCreated {
+			s.ownersSkipped[owner] = true
+		} else {
+			delete(s.ownersSkipped, owner)
+		}
+	} else {
+		s.curSchedPolicy.ConsumeOffers(s, driver, offers)
+	}
 	s.hasReceivedResourceOffers = true
+	s.compactJournalIfNecessary()
+}
+
+// Remove the task at the given index from the task queue, as all its instances have been scheduled.
+func (s *BaseScheduler) removeTask(i int) {
+	s.tasks = append(s.tasks[:i], s.tasks[i+1:]...)
+	s.shutdownIfSchedulingComplete()
+}
+
+// Unless the scheduler is long-running, scheduling is complete once there are no tasks
+// left to schedule, including the tasks that are held back, and no task instances that
+// could still be retried.
+func (s *BaseScheduler) shutdownIfSchedulingComplete() {
+	if (len(s.tasks) > 0) || (len(s.allTasks) > 0) || (len(s.blockedTasks) > 0) || s.retriesPending() ||
+		s.longRunning {
+		return
+	}
+	select {
+	case <-s.Shutdown:
+		// Already shutting down.
+	default:
+		s.LogTerminateScheduler()
+		close(s.Shutdown)
+	}
+}
+
+// Tasks returns the task queue.
+// Only to be used by scheduling policies when consuming resource offers.
+func (s *BaseScheduler) Tasks() []def.Task {
+	return s.tasks
+}
+
+// Whether watts is to be considered as a resource when consuming resource offers.
+func (s *BaseScheduler) WattsAsAResource() bool {
+	return s.wattsAsAResource
+}
+
+// Whether the power class specific watts requirement of tasks is to be considered.
+func (s *BaseScheduler) ClassMapWatts() bool {
+	return s.classMapWatts
+}
+
+// Add tasks to the task queue.
+// The tasks are validated and their names need to be different from those of the pending tasks.
+// Tasks can depend on the tasks that were submitted earlier, unless those tasks have failed.
+func (s *BaseScheduler) SubmitTasks(tasks []def.Task) error {
+	if err := def.ValidateTasks(tasks); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	select {
+	case <-s.Shutdown:
+		return errors.New("scheduler is shutting down")
+	default:
+	}
+
+	taskNames := make(map[string]struct{})
+	for _, task := range s.tasks {
+		taskNames[task.Name] = struct{}{}
+	}
+	for _, task := range s.blockedTasks {
+		taskNames[task.Name] = struct{}{}
+	}
+	for _, task := range s.backedOffRetries {
+		taskNames[task.Name] = struct{}{}
+	}
+	for _, task := range tasks {
+		if _, ok := taskNames[task.Name]; ok {
+			return errors.New("task " + task.Name + " is already pending")
+		}
+		taskNames[task.Name] = struct{}{}
+		for _, parent := range task.DependsOn {
+			if s.failedTasks[parent] {
+				return errors.New("task " + task.Name + " depends on failed task " + parent)
+			}
+		}
+	}
+
+	existingTaskNames := make([]string, 0, len(s.unfinishedInstances))
+	for taskName := range s.unfinishedInstances {
+		existingTaskNames = append(existingTaskNames, taskName)
+	}
+	if err := def.ValidateDependencies(tasks, existingTaskNames); err != nil {
+		return err
+	}
+
+	s.indexInstances(tasks)
+	def.RecordTaskResourceRequirements(s.runID, tasks)
+	s.journal(tasksEnqueuedEntry, toPersistedTasks(tasks))
+	s.addTasks(tasks)
+	s.LogTasksSubmitted(tasks)
+	return nil
+}
+
+// Retrieve a copy of the tasks that have instances yet to be scheduled, including the tasks
+// that are held back until the tasks that they depend on have completed, and the retries of
+// failed instances that are waiting for their backoff to elapse.
+func (s *BaseScheduler) PendingTasks() []def.Task {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	pending := make([]def.Task, 0, len(s.tasks)+len(s.blockedTasks)+len(s.backedOffRetries))
+	pending = append(pending, s.tasks...)
+	pending = append(pending, s.blockedTasks...)
+	pending = append(pending, s.backedOffRetries...)
+	for i := range pending {
+		instances := *pending[i].Instances
+		pending[i].Instances = &instances
+	}
+	return pending
+}
+
+// Information about a task that is running on the cluster.
+type RunningTask struct {
+	TaskID   string `json:"taskID"`
+	SlaveID  string `json:"slaveID"`
+	Hostname string `json:"host"`
+}
+
+// Retrieve the tasks that are currently running on the cluster.
+func (s *BaseScheduler) RunningTasks() []RunningTask {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	slaveIDToHostname := make(map[string]string)
+	for hostname, slaveID := range s.HostNameToSlaveID {
+		slaveIDToHostname[slaveID] = hostname
+	}
+
+	s.TasksRunningMutex.Lock()
+	defer s.TasksRunningMutex.Unlock()
+	running := []RunningTask{}
+	for slaveID, tasks := range s.Running {
+		for taskID := range tasks {
+			running = append(running, RunningTask{
+				TaskID:   taskID,
+				SlaveID:  slaveID,
+				Hostname: slaveIDToHostname[slaveID],
+			})
+		}
+	}
+	return running
+}
+
+// Cancel the given number of pending instances of a task.
+// If the number of instances is not positive, then all pending instances are cancelled.
+// Returns the number of instances that were cancelled.
+// Tasks that depend on the task are no longer held back by the cancelled instances.
+func (s *BaseScheduler) CancelPendingInstances(taskName string, instances int) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	cancelled := s.cancelPendingInstances(taskName, instances)
+	if cancelled == 0 {
+		return 0, errors.New("no pending instances of task " + taskName)
+	}
+	return cancelled, nil
+}
+
+// Cancel the given number of pending instances of a task, or all of them if the number of instances
+// is not positive, and release the tasks that depend on it if it has completed.
+// Returns the number of instances that were cancelled.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) cancelPendingInstances(taskName string, instances int) int {
+	cancelled := s.cancelInstances(taskName, instances)
+	if cancelled == 0 {
+		return 0
+	}
+	s.journal(instancesCancelledEntry, cancelledInstances{TaskName: taskName, Instances: cancelled})
+
+	s.LogPendingInstancesCancelled(taskName, cancelled)
+	for _, task := range s.instancesFinished(taskName, cancelled) {
+		s.LogTaskReleased(task)
+	}
+	s.shutdownIfSchedulingComplete()
+	return cancelled
+}
+
+// Remove the given number of pending instances of a task from the task queue, or all of them if the
+// number of instances is not positive. Returns the number of instances that were removed.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) cancelInstances(taskName string, instances int) int {
+	// Pending instances of the task can be spread across the task queue, the tasks that are
+	// held back and the retries of failed instances.
+	cancelled := 0
+	cancelFrom := func(queue []def.Task) []def.Task {
+		remaining := queue[:0]
+		for _, task := range queue {
+			if (task.Name == taskName) && ((instances <= 0) || (cancelled < instances)) {
+				toCancel := *task.Instances
+				if (instances > 0) && (instances-cancelled < toCancel) {
+					toCancel = instances - cancelled
+				}
+				def.ForgetTaskResourceRequirements(s.runID, task, toCancel)
+				*task.Instances -= toCancel
+				cancelled += toCancel
+				if *task.Instances <= 0 {
+					continue
+				}
+			}
+			remaining = append(remaining, task)
+		}
+		return remaining
+	}
+	s.blockedTasks = cancelFrom(s.blockedTasks)
+	s.backedOffRetries = cancelFrom(s.backedOffRetries)
+	s.tasks = cancelFrom(s.tasks)
+	return cancelled
 }
 
 func (s *BaseScheduler) StatusUpdate(driver sched.SchedulerDriver, status *mesos.TaskStatus) {
 	s.LogTaskStatusUpdate(status)
 	if *status.State == mesos.TaskState_TASK_RUNNING {
-		// If this is our first time running into this Agent
-		s.TasksRunningMutex.Lock()
-		if _, ok := s.Running[*status.SlaveId.Value]; !ok {
-			s.Running[*status.SlaveId.Value] = make(map[string]bool)
-		}
-		// Add task to list of tasks running on node
-		s.Running[*status.SlaveId.Value][*status.TaskId.Value] = true
-		s.tasksRunning++
-		s.TasksRunningMutex.Unlock()
+		s.mutex.Lock()
+		s.instanceRunning(status)
+		s.mutex.Unlock()
 	} else if IsTerminal(status.State) {
-		// Update resource availability.
-		utilities.ResourceAvailabilityUpdate("ON_TASK_TERMINAL_STATE",
-			*status.TaskId, *status.SlaveId)
-		s.TasksRunningMutex.Lock()
-		delete(s.Running[*status.SlaveId.Value], *status.TaskId.Value)
-		s.tasksRunning--
-		s.TasksRunningMutex.Unlock()
-		if s.tasksRunning == 0 {
-			select {
-			case <-s.Shutdown:
-				close(s.Done)
-			default:
-			}
-		}
+		s.mutex.Lock()
+		s.instanceTerminated(status)
+		s.compactJournalIfNecessary()
+		s.closeDoneIfComplete()
+		s.mutex.Unlock()
 	}
 }
 
@@ -254,7 +682,7 @@ func (s *BaseScheduler) LogTaskStarting(ts *def.Task, offer *mesos.Offer) {
 	} else {
 		elekLog.WithFields(log.Fields{
 			"task":     ts.Name,
-			"Instance": fmt.Sprintf("%d", *ts.Instances),
+			"Instance": fmt.Sprintf("%d", ts.InstanceID(s.runID).Instance),
 			"host":     offer.GetHostname(),
 		}).Log(CONSOLE, log.InfoLevel, "TASK STARTING... ")
 	}
@@ -291,13 +719,119 @@ func (s *BaseScheduler) LogCoLocatedTasks(slaveID string) {
 }
 
 func (s *BaseScheduler) LogSchedTrace(taskToSchedule *mesos.TaskInfo, offer *mesos.Offer) {
-	elekLog.WithField(offer.GetHostname(), taskToSchedule.GetTaskId().GetValue()).Log(SCHED_TRACE, log.InfoLevel, "")
+	// Logged as the message rather than as fields, so that the priority always follows the task ID.
+	priority := s.launchedInstances[taskToSchedule.GetTaskId().GetValue()].task.Priority
+	elekLog.Logf(SCHED_TRACE, log.InfoLevel, "%s=%s priority=%d", offer.GetHostname(),
+		taskToSchedule.GetTaskId().GetValue(), priority)
 }
 
 func (s *BaseScheduler) LogTerminateScheduler() {
 	elekLog.Log(CONSOLE, log.InfoLevel, "Done scheduling all tasks!")
 }
 
+func (s *BaseScheduler) LogTasksSubmitted(tasks []def.Task) {
+	for _, task := range tasks {
+		elekLog.WithFields(log.Fields{
+			"task":      task.Name,
+			"Instances": fmt.Sprintf("%d", *task.Instances),
+		}).Log(CONSOLE, log.InfoLevel, "TASK SUBMITTED")
+	}
+}
+
+func (s *BaseScheduler) LogTaskReleased(task def.Task) {
+	elekLog.WithField("task", task.Name).Log(CONSOLE, log.InfoLevel,
+		"Dependencies completed. Task released for scheduling")
+}
+
+func (s *BaseScheduler) LogDependentTaskFailed(task def.Task) {
+	elekLog.WithFields(log.Fields{
+		"task":      task.Name,
+		"dependsOn": strings.Join(task.DependsOn, ","),
+	}).Log(CONSOLE, log.WarnLevel, "Task failed as a task that it depends on failed")
+}
+
+func (s *BaseScheduler) LogTaskRetry(retry def.Task, taskID string, host string, state mesos.TaskState,
+	delay time.Duration) {
+	elekLog.WithFields(log.Fields{
+		"task":     retry.Name,
+		"Instance": fmt.Sprintf("%d", retry.Retry.Instance),
+		"TaskID":   taskID,
+		"host":     host,
+		"State":    state.String(),
+		"Retry":    fmt.Sprintf("%d/%d", retry.Retry.Retries, retry.MaxRetries),
+		"Backoff":  delay.String(),
+	}).Log(TASK_RETRY, log.InfoLevel, "Retrying task instance")
+}
+
+func (s *BaseScheduler) LogTaskRetriesExhausted(task def.Task, instance int, taskID string, host string,
+	state mesos.TaskState) {
+	elekLog.WithFields(log.Fields{
+		"task":     task.Name,
+		"Instance": fmt.Sprintf("%d", instance),
+		"TaskID":   taskID,
+		"host":     host,
+		"State":    state.String(),
+		"Retries":  fmt.Sprintf("%d", task.MaxRetries),
+	}).Log(TASK_RETRY, log.WarnLevel, "Retries of task instance exhausted")
+}
+
+func (s *BaseScheduler) LogTaskRequeued(retry def.Task, taskID string, host string) {
+	elekLog.WithFields(log.Fields{
+		"task":     retry.Name,
+		"Instance": fmt.Sprintf("%d", retry.Retry.Instance),
+		"TaskID":   taskID,
+		"host":     host,
+	}).Log(TASK_RETRY, log.InfoLevel, "Requeueing task instance lost along with its agent")
+}
+
+func (s *BaseScheduler) LogPendingInstancesCancelled(taskName string, instances int) {
+	elekLog.WithFields(log.Fields{
+		"task":      taskName,
+		"Instances": fmt.Sprintf("%d", instances),
+	}).Log(CONSOLE, log.InfoLevel, "PENDING INSTANCES CANCELLED")
+}
+
+func (s *BaseScheduler) LogInstanceKillRequested(taskID string, host string) {
+	elekLog.WithFields(log.Fields{
+		"TaskID": taskID,
+		"host":   host,
+	}).Log(CONSOLE, log.InfoLevel, "KILLING TASK INSTANCE")
+}
+
+func (s *BaseScheduler) LogDraining(launchedInstances int) {
+	elekLog.WithField("Launched Instances", fmt.Sprintf("%d", launchedInstances)).Log(CONSOLE,
+		log.InfoLevel, "Draining... waiting for the launched task instances to terminate")
+}
+
+func (s *BaseScheduler) LogHardStop(launchedInstances int) {
+	elekLog.WithField("Launched Instances", fmt.Sprintf("%d", launchedInstances)).Log(CONSOLE,
+		log.InfoLevel, "Stopping... killing the launched task instances")
+}
+
+func (s *BaseScheduler) LogTaskTimedOut(task def.Task, instance int, taskID string, host string,
+	runtime time.Duration) {
+	elekLog.WithFields(log.Fields{
+		"task":     task.Name,
+		"Instance": fmt.Sprintf("%d", instance),
+		"TaskID":   taskID,
+		"host":     host,
+		"Runtime":  runtime.String(),
+		"Timeout":  seconds(task.TimeoutSeconds).String(),
+	}).Log(TASK_WATCHDOG, log.WarnLevel, "Killing task instance that timed out")
+}
+
+func (s *BaseScheduler) LogStraggler(task def.Task, instance int, taskID string, host string,
+	runtime time.Duration, reference time.Duration) {
+	elekLog.WithFields(log.Fields{
+		"task":      task.Name,
+		"Instance":  fmt.Sprintf("%d", instance),
+		"TaskID":    taskID,
+		"host":      host,
+		"Runtime":   runtime.String(),
+		"Reference": reference.String(),
+	}).Log(TASK_WATCHDOG, log.WarnLevel, "Task instance is a straggler")
+}
+
 func (s *BaseScheduler) LogInsufficientResourcesDeclineOffer(offer *mesos.Offer,
 	offerResources ...interface{}) {
 	buffer := bytes.Buffer{}
@@ -306,6 +840,19 @@ func (s *BaseScheduler) LogInsufficientResourcesDeclineOffer(offer *mesos.Offer,
 		log.WarnLevel, "DECLINING OFFER... Offer has insufficient resources to launch a task")
 }
 
+func (s *BaseScheduler) LogEnergyBudgetExceeded(offer *mesos.Offer, projectedJoules, budgetJoules float64) {
+	elekLog.WithFields(log.Fields{
+		"host":      offer.GetHostname(),
+		"projected": fmt.Sprintf("%f J", projectedJoules),
+		"budget":    fmt.Sprintf("%f J", budgetJoules),
+	}).Log(CONSOLE, log.WarnLevel, "DECLINING OFFER... Launching a task would exceed the energy budget")
+}
+
+func (s *BaseScheduler) LogHostCappedDeclineOffer(offer *mesos.Offer) {
+	elekLog.WithField("host", offer.GetHostname()).Log(CONSOLE,
+		log.WarnLevel, "DECLINING OFFER... Host is power capped")
+}
+
 func (s *BaseScheduler) LogOfferRescinded(offerID *mesos.OfferID) {
 	elekLog.WithField("OfferID", *offerID.Value).Log(CONSOLE, log.ErrorLevel, "OFFER RESCINDED")
 }
@@ -321,6 +868,17 @@ func (s *BaseScheduler) LogExecutorLost(executorID *mesos.ExecutorID, slaveID *m
 	}).Log(CONSOLE, log.ErrorLevel, "EXECUTOR LOST")
 }
 
+func (s *BaseScheduler) LogReconcilingTasks(numTasks int) {
+	elekLog.WithField("numTasks", fmt.Sprintf("%d", numTasks)).Log(CONSOLE, log.InfoLevel, "Reconciling tasks")
+}
+
+func (s *BaseScheduler) LogAgentRemoved(host string, slaveID string) {
+	elekLog.WithFields(log.Fields{
+		"host":    host,
+		"SlaveID": slaveID,
+	}).Log(CONSOLE, log.WarnLevel, "Agent removed from the cluster")
+}
+
 func (s *BaseScheduler) LogFrameworkMessage(executorID *mesos.ExecutorID,
 	slaveID *mesos.SlaveID, message string) {
 	elekLog.Logf(CONSOLE, log.InfoLevel, "Received Framework message from executor %v", executorID)
@@ -21,42 +21,28 @@ package schedulers
 import (
 	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
 	sched "github.com/mesos/mesos-go/api/v0/scheduler"
-	log "github.com/sirupsen/logrus"
 	"github.com/spdfg/elektron/def"
 	"github.com/spdfg/elektron/utilities/mesosUtils"
 	"github.com/spdfg/elektron/utilities/offerUtils"
 )
 
 // Decides if to take an offer or not
-func (s *BinPackSortedWatts) takeOffer(spc SchedPolicyContext, offer *mesos.Offer, task def.Task, totalCPU, totalRAM, totalWatts float64) bool {
-
+// The resources taken by the task instances already placed on the offer are not available.
+func (s *BinPackSortedWatts) takeOffer(spc SchedPolicyContext, offer *mesos.Offer, task def.Task) bool {
 	baseSchedRef := spc.(*BaseScheduler)
-	cpus, mem, watts := offerUtils.OfferAgg(offer)
-
-	//TODO: Insert watts calculation here instead of taking them as a parameter
-
-	wattsConsideration, err := def.WattsToConsider(task, baseSchedRef.classMapWatts, offer)
-	if err != nil {
-		// Error in determining wattsConsideration.
-		log.Fatal(err)
-	}
-	if (cpus >= (totalCPU + task.CPU)) && (mem >= (totalRAM + task.RAM)) &&
-		(!baseSchedRef.wattsAsAResource || (watts >= (totalWatts + wattsConsideration))) {
-		return true
-	}
-	return false
+	return !baseSchedRef.violatesConstraints(offer, task) && baseSchedRef.fits(offer, task)
 }
 
 type BinPackSortedWatts struct {
-	baseSchedPolicyState
+	BaseSchedPolicyState
 }
 
 func (s *BinPackSortedWatts) ConsumeOffers(spc SchedPolicyContext, driver sched.SchedulerDriver, offers []*mesos.Offer) {
 	baseSchedRef := spc.(*BaseScheduler)
 	if baseSchedRef.schedPolSwitchEnabled {
-		SortNTasks(baseSchedRef.tasks, baseSchedRef.numTasksInSchedWindow, def.SortByWatts)
+		SortNTasks(baseSchedRef.tasks, baseSchedRef.numTasksInSchedWindow, def.ByPriority(def.SortByWatts))
 	} else {
-		def.SortTasks(baseSchedRef.tasks, def.SortByWatts)
+		def.SortTasksBy(baseSchedRef.tasks, def.ByPriority(def.SortByWatts))
 	}
 	baseSchedRef.LogOffersReceived(offers)
 
@@ -74,19 +60,12 @@ func (s *BinPackSortedWatts) ConsumeOffers(spc SchedPolicyContext, driver sched.
 		tasks := []*mesos.TaskInfo{}
 
 		offerTaken := false
-		totalWatts := 0.0
-		totalCPU := 0.0
-		totalRAM := 0.0
 		for i := 0; i < len(baseSchedRef.tasks); i++ {
 			task := baseSchedRef.tasks[i]
-			wattsConsideration, err := def.WattsToConsider(task, baseSchedRef.classMapWatts, offer)
-			if err != nil {
-				// Error in determining wattsConsideration.
-				log.Fatal(err)
-			}
 
 			// Don't take offer if it doesn't match our task's host requirement.
-			if offerUtils.HostMismatch(*offer.Hostname, task.Host) {
+			// Retries of failed instances also avoid the hosts on which they failed, if required.
+			if offerUtils.HostMismatch(*offer.Hostname, task.Host) || task.AvoidsHost(*offer.Hostname) {
 				continue
 			}
 
@@ -98,12 +77,9 @@ func (s *BinPackSortedWatts) ConsumeOffers(spc SchedPolicyContext, driver sched.
 					break // Offers will automatically get declined.
 				}
 				// Does the task fit
-				if s.takeOffer(spc, offer, task, totalCPU, totalRAM, totalWatts) {
+				if s.takeOffer(spc, offer, task) {
 
 					offerTaken = true
-					totalWatts += wattsConsideration
-					totalCPU += task.CPU
-					totalRAM += task.RAM
 					baseSchedRef.LogCoLocatedTasks(offer.GetSlaveId().GoString())
 					taskToSchedule := baseSchedRef.newTask(offer, task)
 					tasks = append(tasks, taskToSchedule)
@@ -113,13 +89,8 @@ func (s *BinPackSortedWatts) ConsumeOffers(spc SchedPolicyContext, driver sched.
 					s.numTasksScheduled++
 
 					if *task.Instances <= 0 {
-						// All instances of task have been scheduled, remove it
-						baseSchedRef.tasks = append(baseSchedRef.tasks[:i], baseSchedRef.tasks[i+1:]...)
-
-						if len(baseSchedRef.tasks) <= 0 {
-							baseSchedRef.LogTerminateScheduler()
-							close(baseSchedRef.Shutdown)
-						}
+						// All instances of task have been scheduled, remove it.
+						baseSchedRef.removeTask(i)
 					}
 				} else {
 					break // Continue on to next task
@@ -0,0 +1,127 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"github.com/pkg/errors"
+)
+
+// KillInstance kills the launched task instance with the given task ID.
+// Instances that are killed on request are not retried, and do not fail their task.
+func (s *BaseScheduler) KillInstance(taskID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.killInstance(taskID)
+}
+
+// KillTask cancels all the pending instances of a task and kills all its launched instances.
+// Returns the number of instances that were cancelled and the number of instances that were killed.
+func (s *BaseScheduler) KillTask(taskName string) (int, int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	cancelled := s.cancelPendingInstances(taskName, 0)
+	killed := 0
+	for _, taskID := range s.launchedInstancesOf(taskName) {
+		if err := s.killInstance(taskID); err != nil {
+			return cancelled, killed, err
+		}
+		killed++
+	}
+	if (cancelled == 0) && (killed == 0) {
+		return 0, 0, errors.New("no pending or launched instances of task " + taskName)
+	}
+	return cancelled, killed, nil
+}
+
+// Drain stops launching task instances, and shuts the scheduler down once the launched instances
+// have terminated. Pending instances are left unscheduled.
+func (s *BaseScheduler) Drain() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.LogDraining(len(s.launchedInstances))
+	s.stopLaunching()
+	s.closeDoneIfComplete()
+}
+
+// HardStop stops launching task instances and kills all the launched instances, so that the
+// scheduler shuts down once they have been killed.
+func (s *BaseScheduler) HardStop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.LogHardStop(len(s.launchedInstances))
+	s.stopLaunching()
+	for taskID := range s.launchedInstances {
+		if err := s.killInstance(taskID); err != nil {
+			s.LogElectronError(err)
+		}
+	}
+	s.closeDoneIfComplete()
+}
+
+// Abort shuts the scheduler down right away, without waiting for the launched task instances
+// to terminate.
+func (s *BaseScheduler) Abort() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stopLaunching()
+	select {
+	case <-s.Done:
+		// Already done.
+	default:
+		close(s.Done)
+	}
+}
+
+// Kill the launched task instance with the given task ID.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) killInstance(taskID string) error {
+	instance, ok := s.launchedInstances[taskID]
+	if !ok {
+		return errors.New("no launched instance with task ID " + taskID)
+	}
+	s.LogInstanceKillRequested(taskID, instance.host)
+	if err := s.sendKill(taskID); err != nil {
+		return err
+	}
+	s.killedInstances[taskID] = true
+	return nil
+}
+
+// Task IDs of the launched instances of the given task.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) launchedInstancesOf(taskName string) []string {
+	var taskIDs []string
+	for taskID, instance := range s.launchedInstances {
+		if instance.task.Name == taskName {
+			taskIDs = append(taskIDs, taskID)
+		}
+	}
+	return taskIDs
+}
+
+// Stop launching task instances, as resource offers are declined once the scheduler is shutting down.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) stopLaunching() {
+	select {
+	case <-s.Shutdown:
+		// Already shutting down.
+	default:
+		close(s.Shutdown)
+	}
+}
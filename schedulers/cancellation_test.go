@@ -0,0 +1,95 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/spdfg/elektron/def"
+	"github.com/spdfg/elektron/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseScheduler_KilledInstanceNotRetried(t *testing.T) {
+	instances := 2
+	minife := def.Task{
+		Name:       "minife",
+		CPU:        3.0,
+		RAM:        4096,
+		Image:      "rdelvalle/minife:electron1",
+		Instances:  &instances,
+		MaxRetries: 2,
+	}
+	offer := &mesos.Offer{
+		Id:       &mesos.OfferID{Value: proto.String("offer1")},
+		SlaveId:  &mesos.SlaveID{Value: proto.String("agent1")},
+		Hostname: proto.String("host1"),
+	}
+
+	journal := store.NewMemoryStore()
+	recordPCP := true
+	s := &BaseScheduler{RecordPCP: &recordPCP, Shutdown: make(chan struct{})}
+	s.init(WithStore(journal), WithTasks([]def.Task{minife}))
+	// Not shutting down once all the instances have terminated.
+	s.longRunning = true
+	for len(s.tasks) > 0 {
+		s.newTask(offer, s.tasks[0])
+		*s.tasks[0].Instances--
+		if *s.tasks[0].Instances <= 0 {
+			s.removeTask(0)
+		}
+	}
+	taskID := func(instance int) string {
+		return def.InstanceID{RunID: s.runID, Task: "minife", Instance: instance}.String()
+	}
+	assert.ElementsMatch(t, []string{taskID(1), taskID(2)}, s.launchedInstancesOf("minife"))
+	assert.Empty(t, s.launchedInstancesOf("dgemm"))
+
+	// One of the instances is killed on request, and the other finishes.
+	s.killedInstances[taskID(2)] = true
+	s.updateTaskCompletion(&mesos.TaskStatus{
+		TaskId:  &mesos.TaskID{Value: proto.String(taskID(2))},
+		SlaveId: offer.SlaveId,
+		State:   mesos.TaskState_TASK_KILLED.Enum(),
+	})
+	assert.Empty(t, s.tasks)
+	assert.Empty(t, s.killedInstances)
+	assert.False(t, s.failedTasks["minife"])
+	s.updateTaskCompletion(&mesos.TaskStatus{
+		TaskId:  &mesos.TaskID{Value: proto.String(taskID(1))},
+		SlaveId: offer.SlaveId,
+		State:   mesos.TaskState_TASK_FINISHED.Enum(),
+	})
+	assert.True(t, s.completedTasks["minife"])
+	// The resource requirements of instances that are not retried are forgotten.
+	for _, instance := range []int{1, 2} {
+		_, err := def.GetResourceRequirement(taskID(instance))
+		assert.Error(t, err)
+	}
+
+	// The killed instance is not retried when the journal is replayed either.
+	restored := &BaseScheduler{}
+	restored.init(WithStore(journal))
+	assert.Empty(t, restored.tasks)
+	assert.Empty(t, restored.launchedInstances)
+	assert.True(t, restored.completedTasks["minife"])
+	assert.False(t, restored.failedTasks["minife"])
+}
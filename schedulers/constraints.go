@@ -0,0 +1,59 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/spdfg/elektron/def"
+)
+
+// Whether placing an instance of the task on the host of the offer would violate any of the
+// constraints of the task, or any of the affinity rules of the workload. The instances placed
+// earlier in the offer cycle count towards the max constraints of the task and the affinity rules.
+func (s *BaseScheduler) violatesConstraints(offer *mesos.Offer, task def.Task) bool {
+	if s.violatesAffinityRules(offer, task) {
+		return true
+	}
+	for _, constraint := range task.Constraints {
+		attribute := constraint.Attribute
+		instances := func(value string) int {
+			return s.instancesWithAttribute(task.Name, attribute, value)
+		}
+		if !constraint.SatisfiedBy(offer, instances) {
+			return true
+		}
+	}
+	return false
+}
+
+// Number of instances of the task that have been launched, and have not yet terminated, on the hosts
+// with the given value of the attribute.
+// Instances on hosts that are yet to make an offer are only counted for the host attribute.
+func (s *BaseScheduler) instancesWithAttribute(taskName string, attribute string, value string) int {
+	count := 0
+	for _, instance := range s.launchedInstances {
+		if instance.task.Name != taskName {
+			continue
+		}
+		if v, ok := def.AttributeValue(instance.host, s.hostAttributes[instance.host], attribute); ok && v == value {
+			count++
+		}
+	}
+	return count
+}
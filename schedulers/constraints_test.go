@@ -0,0 +1,59 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/spdfg/elektron/def"
+	"github.com/stretchr/testify/assert"
+)
+
+func classOffer(host, class string) *mesos.Offer {
+	return &mesos.Offer{
+		Hostname: proto.String(host),
+		Attributes: []*mesos.Attribute{{
+			Name: proto.String("class"),
+			Type: mesos.Value_TEXT.Enum(),
+			Text: &mesos.Value_Text{Value: proto.String(class)},
+		}},
+	}
+}
+
+func TestBaseScheduler_ViolatesConstraints(t *testing.T) {
+	task := def.Task{Name: "minife"}
+	assert.NoError(t, json.Unmarshal([]byte(`["class in [A, B]", "class max 2"]`), &task.Constraints))
+
+	s := &BaseScheduler{}
+	s.init()
+	s.hostAttributes["stratos-001"] = classOffer("stratos-001", "A").GetAttributes()
+	s.launchedInstances["electron-minife-1"] = launchedInstance{task: task, instance: 1, host: "stratos-001"}
+	s.launchedInstances["electron-dgemm-1"] = launchedInstance{task: def.Task{Name: "dgemm"}, instance: 1, host: "stratos-001"}
+
+	assert.False(t, s.violatesConstraints(classOffer("stratos-002", "A"), task))
+	assert.True(t, s.violatesConstraints(classOffer("stratos-003", "C"), task))
+
+	// Instances that are placed count towards the max constraint.
+	s.launchedInstances["electron-minife-2"] = launchedInstance{task: task, instance: 2, host: "stratos-001"}
+	assert.True(t, s.violatesConstraints(classOffer("stratos-002", "A"), task))
+	assert.False(t, s.violatesConstraints(classOffer("stratos-004", "B"), task))
+}
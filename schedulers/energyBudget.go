@@ -0,0 +1,293 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	sched "github.com/mesos/mesos-go/api/v0/scheduler"
+	"github.com/spdfg/elektron/def"
+	"github.com/spdfg/elektron/utilities/mesosUtils"
+	"github.com/spdfg/elektron/utilities/offerUtils"
+)
+
+// Weight given to the latest ratio of the measured power of the cluster to the estimated power of
+// the running task instances, when correcting the energy estimates.
+const energyCorrectionSmoothing = 0.5
+
+// Estimated power consumption of a task instance that is running.
+type instanceEnergy struct {
+	watts    float64
+	launched time.Time
+	// Zero if the runtime of the instance is not known.
+	expectedEnd time.Time
+}
+
+// Tracks the energy consumed by the cluster against an energy budget, which is renewed every horizon.
+// The energy consumed by the cluster is measured using the power reported by PCP, if available.
+// Otherwise, it is estimated from the watts requirement of the task instances that have run.
+type energyBudget struct {
+	mutex sync.Mutex
+	// Energy, in joules, that the cluster can consume in every horizon.
+	budgetJoules float64
+	horizon      time.Duration
+	// Start of the current horizon.
+	horizonStart time.Time
+
+	// Whether the power of the cluster is being measured by PCP.
+	measured bool
+	// Energy consumed in the current horizon, as measured by PCP.
+	measuredJoules float64
+	lastSample     time.Time
+	// Energy consumed in the current horizon by the task instances that have terminated, as estimated.
+	estimatedJoules float64
+	// Ratio of the measured power of the cluster to the estimated power of the running task instances.
+	correction float64
+
+	// Running task instances, keyed by task ID.
+	running map[string]instanceEnergy
+}
+
+func newEnergyBudget(budgetJoules float64, horizon time.Duration) *energyBudget {
+	return &energyBudget{
+		budgetJoules: budgetJoules,
+		horizon:      horizon,
+		correction:   1.0,
+		running:      make(map[string]instanceEnergy),
+	}
+}
+
+// Move on to the horizon that contains the given time, renewing the energy budget.
+// Needs to be called with the mutex locked.
+func (e *energyBudget) advance(now time.Time) {
+	if e.horizonStart.IsZero() {
+		e.horizonStart = now
+	}
+	for !now.Before(e.horizonStart.Add(e.horizon)) {
+		e.horizonStart = e.horizonStart.Add(e.horizon)
+		e.measuredJoules = 0.0
+		e.estimatedJoules = 0.0
+	}
+}
+
+// Estimated energy consumed by the running instance in the current horizon until the given time.
+// Needs to be called with the mutex locked.
+func (e *energyBudget) consumedByInstance(instance instanceEnergy, now time.Time) float64 {
+	start := instance.launched
+	if start.Before(e.horizonStart) {
+		start = e.horizonStart
+	}
+	return instance.watts * math.Max(0.0, now.Sub(start).Seconds())
+}
+
+// Estimated energy that would be consumed in the rest of the current horizon by an instance that
+// consumes the given power until the given time. Instances whose runtime is not known are assumed to
+// run until the end of the horizon.
+// Needs to be called with the mutex locked.
+func (e *energyBudget) toBeConsumed(watts float64, end time.Time, now time.Time) float64 {
+	horizonEnd := e.horizonStart.Add(e.horizon)
+	if end.IsZero() || end.After(horizonEnd) {
+		end = horizonEnd
+	}
+	return watts * e.correction * math.Max(0.0, end.Sub(now).Seconds())
+}
+
+// Record that a task instance has been launched at the given time.
+func (e *energyBudget) instanceLaunched(now time.Time, taskID string, watts float64,
+	expectedRuntimeSeconds float64) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	instance := instanceEnergy{watts: watts, launched: now}
+	if expectedRuntimeSeconds > 0.0 {
+		instance.expectedEnd = now.Add(time.Duration(expectedRuntimeSeconds * float64(time.Second)))
+	}
+	e.running[taskID] = instance
+}
+
+// Record that a task instance has terminated at the given time.
+func (e *energyBudget) instanceTerminated(now time.Time, taskID string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	instance, ok := e.running[taskID]
+	if !ok {
+		return
+	}
+	e.advance(now)
+	e.estimatedJoules += e.consumedByInstance(instance, now)
+	delete(e.running, taskID)
+}
+
+// Record the power consumed by the cluster, as reported by PCP.
+// The estimates of the energy that the running instances are yet to consume are corrected using
+// the ratio of the measured power to the estimated power.
+func (e *energyBudget) recordSample(now time.Time, watts float64) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.advance(now)
+	if e.measured {
+		start := e.lastSample
+		if start.Before(e.horizonStart) {
+			start = e.horizonStart
+		}
+		e.measuredJoules += watts * math.Max(0.0, now.Sub(start).Seconds())
+	}
+	e.measured = true
+	e.lastSample = now
+
+	estimatedWatts := 0.0
+	for _, instance := range e.running {
+		estimatedWatts += instance.watts
+	}
+	if estimatedWatts > 0.0 {
+		e.correction = (1.0-energyCorrectionSmoothing)*e.correction +
+			energyCorrectionSmoothing*(watts/estimatedWatts)
+	}
+}
+
+// Projected energy consumption of the cluster in the current horizon, if an instance of a task that
+// consumes the given power for the given number of seconds (0 if not known) were to be launched at
+// the given time. Returns whether the projected energy consumption is within the energy budget.
+func (e *energyBudget) fits(now time.Time, watts float64, expectedRuntimeSeconds float64) (float64, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.advance(now)
+
+	projected := e.measuredJoules
+	if !e.measured {
+		projected = e.estimatedJoules
+		for _, instance := range e.running {
+			projected += e.consumedByInstance(instance, now)
+		}
+	}
+	for _, instance := range e.running {
+		projected += e.toBeConsumed(instance.watts, instance.expectedEnd, now)
+	}
+	var end time.Time
+	if expectedRuntimeSeconds > 0.0 {
+		end = now.Add(time.Duration(expectedRuntimeSeconds * float64(time.Second)))
+	}
+	projected += e.toBeConsumed(watts, end, now)
+	return projected, projected <= e.budgetJoules
+}
+
+// Scheduling policy that schedules tasks in first-fit order, as long as the energy projected to be
+// consumed by the cluster stays within the energy budget. Tasks that would exceed the energy budget
+// are delayed until the budget is renewed, or until enough of the running tasks terminate.
+// The energy consumed by an instance of a task is estimated from its watts requirement and its
+// expected runtime. Tasks whose runtime is not known are assumed to run until the end of the horizon.
+type EnergyBudget struct {
+	BaseSchedPolicyState
+}
+
+// Decides if to take an offer or not
+func (s *EnergyBudget) takeOffer(spc SchedPolicyContext, offer *mesos.Offer, task def.Task) bool {
+	baseSchedRef := spc.(*BaseScheduler)
+	return !baseSchedRef.violatesConstraints(offer, task) && baseSchedRef.fits(offer, task)
+}
+
+func (s *EnergyBudget) ConsumeOffers(spc SchedPolicyContext, driver sched.SchedulerDriver, offers []*mesos.Offer) {
+	baseSchedRef := spc.(*BaseScheduler)
+	// Tasks of higher priority are scheduled first.
+	if baseSchedRef.schedPolSwitchEnabled {
+		SortNTasks(baseSchedRef.tasks, baseSchedRef.numTasksInSchedWindow, def.Ordering{def.SortByPriority})
+	} else {
+		def.SortTasksBy(baseSchedRef.tasks, def.Ordering{def.SortByPriority})
+	}
+	baseSchedRef.LogOffersReceived(offers)
+
+	for _, offer := range offers {
+		offerUtils.UpdateEnvironment(offer)
+		select {
+		case <-baseSchedRef.Shutdown:
+			baseSchedRef.LogNoPendingTasksDeclineOffers(offer)
+			driver.DeclineOffer(offer.Id, mesosUtils.LongFilter)
+			baseSchedRef.LogNumberOfRunningTasks()
+			continue
+		default:
+		}
+
+		tasks := []*mesos.TaskInfo{}
+
+		offerTaken := false
+		budgetExceeded := false
+		projected := 0.0
+		for i := 0; i < len(baseSchedRef.tasks); i++ {
+			// If scheduling policy switching enabled, then
+			// stop scheduling if the #baseSchedRef.schedWindowSize tasks have been scheduled.
+			if baseSchedRef.schedPolSwitchEnabled && (s.numTasksScheduled >= baseSchedRef.schedWindowSize) {
+				break // Offers will automatically get declined.
+			}
+			task := baseSchedRef.tasks[i]
+
+			// Don't take offer if it doesn't match our task's host requirement.
+			// Retries of failed instances also avoid the hosts on which they failed, if required.
+			if offerUtils.HostMismatch(*offer.Hostname, task.Host) || task.AvoidsHost(*offer.Hostname) {
+				continue
+			}
+			if !s.takeOffer(spc, offer, task) {
+				continue
+			}
+
+			// Delaying the task if it would exceed the energy budget.
+			// Tasks that consume less energy could still fit in the energy budget.
+			if baseSchedRef.energyBudget != nil {
+				var fits bool
+				projected, fits = baseSchedRef.energyBudget.fits(baseSchedRef.now(),
+					baseSchedRef.estimatedWatts(task, offer),
+					task.ExpectedRuntimeSeconds)
+				if !fits {
+					budgetExceeded = true
+					continue
+				}
+			}
+
+			baseSchedRef.LogCoLocatedTasks(offer.GetSlaveId().GoString())
+
+			taskToSchedule := baseSchedRef.newTask(offer, task)
+			tasks = append(tasks, taskToSchedule)
+
+			baseSchedRef.LogTaskStarting(&task, offer)
+			LaunchTasks([]*mesos.OfferID{offer.Id}, tasks, driver)
+			offerTaken = true
+
+			baseSchedRef.LogSchedTrace(taskToSchedule, offer)
+			*task.Instances--
+			s.numTasksScheduled++
+
+			if *task.Instances <= 0 {
+				// All instances of task have been scheduled, remove it.
+				baseSchedRef.removeTask(i)
+			}
+			break // Offer taken, move on.
+		}
+
+		if !offerTaken {
+			if budgetExceeded {
+				baseSchedRef.LogEnergyBudgetExceeded(offer, projected, baseSchedRef.energyBudget.budgetJoules)
+			} else {
+				cpus, mem, watts := offerUtils.OfferAgg(offer)
+				baseSchedRef.LogInsufficientResourcesDeclineOffer(offer, cpus, mem, watts)
+			}
+			driver.DeclineOffer(offer.Id, mesosUtils.DefaultFilter)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnergyBudget_Fits(t *testing.T) {
+	e := newEnergyBudget(10000.0, time.Hour)
+	now := time.Now()
+	e.horizonStart = now.Add(-100 * time.Second)
+	// Running for 100 seconds, and expected to run for another 100 seconds.
+	e.running["electron-task-1"] = instanceEnergy{
+		watts:       10.0,
+		launched:    now.Add(-100 * time.Second),
+		expectedEnd: now.Add(100 * time.Second),
+	}
+
+	// 1000 J consumed, 1000 J yet to be consumed by the running instance, and 5000 J to be consumed
+	// by the new instance.
+	projected, fits := e.fits(now, 50.0, 100.0)
+	assert.InDelta(t, 7000.0, projected, 10.0)
+	assert.True(t, fits)
+
+	_, fits = e.fits(now, 100.0, 100.0)
+	assert.False(t, fits, "budget exceeded")
+	// Instances whose runtime is not known are assumed to run until the end of the horizon.
+	_, fits = e.fits(now, 1.0, 0.0)
+	assert.True(t, fits)
+	_, fits = e.fits(now, 3.0, 0.0)
+	assert.False(t, fits, "budget exceeded")
+
+	// The energy consumed by terminated instances is still accounted for.
+	e.instanceTerminated(now, "electron-task-1")
+	assert.Empty(t, e.running)
+	projected, _ = e.fits(now, 0.0, 0.0)
+	assert.InDelta(t, 1000.0, projected, 10.0)
+}
+
+func TestEnergyBudget_RecordSample(t *testing.T) {
+	e := newEnergyBudget(10000.0, time.Hour)
+	now := time.Now()
+	e.running["electron-task-1"] = instanceEnergy{
+		watts:       10.0,
+		launched:    now,
+		expectedEnd: now.Add(100 * time.Second),
+	}
+
+	// The running instance consumes twice the power it was estimated to.
+	e.recordSample(now, 20.0)
+	e.recordSample(now.Add(10*time.Second), 20.0)
+	assert.InDelta(t, 1.75, e.correction, 1e-9)
+	assert.InDelta(t, 200.0, e.measuredJoules, 1e-9)
+
+	// The measured energy replaces the estimated energy consumed so far.
+	projected, _ := e.fits(now, 0.0, 0.0)
+	assert.InDelta(t, 200.0+100.0*10.0*1.75, projected, 50.0)
+
+	// The energy budget is renewed every horizon.
+	e.recordSample(now.Add(time.Hour+10*time.Second), 20.0)
+	assert.InDelta(t, 200.0, e.measuredJoules, 1e-9)
+	assert.Equal(t, now.Add(time.Hour), e.horizonStart)
+}
@@ -0,0 +1,147 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/spdfg/elektron/def"
+	"github.com/spdfg/elektron/utilities"
+)
+
+// Weight of the owners that are not present in the owner weights config file.
+const defaultOwnerWeight = 1.0
+
+// Read the weights of the owners of tasks from the given config file, which maps the names of
+// owners to their weights. Owners receive shares of the cluster in proportion to their weights.
+func OwnerWeightsFromJSON(ownerWeightsConfigFilename string) (map[string]float64, error) {
+	var ownerWeights map[string]float64
+	file, err := os.Open(ownerWeightsConfigFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error opening file")
+	}
+	defer file.Close()
+	if err := json.NewDecoder(file).Decode(&ownerWeights); err != nil {
+		return nil, errors.Wrap(err, "Error unmarshalling")
+	}
+	for owner, weight := range ownerWeights {
+		if weight <= 0.0 {
+			return nil, errors.New(fmt.Sprintf("weight of owner %s should be positive", owner))
+		}
+	}
+	return ownerWeights, nil
+}
+
+// Weighted dominant share of the owner, which is the largest share of any resource in the cluster
+// allocated to the owner, divided by the weight of the owner.
+func (s *BaseScheduler) dominantShare(owner string, allocation def.TaskResources,
+	total utilities.ResourceCount) float64 {
+	share := 0.0
+	for _, resourceShare := range []struct{ allocated, total float64 }{
+		{allocation.CPU, total.TotalCPU},
+		{allocation.Ram, total.TotalRAM},
+		{allocation.Watts, total.TotalWatts},
+	} {
+		if (resourceShare.total > 0.0) && (resourceShare.allocated/resourceShare.total > share) {
+			share = resourceShare.allocated / resourceShare.total
+		}
+	}
+	weight, ok := s.ownerWeights[owner]
+	if !ok {
+		weight = defaultOwnerWeight
+	}
+	return share / weight
+}
+
+// Select the owner whose tasks are to be scheduled in this offer cycle, using dominant resource fairness.
+// The owner with pending tasks that has the smallest weighted dominant share is selected. Owners whose
+// tasks could not be scheduled in the offer cycle in which they were last selected are skipped until
+// every owner has been skipped, so that they do not hold back the other owners.
+// Returns false if fair sharing is disabled, or if the pending tasks belong to a single owner.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) selectOwner() (string, bool) {
+	if s.ownerWeights == nil {
+		return "", false
+	}
+	var owners []string
+	ownersSeen := make(map[string]bool)
+	for _, task := range s.tasks {
+		if !ownersSeen[task.Owner] {
+			ownersSeen[task.Owner] = true
+			owners = append(owners, task.Owner)
+		}
+	}
+	if len(owners) <= 1 {
+		return "", false
+	}
+	sort.Strings(owners)
+
+	var candidates []string
+	for _, owner := range owners {
+		if !s.ownersSkipped[owner] {
+			candidates = append(candidates, owner)
+		}
+	}
+	if len(candidates) == 0 {
+		s.ownersSkipped = make(map[string]bool)
+		candidates = owners
+	}
+
+	perOwnerAllocation := utilities.GetPerOwnerAllocation()
+	total := utilities.GetClusterwideResourceAvailability()
+	selected := candidates[0]
+	minShare := s.dominantShare(selected, perOwnerAllocation[selected], total)
+	for _, owner := range candidates[1:] {
+		if share := s.dominantShare(owner, perOwnerAllocation[owner], total); share < minShare {
+			selected = owner
+			minShare = share
+		}
+	}
+	return selected, true
+}
+
+// Hide the tasks of all the owners other than the given owner from the scheduling policy.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) showTasksOfOwner(owner string) {
+	s.allTasks = s.tasks
+	s.tasks = nil
+	for _, task := range s.allTasks {
+		if task.Owner == owner {
+			s.tasks = append(s.tasks, task)
+		}
+	}
+}
+
+// Make the tasks of all the owners visible to the scheduling policy again, retaining their order
+// in the task queue. Tasks all of whose instances have been scheduled are no longer queued.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) showTasksOfAllOwners() {
+	tasks := make([]def.Task, 0, len(s.allTasks))
+	for _, task := range s.allTasks {
+		if *task.Instances > 0 {
+			tasks = append(tasks, task)
+		}
+	}
+	s.tasks = tasks
+	s.allTasks = nil
+}
@@ -0,0 +1,109 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/mesos/mesos-go/api/v0/mesosutil"
+	"github.com/spdfg/elektron/def"
+	"github.com/spdfg/elektron/utilities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOwnerWeightsFromJSON(t *testing.T) {
+	ownerWeights, err := OwnerWeightsFromJSON("../ownerWeights_sample.json")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"teamA": 2.0, "teamB": 1.0}, ownerWeights)
+
+	config, err := ioutil.TempFile("", "ownerWeights")
+	assert.NoError(t, err)
+	defer os.Remove(config.Name())
+	_, err = config.WriteString(`{"teamA": 0}`)
+	assert.NoError(t, err)
+	assert.NoError(t, config.Close())
+	_, err = OwnerWeightsFromJSON(config.Name())
+	assert.Error(t, err, "non-positive weight accepted")
+}
+
+func TestBaseScheduler_DominantShare(t *testing.T) {
+	s := &BaseScheduler{ownerWeights: map[string]float64{"teamA": 2.0}}
+	total := utilities.ResourceCount{TotalCPU: 10.0, TotalRAM: 1000.0}
+	allocation := def.TaskResources{CPU: 2.0, Ram: 400.0, Watts: 50.0}
+	// Memory is the dominant resource, and watts are not considered as they are not offered.
+	assert.InDelta(t, 0.2, s.dominantShare("teamA", allocation, total), 1e-9)
+	assert.InDelta(t, 0.4, s.dominantShare("teamB", allocation, total), 1e-9)
+}
+
+func TestBaseScheduler_SelectOwner(t *testing.T) {
+	instances := []int{1, 1, 1}
+	tasks := []def.Task{
+		{Name: "minife", Owner: "teamA", Instances: &instances[0]},
+		{Name: "dgemm", Owner: "teamB", Instances: &instances[1]},
+		{Name: "stream", Owner: "teamA", Instances: &instances[2]},
+	}
+	s := &BaseScheduler{}
+	s.init(WithTasks(tasks))
+	_, ok := s.selectOwner()
+	assert.False(t, ok, "owner selected without fair sharing")
+
+	s.ownerWeights = map[string]float64{}
+	utilities.RecordTotalResourceAvailability([]*mesos.Offer{{
+		Id:       &mesos.OfferID{Value: proto.String("offer1")},
+		SlaveId:  &mesos.SlaveID{Value: proto.String("fairShareAgent")},
+		Hostname: proto.String("fairShareHost"),
+		Resources: []*mesos.Resource{
+			mesosutil.NewScalarResource("cpus", 8.0),
+			mesosutil.NewScalarResource("mem", 8192.0),
+		},
+	}})
+	// teamA is allocated resources, and so teamB is selected.
+	def.RecordInstanceResourceRequirements("electron-run-fairShare-1-0", def.Task{CPU: 4.0, RAM: 1024, Owner: "teamA"})
+	utilities.ResourceAvailabilityUpdate("ON_TASK_ACTIVE_STATE",
+		mesos.TaskID{Value: proto.String("electron-run-fairShare-1-0")},
+		mesos.SlaveID{Value: proto.String("fairShareAgent")})
+	defer utilities.ResourceAvailabilityUpdate("ON_TASK_TERMINAL_STATE",
+		mesos.TaskID{Value: proto.String("electron-run-fairShare-1-0")},
+		mesos.SlaveID{Value: proto.String("fairShareAgent")})
+	owner, ok := s.selectOwner()
+	assert.True(t, ok)
+	assert.Equal(t, "teamB", owner)
+
+	// Owners whose tasks could not be scheduled are skipped, until all the owners have been skipped.
+	s.ownersSkipped["teamB"] = true
+	owner, _ = s.selectOwner()
+	assert.Equal(t, "teamA", owner)
+	s.ownersSkipped["teamA"] = true
+	owner, _ = s.selectOwner()
+	assert.Equal(t, "teamB", owner)
+	assert.Empty(t, s.ownersSkipped)
+
+	// Only the tasks of the selected owner are shown to the scheduling policy.
+	s.showTasksOfOwner("teamA")
+	assert.Equal(t, []string{"minife", "stream"}, taskNames(s.tasks))
+	*s.tasks[0].Instances--
+	s.removeTask(0)
+	s.showTasksOfAllOwners()
+	assert.Equal(t, []string{"dgemm", "stream"}, taskNames(s.tasks))
+	assert.Nil(t, s.allTasks)
+}
@@ -29,29 +29,23 @@ import (
 // Decides if to take an offer or not
 func (s *FirstFit) takeOffer(spc SchedPolicyContext, offer *mesos.Offer, task def.Task) bool {
 	baseSchedRef := spc.(*BaseScheduler)
-	cpus, mem, watts := offerUtils.OfferAgg(offer)
-
-	//TODO: Insert watts calculation here instead of taking them as a parameter
-
-	wattsConsideration, err := def.WattsToConsider(task, baseSchedRef.classMapWatts, offer)
-	if err != nil {
-		// Error in determining wattsConsideration
-		baseSchedRef.LogElectronError(err)
-	}
-	if cpus >= task.CPU && mem >= task.RAM && (!baseSchedRef.wattsAsAResource || watts >= wattsConsideration) {
-		return true
-	}
-
-	return false
+	return !baseSchedRef.violatesConstraints(offer, task) && baseSchedRef.fits(offer, task)
 }
 
 // Elektron scheduler implements the Scheduler interface.
 type FirstFit struct {
-	baseSchedPolicyState
+	BaseSchedPolicyState
 }
 
 func (s *FirstFit) ConsumeOffers(spc SchedPolicyContext, driver sched.SchedulerDriver, offers []*mesos.Offer) {
 	baseSchedRef := spc.(*BaseScheduler)
+	// Tasks of higher priority are scheduled first. Tasks of the same priority are scheduled in the
+	// order in which they were queued.
+	if baseSchedRef.schedPolSwitchEnabled {
+		SortNTasks(baseSchedRef.tasks, baseSchedRef.numTasksInSchedWindow, def.Ordering{def.SortByPriority})
+	} else {
+		def.SortTasksBy(baseSchedRef.tasks, def.Ordering{def.SortByPriority})
+	}
 	baseSchedRef.LogOffersReceived(offers)
 
 	for _, offer := range offers {
@@ -78,7 +72,8 @@ func (s *FirstFit) ConsumeOffers(spc SchedPolicyContext, driver sched.SchedulerD
 			task := baseSchedRef.tasks[i]
 
 			// Don't take offer if it doesn't match our task's host requirement.
-			if offerUtils.HostMismatch(*offer.Hostname, task.Host) {
+			// Retries of failed instances also avoid the hosts on which they failed, if required.
+			if offerUtils.HostMismatch(*offer.Hostname, task.Host) || task.AvoidsHost(*offer.Hostname) {
 				continue
 			}
 
@@ -99,13 +94,8 @@ func (s *FirstFit) ConsumeOffers(spc SchedPolicyContext, driver sched.SchedulerD
 				s.numTasksScheduled++
 
 				if *task.Instances <= 0 {
-					// All instances of task have been scheduled, remove it
-					baseSchedRef.tasks = append(baseSchedRef.tasks[:i], baseSchedRef.tasks[i+1:]...)
-
-					if len(baseSchedRef.tasks) <= 0 {
-						baseSchedRef.LogTerminateScheduler()
-						close(baseSchedRef.Shutdown)
-					}
+					// All instances of task have been scheduled, remove it.
+					baseSchedRef.removeTask(i)
 				}
 				break // Offer taken, move on.
 			}
@@ -20,6 +20,7 @@ package schedulers
 
 import (
 	"fmt"
+	"time"
 
 	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
 	sched "github.com/mesos/mesos-go/api/v0/scheduler"
@@ -29,11 +30,13 @@ import (
 	"github.com/spdfg/elektron/def"
 	elekLog "github.com/spdfg/elektron/logging"
 	. "github.com/spdfg/elektron/logging/types"
+	"github.com/spdfg/elektron/powerCap"
+	"github.com/spdfg/elektron/store"
 	"github.com/spdfg/elektron/utilities"
 	"github.com/spdfg/elektron/utilities/mesosUtils"
 )
 
-func coLocated(tasks map[string]bool, s BaseScheduler) {
+func coLocated(tasks map[string]bool, s *BaseScheduler) {
 
 	for _, task := range tasks {
 		elekLog.WithField("Task", fmt.Sprintf("%v", task)).Log(CONSOLE, log.InfoLevel, "")
@@ -77,6 +80,105 @@ func WithTasks(ts []def.Task) SchedulerOptions {
 	}
 }
 
+func WithLongRunning(longRunning bool) SchedulerOptions {
+	return func(s ElectronScheduler) error {
+		s.(*BaseScheduler).longRunning = longRunning
+		return nil
+	}
+}
+
+func WithStore(journal store.Store) SchedulerOptions {
+	return func(s ElectronScheduler) error {
+		if journal == nil {
+			return errors.New("Store cannot be nil.")
+		}
+		s.(*BaseScheduler).store = journal
+		return nil
+	}
+}
+
+func WithRequeueOnAgentLoss(requeueOnAgentLoss bool) SchedulerOptions {
+	return func(s ElectronScheduler) error {
+		s.(*BaseScheduler).requeueOnAgentLoss = requeueOnAgentLoss
+		return nil
+	}
+}
+
+func WithOwnerWeights(ownerWeights map[string]float64) SchedulerOptions {
+	return func(s ElectronScheduler) error {
+		if ownerWeights == nil {
+			return errors.New("Owner weights cannot be nil.")
+		}
+		s.(*BaseScheduler).ownerWeights = ownerWeights
+		return nil
+	}
+}
+
+// Clock giving the current time, which is the wall clock by default.
+// The clock of the driver is used when simulating, as time is then simulated.
+func WithClock(now func() time.Time) SchedulerOptions {
+	return func(s ElectronScheduler) error {
+		if now == nil {
+			return errors.New("Clock cannot be nil.")
+		}
+		s.(*BaseScheduler).now = now
+		return nil
+	}
+}
+
+func WithEnergyBudget(budgetJoules float64, horizonSeconds float64) SchedulerOptions {
+	return func(s ElectronScheduler) error {
+		if budgetJoules <= 0.0 {
+			return errors.New("Energy budget must be positive.")
+		}
+		if horizonSeconds <= 0.0 {
+			return errors.New("Horizon of the energy budget must be positive.")
+		}
+		s.(*BaseScheduler).energyBudget = newEnergyBudget(budgetJoules,
+			time.Duration(horizonSeconds*float64(time.Second)))
+		return nil
+	}
+}
+
+func WithStragglerThreshold(threshold float64) SchedulerOptions {
+	return func(s ElectronScheduler) error {
+		if threshold < 0.0 {
+			return errors.New("Straggler threshold cannot be negative.")
+		}
+		s.(*BaseScheduler).stragglerThreshold = threshold
+		return nil
+	}
+}
+
+func WithCapStates(capStates *powerCap.CapStates) SchedulerOptions {
+	return func(s ElectronScheduler) error {
+		if capStates == nil {
+			return errors.New("Cap states cannot be nil.")
+		}
+		s.(*BaseScheduler).capStates = capStates
+		return nil
+	}
+}
+
+func WithOfferRanking(comparatorNames []string) SchedulerOptions {
+	return func(s ElectronScheduler) error {
+		baseSchedRef := s.(*BaseScheduler)
+		ranking, err := baseSchedRef.offerRankingFromNames(comparatorNames)
+		if err != nil {
+			return err
+		}
+		baseSchedRef.offerRanking = ranking
+		return nil
+	}
+}
+
+func WithAffinityRules(rules def.AffinityRules) SchedulerOptions {
+	return func(s ElectronScheduler) error {
+		s.(*BaseScheduler).affinityRules = rules
+		return nil
+	}
+}
+
 func WithWattsAsAResource(waar bool) SchedulerOptions {
 	return func(s ElectronScheduler) error {
 		s.(*BaseScheduler).wattsAsAResource = waar
@@ -186,6 +288,45 @@ func LaunchTasks(offerIDs []*mesos.OfferID, tasksToLaunch []*mesos.TaskInfo, dri
 }
 
 // Sort N tasks in the TaskQueue
-func SortNTasks(tasks []def.Task, n int, sb def.SortBy) {
-	def.SortTasks(tasks[:n], sb)
+func SortNTasks(tasks []def.Task, n int, ordering def.Ordering) {
+	// Scheduling policies could be shown fewer tasks than there are in the scheduling window
+	// (for example, when resources are shared fairly between the owners of tasks).
+	if n > len(tasks) {
+		n = len(tasks)
+	}
+	def.SortTasksBy(tasks[:n], ordering)
+}
+
+// Distinct priorities of the tasks in the TaskQueue, in the order in which they appear.
+// The TaskQueue is assumed to be sorted by priority.
+func taskPriorities(tasks []def.Task) []int {
+	var priorities []int
+	for i, task := range tasks {
+		if (i == 0) || (task.Priority != tasks[i-1].Priority) {
+			priorities = append(priorities, task.Priority)
+		}
+	}
+	return priorities
+}
+
+// Range [lo, hi) of the tasks in the TaskQueue that have the given priority.
+// The TaskQueue is assumed to be sorted by priority.
+func priorityBand(tasks []def.Task, priority int) (int, int) {
+	lo := 0
+	for (lo < len(tasks)) && (tasks[lo].Priority != priority) {
+		lo++
+	}
+	hi := lo
+	for (hi < len(tasks)) && (tasks[hi].Priority == priority) {
+		hi++
+	}
+	return lo, hi
+}
+
+// Number of tasks in the TaskQueue that have the given priority.
+// As tasks are removed from the TaskQueue once all their instances have been scheduled, this
+// needs to be re-evaluated after scheduling a task.
+func bandSize(tasks []def.Task, priority int) int {
+	lo, hi := priorityBand(tasks, priority)
+	return hi - lo
 }
@@ -0,0 +1,44 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"testing"
+
+	"github.com/spdfg/elektron/def"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriorityBand(t *testing.T) {
+	tasks := []def.Task{
+		{Name: "task1", Priority: 2},
+		{Name: "task2", Priority: 1},
+		{Name: "task3", Priority: 1},
+		{Name: "task4", Priority: 0},
+	}
+	assert.Equal(t, []int{2, 1, 0}, taskPriorities(tasks))
+
+	lo, hi := priorityBand(tasks, 1)
+	assert.Equal(t, 1, lo)
+	assert.Equal(t, 3, hi)
+	assert.Equal(t, 2, bandSize(tasks, 1))
+	assert.Equal(t, 1, bandSize(tasks, 0))
+	assert.Equal(t, 0, bandSize(tasks, 5))
+	assert.Empty(t, taskPriorities(nil))
+}
@@ -0,0 +1,56 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"github.com/spdfg/elektron/pcp"
+)
+
+// MonitorPower receives the samples recorded by PCP, and keeps track of the power consumed by
+// the hosts, of the energy consumed by the cluster and of the energy attributed to the task instances.
+// Samples need to be received until the stream of samples ends.
+func (s *BaseScheduler) MonitorPower(samples <-chan pcp.Sample) {
+	for sample := range samples {
+		s.powerHistoryMutex.Lock()
+		s.powerHistory.Record(sample)
+		s.powerHistoryMutex.Unlock()
+		now := s.now()
+		if s.energyBudget != nil {
+			s.energyBudget.recordSample(now, sample.Watts())
+		}
+		s.energyAttribution.attribute(now, sample)
+	}
+}
+
+// HostWatts returns the average power recently consumed by the host, as reported by PCP,
+// and whether the power consumed by the host is being monitored.
+func (s *BaseScheduler) HostWatts(host string) (float64, bool) {
+	s.powerHistoryMutex.Lock()
+	defer s.powerHistoryMutex.Unlock()
+	return s.powerHistory.HostWatts(host)
+}
+
+// HostCapped returns whether the host is currently power capped.
+// Hosts are never capped if power capping is disabled.
+func (s *BaseScheduler) HostCapped(host string) bool {
+	if s.capStates == nil {
+		return false
+	}
+	return s.capStates.Capped(host)
+}
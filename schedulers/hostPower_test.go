@@ -0,0 +1,56 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spdfg/elektron/pcp"
+	"github.com/spdfg/elektron/powerCap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseScheduler_MonitorPower(t *testing.T) {
+	s := &BaseScheduler{
+		powerHistory:      pcp.NewPowerHistory(),
+		energyAttribution: newEnergyAttribution(),
+		now:               time.Now,
+	}
+	samples := make(chan pcp.Sample, 1)
+	samples <- pcp.Sample{Hosts: map[string]pcp.HostSample{
+		"host1": {PKGWatts: []float64{10.0, 10.0}, DRAMWatts: []float64{5.0, 5.0}},
+	}}
+	close(samples)
+	s.MonitorPower(samples)
+
+	watts, ok := s.HostWatts("host1")
+	assert.True(t, ok)
+	assert.Equal(t, 30.0, watts)
+	_, ok = s.HostWatts("host2")
+	assert.False(t, ok, "power of unmonitored host reported")
+}
+
+func TestBaseScheduler_HostCapped(t *testing.T) {
+	s := &BaseScheduler{}
+	assert.False(t, s.HostCapped("host1"), "host capped without power capping")
+
+	assert.NoError(t, WithCapStates(powerCap.NewCapStates())(s))
+	assert.False(t, s.HostCapped("host1"))
+}
@@ -0,0 +1,325 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/pkg/errors"
+	"github.com/spdfg/elektron/def"
+	"github.com/spdfg/elektron/store"
+)
+
+// Types of the entries that are journaled.
+const (
+	snapshotEntry            = "snapshot"
+	frameworkRegisteredEntry = "frameworkRegistered"
+	tasksEnqueuedEntry       = "tasksEnqueued"
+	instanceLaunchedEntry    = "instanceLaunched"
+	instanceTerminatedEntry  = "instanceTerminated"
+	instancesCancelledEntry  = "instancesCancelled"
+	schedPolicySwitchedEntry = "schedPolicySwitched"
+)
+
+// Number of entries beyond which the journal is compacted into a snapshot.
+const journalCompactionThreshold = 1000
+
+// Task instance that has terminated, along with how the termination was handled.
+type terminatedInstance struct {
+	TaskID string `json:"taskID"`
+	State  string `json:"state"`
+	// Retry of the instance, if it did not finish successfully and is retried.
+	Retry *persistedTask `json:"retry,omitempty"`
+	// Whether the retry has to wait for its backoff to elapse.
+	BackedOff bool `json:"backedOff,omitempty"`
+	// Whether the instance was killed on request, in which case it is neither retried nor fails its task.
+	Killed bool `json:"killed,omitempty"`
+}
+
+// Pending instances of a task that were cancelled.
+type cancelledInstances struct {
+	TaskName  string `json:"taskName"`
+	Instances int    `json:"instances"`
+}
+
+// Append an entry to the journal, if the state of the scheduler is being journaled.
+func (s *BaseScheduler) appendEntry(entryType string, data interface{}) error {
+	if s.store == nil {
+		return nil
+	}
+	entry, err := store.NewEntry(entryType, data)
+	if err != nil {
+		return errors.Wrap(err, "Failed to create "+entryType+" entry")
+	}
+	return errors.Wrap(s.store.Append(entry), "Failed to journal "+entryType+" entry")
+}
+
+// Journal a change to the state of the scheduler.
+// The framework keeps running if the change could not be journaled, but might not be able to
+// fully recover from a restart.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) journal(entryType string, data interface{}) {
+	if err := s.appendEntry(entryType, data); err != nil {
+		s.LogElectronError(err)
+	}
+}
+
+// Compact the journal into a snapshot of the state of the scheduler, once it has grown large enough.
+// Needs to be called with the task queue locked, and when no task instance is being launched.
+func (s *BaseScheduler) compactJournalIfNecessary() {
+	if (s.store == nil) || (s.store.Len() < journalCompactionThreshold) {
+		return
+	}
+	snapshot, err := store.NewEntry(snapshotEntry, s.snapshot())
+	if err != nil {
+		s.LogElectronError(errors.Wrap(err, "Failed to create snapshot"))
+		return
+	}
+	if err := s.store.Compact(snapshot); err != nil {
+		s.LogElectronError(errors.Wrap(err, "Failed to compact journal"))
+	}
+}
+
+// Snapshot of the state of the scheduler.
+func (s *BaseScheduler) snapshot() persistedState {
+	state := persistedState{
+		Tasks:               toPersistedTasks(s.tasks),
+		BlockedTasks:        toPersistedTasks(s.blockedTasks),
+		BackedOffRetries:    toPersistedTasks(s.backedOffRetries),
+		UnfinishedInstances: s.unfinishedInstances,
+		CompletedTasks:      sortedKeys(s.completedTasks),
+		FailedTasks:         sortedKeys(s.failedTasks),
+		SchedPolicy:         schedPolicyName(s.curSchedPolicy),
+	}
+	if s.frameworkID != nil {
+		state.FrameworkID = s.frameworkID.GetValue()
+	}
+	for taskID, instance := range s.launchedInstances {
+		state.LaunchedInstances = append(state.LaunchedInstances, persistedInstance{
+			TaskID:   taskID,
+			Task:     toPersistedTask(instance.task),
+			Instance: instance.instance,
+			SlaveID:  instance.slaveID,
+			Host:     instance.host,
+		})
+	}
+	sort.Slice(state.LaunchedInstances, func(i, j int) bool {
+		return state.LaunchedInstances[i].TaskID < state.LaunchedInstances[j].TaskID
+	})
+	return state
+}
+
+// Name of the given scheduling policy, if it is one of the pluggable scheduling policies.
+func schedPolicyName(schedPolicy SchedPolicyState) string {
+	for name, sp := range SchedPolicies {
+		if sp == schedPolicy {
+			return name
+		}
+	}
+	return ""
+}
+
+// Rebuild the state of the scheduler by replaying the journal.
+// Returns whether there was any state to rebuild.
+// Nothing is logged while replaying, as the state is rebuilt before the loggers are built.
+func (s *BaseScheduler) restoreState() (bool, error) {
+	restored := false
+	err := s.store.Replay(func(entry store.Entry) error {
+		restored = true
+		return errors.Wrap(s.applyEntry(entry), "Failed to replay "+entry.Type+" entry")
+	})
+	return restored, err
+}
+
+// Apply a journaled change to the state of the scheduler.
+func (s *BaseScheduler) applyEntry(entry store.Entry) error {
+	switch entry.Type {
+	case snapshotEntry:
+		var state persistedState
+		if err := json.Unmarshal(entry.Data, &state); err != nil {
+			return err
+		}
+		s.applySnapshot(state)
+	case frameworkRegisteredEntry:
+		var frameworkID string
+		if err := json.Unmarshal(entry.Data, &frameworkID); err != nil {
+			return err
+		}
+		s.frameworkID = &mesos.FrameworkID{Value: proto.String(frameworkID)}
+	case tasksEnqueuedEntry:
+		var tasks []persistedTask
+		if err := json.Unmarshal(entry.Data, &tasks); err != nil {
+			return err
+		}
+		enqueued := fromPersistedTasks(tasks)
+		// The instances that are yet to be launched are launched in the current run.
+		def.RecordTaskResourceRequirements(s.runID, enqueued)
+		s.countSubmittedInstances(enqueued)
+		s.addTasks(enqueued)
+	case instanceLaunchedEntry:
+		var instance persistedInstance
+		if err := json.Unmarshal(entry.Data, &instance); err != nil {
+			return err
+		}
+		s.applyInstanceLaunched(instance)
+	case instanceTerminatedEntry:
+		var terminated terminatedInstance
+		if err := json.Unmarshal(entry.Data, &terminated); err != nil {
+			return err
+		}
+		s.applyInstanceTerminated(terminated)
+	case instancesCancelledEntry:
+		var cancelled cancelledInstances
+		if err := json.Unmarshal(entry.Data, &cancelled); err != nil {
+			return err
+		}
+		s.cancelInstances(cancelled.TaskName, cancelled.Instances)
+		s.instancesFinished(cancelled.TaskName, cancelled.Instances)
+	case schedPolicySwitchedEntry:
+		var name string
+		if err := json.Unmarshal(entry.Data, &name); err != nil {
+			return err
+		}
+		if schedPolicy, ok := SchedPolicies[name]; ok {
+			s.curSchedPolicy = schedPolicy
+		}
+	default:
+		return errors.New(fmt.Sprintf("unknown entry type %s", entry.Type))
+	}
+	return nil
+}
+
+func (s *BaseScheduler) applySnapshot(state persistedState) {
+	s.frameworkID = nil
+	if state.FrameworkID != "" {
+		s.frameworkID = &mesos.FrameworkID{Value: proto.String(state.FrameworkID)}
+	}
+	s.tasks = fromPersistedTasks(state.Tasks)
+	s.blockedTasks = fromPersistedTasks(state.BlockedTasks)
+	s.backedOffRetries = fromPersistedTasks(state.BackedOffRetries)
+	def.RecordTaskResourceRequirements(s.runID, s.tasks)
+	def.RecordTaskResourceRequirements(s.runID, s.blockedTasks)
+	def.RecordTaskResourceRequirements(s.runID, s.backedOffRetries)
+	s.countSubmittedInstances(s.tasks)
+	s.countSubmittedInstances(s.blockedTasks)
+	s.countSubmittedInstances(s.backedOffRetries)
+	s.unfinishedInstances = make(map[string]int)
+	for name, instances := range state.UnfinishedInstances {
+		s.unfinishedInstances[name] = instances
+	}
+	s.completedTasks = make(map[string]bool)
+	for _, name := range state.CompletedTasks {
+		s.completedTasks[name] = true
+	}
+	s.failedTasks = make(map[string]bool)
+	for _, name := range state.FailedTasks {
+		s.failedTasks[name] = true
+	}
+	s.launchedInstances = make(map[string]launchedInstance)
+	// The launched instances are no longer part of the task queue of the snapshot.
+	for _, instance := range state.LaunchedInstances {
+		s.trackLaunchedInstance(instance)
+	}
+	if schedPolicy, ok := SchedPolicies[state.SchedPolicy]; ok {
+		s.curSchedPolicy = schedPolicy
+	}
+}
+
+// Track the launched task instance.
+// The instance is yet to be reconciled, as it is not known whether it is still running.
+func (s *BaseScheduler) trackLaunchedInstance(instance persistedInstance) {
+	task := instance.Task.toTask()
+	def.RecordInstanceResourceRequirements(instance.TaskID, task)
+	s.countSubmittedInstance(task.Name, instance.Instance)
+	s.launchedInstances[instance.TaskID] = launchedInstance{
+		task:      task,
+		instance:  instance.Instance,
+		slaveID:   instance.SlaveID,
+		host:      instance.Host,
+		recovered: true,
+	}
+}
+
+// Track the launched task instance, which is removed from the task queue.
+func (s *BaseScheduler) applyInstanceLaunched(instance persistedInstance) {
+	s.trackLaunchedInstance(instance)
+	task := instance.Task.toTask()
+
+	// The instance was scheduled either from the task queue or, if it is a retry whose backoff
+	// elapsed, from the retries of failed instances.
+	sameTask := func(queued def.Task) bool {
+		if queued.Name != task.Name {
+			return false
+		}
+		if (queued.Retry == nil) || (task.Retry == nil) {
+			// Tasks of the same name that were submitted separately index their instances differently.
+			return (queued.Retry == nil) && (task.Retry == nil) && (queued.InstanceOffset == task.InstanceOffset)
+		}
+		return (queued.Retry.Instance == task.Retry.Instance) && (queued.Retry.Attempt == task.Retry.Attempt)
+	}
+	scheduleFrom := func(queue []def.Task) ([]def.Task, bool) {
+		for i, queued := range queue {
+			if sameTask(queued) {
+				*queued.Instances--
+				if *queued.Instances <= 0 {
+					queue = append(queue[:i], queue[i+1:]...)
+				}
+				return queue, true
+			}
+		}
+		return queue, false
+	}
+	var scheduled bool
+	if s.tasks, scheduled = scheduleFrom(s.tasks); !scheduled {
+		s.backedOffRetries, _ = scheduleFrom(s.backedOffRetries)
+	}
+}
+
+// Stop tracking the terminated task instance, and either retry it or update the completion of its task.
+// Returns the tasks that were released, if the task completed, and the tasks that failed, if the
+// instance failed.
+func (s *BaseScheduler) applyInstanceTerminated(terminated terminatedInstance) ([]def.Task, []def.Task) {
+	instance, ok := s.launchedInstances[terminated.TaskID]
+	if !ok {
+		return nil, nil
+	}
+	delete(s.launchedInstances, terminated.TaskID)
+	// The resources of the instance have been released, and a retry is recorded for this run.
+	def.ForgetInstanceResourceRequirements(terminated.TaskID)
+
+	if terminated.Retry != nil {
+		retry := terminated.Retry.toTask()
+		// The instance could have been launched in an earlier run.
+		def.RecordTaskResourceRequirements(s.runID, []def.Task{retry})
+		if terminated.BackedOff && s.now().Before(retry.Retry.NotBefore) {
+			s.backedOffRetries = append(s.backedOffRetries, retry)
+		} else {
+			s.tasks = append(s.tasks, retry)
+		}
+		return nil, nil
+	}
+	if terminated.Killed || (terminated.State == mesos.TaskState_TASK_FINISHED.String()) {
+		return s.instancesFinished(instance.task.Name, 1), nil
+	}
+	return nil, s.failTask(instance.task.Name)
+}
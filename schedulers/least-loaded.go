@@ -0,0 +1,134 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	sched "github.com/mesos/mesos-go/api/v0/scheduler"
+	"github.com/spdfg/elektron/def"
+	"github.com/spdfg/elektron/utilities/mesosUtils"
+	"github.com/spdfg/elektron/utilities/offerUtils"
+)
+
+// Load of the host of an offer, and the tasks placed on it in the current offer cycle.
+type offerLoad struct {
+	offer *mesos.Offer
+	tasks []*mesos.TaskInfo
+	// Power consumed by the host of the offer, along with the power requirement of the tasks placed on it.
+	watts float64
+}
+
+// Decides if to take an offer or not
+func (s *LeastLoaded) takeOffer(spc SchedPolicyContext, offer *mesos.Offer, task def.Task) bool {
+	baseSchedRef := spc.(*BaseScheduler)
+	return !baseSchedRef.violatesConstraints(offer, task) && baseSchedRef.fits(offer, task)
+}
+
+// Scheduling policy that places every task instance on the least loaded host that it fits on.
+// The load of a host is the power that it has recently consumed, as reported by PCP, along with the
+// power requirement of the tasks that have been placed on it in the current offer cycle.
+// Hosts whose power consumption is not being monitored are considered to be idle.
+type LeastLoaded struct {
+	BaseSchedPolicyState
+}
+
+func (s *LeastLoaded) ConsumeOffers(spc SchedPolicyContext, driver sched.SchedulerDriver, offers []*mesos.Offer) {
+	baseSchedRef := spc.(*BaseScheduler)
+	// Tasks of higher priority are scheduled first.
+	if baseSchedRef.schedPolSwitchEnabled {
+		SortNTasks(baseSchedRef.tasks, baseSchedRef.numTasksInSchedWindow, def.Ordering{def.SortByPriority})
+	} else {
+		def.SortTasksBy(baseSchedRef.tasks, def.Ordering{def.SortByPriority})
+	}
+	baseSchedRef.LogOffersReceived(offers)
+
+	loads := make([]*offerLoad, 0, len(offers))
+	for _, offer := range offers {
+		offerUtils.UpdateEnvironment(offer)
+		select {
+		case <-baseSchedRef.Shutdown:
+			baseSchedRef.LogNoPendingTasksDeclineOffers(offer)
+			driver.DeclineOffer(offer.Id, mesosUtils.LongFilter)
+			baseSchedRef.LogNumberOfRunningTasks()
+			continue
+		default:
+		}
+		// Hosts whose power consumption is not being monitored are considered to be idle.
+		watts, _ := baseSchedRef.HostWatts(offer.GetHostname())
+		loads = append(loads, &offerLoad{offer: offer, watts: watts})
+	}
+
+	for i := 0; i < len(baseSchedRef.tasks); i++ {
+		task := baseSchedRef.tasks[i]
+		for *task.Instances > 0 {
+			// If scheduling policy switching enabled, then
+			// stop scheduling if the #baseSchedRef.schedWindowSize tasks have been scheduled.
+			if baseSchedRef.schedPolSwitchEnabled && (s.numTasksScheduled >= baseSchedRef.schedWindowSize) {
+				break // Offers will automatically get declined.
+			}
+
+			// Finding the least loaded host that the task fits on.
+			var leastLoaded *offerLoad
+			for _, load := range loads {
+				// Don't take offer if it doesn't match our task's host requirement.
+				// Retries of failed instances also avoid the hosts on which they failed, if required.
+				if offerUtils.HostMismatch(load.offer.GetHostname(), task.Host) ||
+					task.AvoidsHost(load.offer.GetHostname()) {
+					continue
+				}
+				if !s.takeOffer(spc, load.offer, task) {
+					continue
+				}
+				if leastLoaded == nil || load.watts < leastLoaded.watts {
+					leastLoaded = load
+				}
+			}
+			if leastLoaded == nil {
+				break // Continue on to next task
+			}
+
+			leastLoaded.watts += baseSchedRef.estimatedWatts(task, leastLoaded.offer)
+			baseSchedRef.LogCoLocatedTasks(leastLoaded.offer.GetSlaveId().GoString())
+			taskToSchedule := baseSchedRef.newTask(leastLoaded.offer, task)
+			leastLoaded.tasks = append(leastLoaded.tasks, taskToSchedule)
+
+			baseSchedRef.LogSchedTrace(taskToSchedule, leastLoaded.offer)
+			*task.Instances--
+			s.numTasksScheduled++
+		}
+
+		if *task.Instances <= 0 {
+			// All instances of task have been scheduled, remove it.
+			baseSchedRef.removeTask(i)
+			i--
+		}
+	}
+
+	for _, load := range loads {
+		if len(load.tasks) > 0 {
+			baseSchedRef.LogTaskStarting(nil, load.offer)
+			LaunchTasks([]*mesos.OfferID{load.offer.Id}, load.tasks, driver)
+		} else {
+			// If there was no match for the task
+			cpus, mem, watts := offerUtils.OfferAgg(load.offer)
+			baseSchedRef.LogInsufficientResourcesDeclineOffer(load.offer, cpus, mem, watts)
+			driver.DeclineOffer(load.offer.Id, mesosUtils.DefaultFilter)
+		}
+	}
+}
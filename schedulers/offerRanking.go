@@ -0,0 +1,79 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"fmt"
+	"strings"
+
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/pkg/errors"
+	"github.com/spdfg/elektron/utilities"
+	"github.com/spdfg/elektron/utilities/offerUtils"
+)
+
+// Names of the comparators by which offers can be ranked.
+const (
+	rankByWatts      = "watts"
+	rankByCPU        = "cpu"
+	rankByMem        = "mem"
+	rankByPowerClass = "powerClass"
+	rankByHeadroom   = "headroom"
+)
+
+// OfferComparatorNames are the names of the comparators by which offers can be ranked.
+var OfferComparatorNames = []string{rankByWatts, rankByCPU, rankByMem, rankByPowerClass, rankByHeadroom}
+
+// Ranking of offers made up of the comparators with the given names, in order of precedence.
+func (s *BaseScheduler) offerRankingFromNames(names []string) (offerUtils.OfferRanking, error) {
+	comparators := map[string]offerUtils.OfferComparator{
+		rankByWatts:      offerUtils.ByRemainingWatts,
+		rankByCPU:        offerUtils.ByCPU,
+		rankByMem:        offerUtils.ByMem,
+		rankByPowerClass: offerUtils.ByPowerClass,
+		rankByHeadroom:   offerUtils.ByDescending(s.powerHeadroom),
+	}
+	var ranking offerUtils.OfferRanking
+	for _, name := range names {
+		comparator, ok := comparators[strings.TrimSpace(name)]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Invalid offer comparator %s. Available comparators: %s",
+				name, strings.Join(OfferComparatorNames, ", ")))
+		}
+		ranking = append(ranking, comparator)
+	}
+	return ranking, nil
+}
+
+// Difference between the power cap of the host of the offer and the power that the host recently
+// consumed, as reported by PCP.
+// The power cap of a host is the share of the watts offered by the host, when its offers were first
+// received, as determined by the power capping policy. Hosts that are not capped have a power cap of 100%.
+// Hosts whose power consumption is not being monitored are considered to be idle.
+func (s *BaseScheduler) powerHeadroom(offer *mesos.Offer) float64 {
+	capWatts := 0.0
+	if resCount, ok := utilities.GetPerHostResourceAvailability()[offer.GetSlaveId().GetValue()]; ok {
+		capWatts = resCount.TotalWatts
+	}
+	if s.capStates != nil {
+		capWatts *= s.capStates.Percentage(offer.GetHostname()) / 100.0
+	}
+	watts, _ := s.HostWatts(offer.GetHostname())
+	return capWatts - watts
+}
@@ -0,0 +1,75 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/mesos/mesos-go/api/v0/mesosutil"
+	"github.com/spdfg/elektron/pcp"
+	"github.com/spdfg/elektron/powerCap"
+	"github.com/spdfg/elektron/utilities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseScheduler_OfferRankingFromNames(t *testing.T) {
+	s := &BaseScheduler{powerHistory: pcp.NewPowerHistory()}
+	ranking, err := s.offerRankingFromNames([]string{"headroom", " cpu"})
+	assert.NoError(t, err)
+	assert.Len(t, ranking, 2)
+
+	_, err = s.offerRankingFromNames([]string{"disk"})
+	assert.Error(t, err, "unknown comparator accepted")
+}
+
+func TestBaseScheduler_PowerHeadroom(t *testing.T) {
+	var offers []*mesos.Offer
+	for _, host := range []string{"headroomHost1", "headroomHost2", "headroomHost3"} {
+		offers = append(offers, &mesos.Offer{
+			Id:        &mesos.OfferID{Value: proto.String(host)},
+			SlaveId:   &mesos.SlaveID{Value: proto.String(host)},
+			Hostname:  proto.String(host),
+			Resources: []*mesos.Resource{mesosutil.NewScalarResource("watts", 200.0)},
+		})
+		defer utilities.RemoveResourceAvailability(host)
+	}
+	utilities.RecordTotalResourceAvailability(offers)
+
+	s := &BaseScheduler{powerHistory: pcp.NewPowerHistory(), capStates: powerCap.NewCapStates()}
+	s.powerHistory.Record(pcp.Sample{Hosts: map[string]pcp.HostSample{
+		"headroomHost1": {PKGWatts: []float64{60.0, 60.0}, DRAMWatts: []float64{10.0, 10.0}},
+		"headroomHost2": {PKGWatts: []float64{20.0, 20.0}, DRAMWatts: []float64{10.0, 10.0}},
+	}})
+	// Capping headroomHost2 at 50% leaves it with less headroom than headroomHost1.
+	s.capStates.Record(powerCap.CapAction{Kind: powerCap.Cap, Host: "headroomHost2", Percentage: 50.0})
+
+	assert.Equal(t, 60.0, s.powerHeadroom(offers[0]))
+	assert.Equal(t, 40.0, s.powerHeadroom(offers[1]))
+	// Hosts whose power consumption is not being monitored are considered to be idle.
+	assert.Equal(t, 200.0, s.powerHeadroom(offers[2]))
+
+	ranking, err := s.offerRankingFromNames([]string{"headroom"})
+	assert.NoError(t, err)
+	ranking.Sort(offers)
+	assert.Equal(t, "headroomHost3", offers[0].GetHostname())
+	assert.Equal(t, "headroomHost1", offers[1].GetHostname())
+	assert.Equal(t, "headroomHost2", offers[2].GetHostname())
+}
@@ -0,0 +1,164 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	sched "github.com/mesos/mesos-go/api/v0/scheduler"
+	"github.com/pkg/errors"
+	"github.com/spdfg/elektron/def"
+	"github.com/spdfg/elektron/utilities"
+)
+
+// Task along with its retry information and the index of its instances, which are otherwise not serialized.
+type persistedTask struct {
+	def.Task
+	InstanceOffset int        `json:"instanceOffset,omitempty"`
+	Retry          *def.Retry `json:"retry,omitempty"`
+}
+
+// Launched task instance that has not yet terminated.
+type persistedInstance struct {
+	TaskID   string        `json:"taskID"`
+	Task     persistedTask `json:"task"`
+	Instance int           `json:"instance"`
+	SlaveID  string        `json:"slaveID"`
+	Host     string        `json:"host"`
+}
+
+// Snapshot of the state of the scheduler, so that the framework can survive a restart of the scheduler.
+type persistedState struct {
+	FrameworkID         string              `json:"frameworkID"`
+	Tasks               []persistedTask     `json:"tasks"`
+	BlockedTasks        []persistedTask     `json:"blockedTasks"`
+	BackedOffRetries    []persistedTask     `json:"backedOffRetries"`
+	LaunchedInstances   []persistedInstance `json:"launchedInstances"`
+	UnfinishedInstances map[string]int      `json:"unfinishedInstances"`
+	CompletedTasks      []string            `json:"completedTasks"`
+	FailedTasks         []string            `json:"failedTasks"`
+	// Scheduling policy that was deployed last.
+	SchedPolicy string `json:"schedPolicy,omitempty"`
+}
+
+func toPersistedTasks(tasks []def.Task) []persistedTask {
+	persisted := make([]persistedTask, 0, len(tasks))
+	for _, task := range tasks {
+		persisted = append(persisted, toPersistedTask(task))
+	}
+	return persisted
+}
+
+func toPersistedTask(task def.Task) persistedTask {
+	return persistedTask{Task: task, InstanceOffset: task.InstanceOffset, Retry: task.Retry}
+}
+
+func fromPersistedTasks(persisted []persistedTask) []def.Task {
+	tasks := make([]def.Task, 0, len(persisted))
+	for _, task := range persisted {
+		tasks = append(tasks, task.toTask())
+	}
+	return tasks
+}
+
+func (p persistedTask) toTask() def.Task {
+	task := p.Task
+	task.InstanceOffset = p.InstanceOffset
+	task.Retry = p.Retry
+	return task
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// FrameworkID returns the ID of the framework, if it has been registered or restored from the journal.
+func (s *BaseScheduler) FrameworkID() *mesos.FrameworkID {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.frameworkID
+}
+
+// Explicitly reconcile the task instances that have been launched, so that the instances that are
+// still running are tracked again, and the instances that terminated while the scheduler was
+// disconnected are accounted for.
+func (s *BaseScheduler) reconcileTasks(driver sched.SchedulerDriver) {
+	s.mutex.Lock()
+	taskIDs := make([]string, 0, len(s.launchedInstances))
+	for taskID := range s.launchedInstances {
+		taskIDs = append(taskIDs, taskID)
+	}
+	sort.Strings(taskIDs)
+	statuses := make([]*mesos.TaskStatus, 0, len(taskIDs))
+	for _, taskID := range taskIDs {
+		statuses = append(statuses, &mesos.TaskStatus{
+			TaskId:  &mesos.TaskID{Value: proto.String(taskID)},
+			SlaveId: &mesos.SlaveID{Value: proto.String(s.launchedInstances[taskID].slaveID)},
+			State:   mesos.TaskState_TASK_STAGING.Enum(),
+		})
+	}
+	s.mutex.Unlock()
+
+	if len(statuses) == 0 {
+		return
+	}
+	s.LogReconcilingTasks(len(statuses))
+	if _, err := driver.ReconcileTasks(statuses); err != nil {
+		s.LogElectronError(errors.Wrap(err, "Failed to reconcile tasks"))
+	}
+}
+
+// Track the task instance corresponding to the status as running.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) instanceRunning(status *mesos.TaskStatus) {
+	taskID := status.GetTaskId().GetValue()
+	slaveID := status.GetSlaveId().GetValue()
+	if instance, ok := s.launchedInstances[taskID]; ok && instance.recovered {
+		// Instances recovered from the journal are yet to be accounted for in the resource
+		// availability of their agent.
+		utilities.ResourceAvailabilityUpdate("ON_TASK_RECOVERED", *status.TaskId, *status.SlaveId)
+		instance.recovered = false
+		s.launchedInstances[taskID] = instance
+	}
+	if instance, ok := s.launchedInstances[taskID]; ok && instance.started.IsZero() {
+		instance.started = s.statusTime(status)
+		s.launchedInstances[taskID] = instance
+		s.energyAttribution.instanceStarted(taskID, instance.host, instance.watts)
+	}
+
+	s.TasksRunningMutex.Lock()
+	defer s.TasksRunningMutex.Unlock()
+	// If this is our first time running into this Agent
+	if _, ok := s.Running[slaveID]; !ok {
+		s.Running[slaveID] = make(map[string]bool)
+	}
+	// Add task to list of tasks running on node.
+	// Status updates can be repeated (for example, when reconciling).
+	if !s.Running[slaveID][taskID] {
+		s.Running[slaveID][taskID] = true
+		s.tasksRunning++
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/spdfg/elektron/def"
+	"github.com/spdfg/elektron/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseScheduler_RestoreState(t *testing.T) {
+	instances := 2
+	minife := def.Task{
+		Name:       "minife",
+		CPU:        3.0,
+		RAM:        4096,
+		Image:      "rdelvalle/minife:electron1",
+		Instances:  &instances,
+		MaxRetries: 2,
+	}
+	dgemmInstances := 2
+	dgemm := def.Task{
+		Name:      "dgemm",
+		CPU:       3.0,
+		RAM:       32,
+		Image:     "rdelvalle/dgemm:electron1",
+		Instances: &dgemmInstances,
+		DependsOn: []string{"minife"},
+	}
+	offer := &mesos.Offer{
+		Id:       &mesos.OfferID{Value: proto.String("offer1")},
+		SlaveId:  &mesos.SlaveID{Value: proto.String("agent1")},
+		Hostname: proto.String("host1"),
+	}
+
+	journal := store.NewMemoryStore()
+	recordPCP := true
+	s := &BaseScheduler{RecordPCP: &recordPCP, Shutdown: make(chan struct{})}
+	s.init(WithStore(journal), WithTasks([]def.Task{minife, dgemm}))
+	s.frameworkID = &mesos.FrameworkID{Value: proto.String("framework")}
+	s.journal(frameworkRegisteredEntry, s.frameworkID.GetValue())
+
+	// Launching both the instances of minife, one of which finishes.
+	for len(s.tasks) > 0 {
+		s.newTask(offer, s.tasks[0])
+		*s.tasks[0].Instances--
+		if *s.tasks[0].Instances <= 0 {
+			s.removeTask(0)
+		}
+	}
+	s.updateTaskCompletion(&mesos.TaskStatus{
+		TaskId:  &mesos.TaskID{Value: proto.String(def.InstanceID{RunID: s.runID, Task: "minife", Instance: 2}.String())},
+		SlaveId: offer.SlaveId,
+		State:   mesos.TaskState_TASK_FINISHED.Enum(),
+	})
+
+	runningTaskID := def.InstanceID{RunID: s.runID, Task: "minife", Instance: 1}.String()
+	assertRestored := func() {
+		restored := &BaseScheduler{}
+		restored.init(WithStore(journal))
+		assert.Equal(t, "framework", restored.FrameworkID().GetValue())
+		assert.Empty(t, restored.tasks)
+		assert.Equal(t, []string{"dgemm"}, taskNames(restored.blockedTasks))
+		assert.Equal(t, map[string]int{"minife": 1, "dgemm": 2}, restored.unfinishedInstances)
+
+		assert.Len(t, restored.launchedInstances, 1)
+		instance, ok := restored.launchedInstances[runningTaskID]
+		assert.True(t, ok)
+		assert.Equal(t, 1, instance.instance)
+		assert.Equal(t, "agent1", instance.slaveID)
+		assert.Equal(t, "host1", instance.host)
+		assert.True(t, instance.recovered)
+		_, err := def.GetResourceRequirement(runningTaskID)
+		assert.NoError(t, err)
+	}
+	assertRestored()
+
+	// The state is restored from the snapshot as well.
+	snapshot, err := store.NewEntry(snapshotEntry, s.snapshot())
+	assert.NoError(t, err)
+	assert.NoError(t, journal.Compact(snapshot))
+	assertRestored()
+}
+
+func TestBaseScheduler_RestoreRetry(t *testing.T) {
+	instances := 1
+	minife := def.Task{
+		Name:       "minife",
+		CPU:        3.0,
+		RAM:        4096,
+		Image:      "rdelvalle/minife:electron1",
+		Instances:  &instances,
+		MaxRetries: 2,
+	}
+	retryInstances := 1
+	retry := minife
+	retry.Instances = &retryInstances
+	retry.Retry = &def.Retry{Instance: 1, Attempt: 1, Retries: 1, FailedHosts: []string{"host1"}}
+
+	journal := store.NewMemoryStore()
+	s := &BaseScheduler{}
+	s.init(WithStore(journal), WithTasks([]def.Task{minife}))
+	s.journal(instanceLaunchedEntry, persistedInstance{
+		TaskID:   "electron-run-minife-1-0",
+		Task:     persistedTask{Task: minife},
+		Instance: 1,
+		SlaveID:  "agent1",
+		Host:     "host1",
+	})
+	s.journal(instanceTerminatedEntry, terminatedInstance{
+		TaskID: "electron-run-minife-1-0",
+		State:  mesos.TaskState_TASK_FAILED.String(),
+		Retry:  &persistedTask{Task: retry, Retry: retry.Retry},
+	})
+
+	restored := &BaseScheduler{}
+	restored.init(WithStore(journal))
+	assert.Empty(t, restored.launchedInstances)
+	assert.Len(t, restored.tasks, 1)
+	assert.Equal(t, 1, *restored.tasks[0].Instances)
+	assert.Equal(t, retry.Retry.FailedHosts, restored.tasks[0].Retry.FailedHosts)
+	assert.Equal(t, 1, restored.tasks[0].Retry.Retries)
+
+	// Launching the retry removes it from the task queue.
+	s.journal(instanceLaunchedEntry, persistedInstance{
+		TaskID:   "electron-run-minife-1-1",
+		Task:     persistedTask{Task: retry, Retry: retry.Retry},
+		Instance: 1,
+		SlaveID:  "agent2",
+		Host:     "host2",
+	})
+	restored = &BaseScheduler{}
+	restored.init(WithStore(journal))
+	assert.Empty(t, restored.tasks)
+	instance, ok := restored.launchedInstances["electron-run-minife-1-1"]
+	assert.True(t, ok)
+	assert.Equal(t, 1, instance.retries())
+}
+
+func TestBaseScheduler_RestoreStateEmptyJournal(t *testing.T) {
+	instances := 1
+	journal := store.NewMemoryStore()
+	s := &BaseScheduler{}
+	s.init(WithStore(journal), WithTasks([]def.Task{
+		{Name: "minife", CPU: 3.0, RAM: 4096, Image: "rdelvalle/minife:electron1", Instances: &instances},
+	}))
+	assert.Nil(t, s.FrameworkID())
+	assert.Equal(t, []string{"minife"}, taskNames(s.tasks))
+	// The workload is journaled.
+	assert.Equal(t, 1, journal.Len())
+}
+
+func taskNames(tasks []def.Task) []string {
+	names := []string{}
+	for _, task := range tasks {
+		names = append(names, task.Name)
+	}
+	return names
+}
+
+func TestBaseScheduler_UniqueTaskIDs(t *testing.T) {
+	newMinife := func(instances int) def.Task {
+		return def.Task{Name: "minife", CPU: 3.0, RAM: 4096, Image: "rdelvalle/minife:electron1", Instances: &instances}
+	}
+	offer := &mesos.Offer{
+		Id:       &mesos.OfferID{Value: proto.String("offer1")},
+		SlaveId:  &mesos.SlaveID{Value: proto.String("agent1")},
+		Hostname: proto.String("host1"),
+	}
+
+	journal := store.NewMemoryStore()
+	recordPCP := true
+	s := &BaseScheduler{RecordPCP: &recordPCP, Shutdown: make(chan struct{})}
+	s.init(WithStore(journal), WithTasks([]def.Task{newMinife(2)}))
+	// Not shutting down once all the instances have been scheduled, as more tasks are submitted.
+	s.longRunning = true
+	var taskIDs []string
+	launch := func() {
+		taskIDs = append(taskIDs, s.newTask(offer, s.tasks[0]).GetTaskId().GetValue())
+		*s.tasks[0].Instances--
+		if *s.tasks[0].Instances <= 0 {
+			s.removeTask(0)
+		}
+	}
+	launch()
+	launch()
+
+	// Resubmitting a task of the same name, whose instances are indexed after the earlier ones.
+	resubmitted := []def.Task{newMinife(2)}
+	s.indexInstances(resubmitted)
+	def.RecordTaskResourceRequirements(s.runID, resubmitted)
+	s.journal(tasksEnqueuedEntry, toPersistedTasks(resubmitted))
+	s.addTasks(resubmitted)
+	launch()
+	// Relaunching a failed instance.
+	retry := s.launchedInstances[taskIDs[0]].retry(true)
+	taskIDs = append(taskIDs, s.newTask(offer, retry).GetTaskId().GetValue())
+
+	assert.Equal(t, []string{
+		def.InstanceID{RunID: s.runID, Task: "minife", Instance: 2}.String(),
+		def.InstanceID{RunID: s.runID, Task: "minife", Instance: 1}.String(),
+		def.InstanceID{RunID: s.runID, Task: "minife", Instance: 4}.String(),
+		def.InstanceID{RunID: s.runID, Task: "minife", Instance: 2, Attempt: 1}.String(),
+	}, taskIDs)
+	for _, taskID := range taskIDs {
+		_, err := def.GetResourceRequirement(taskID)
+		assert.NoError(t, err)
+	}
+
+	// Instances launched after a restart belong to a different run, and the instances of the tasks
+	// submitted after the restart are indexed after the ones that are restored.
+	assertRestored := func() *BaseScheduler {
+		restored := &BaseScheduler{}
+		restored.init(WithStore(journal))
+		assert.NotEqual(t, s.runID, restored.runID)
+		assert.Len(t, restored.tasks, 1)
+		assert.Equal(t, 1, *restored.tasks[0].Instances)
+		assert.Equal(t, 3, restored.tasks[0].InstanceID(restored.runID).Instance)
+		return restored
+	}
+	assertRestored()
+	// The launched instances are not removed from the task queue of a snapshot again.
+	snapshot, err := store.NewEntry(snapshotEntry, s.snapshot())
+	assert.NoError(t, err)
+	assert.NoError(t, journal.Compact(snapshot))
+	restored := assertRestored()
+	// The resource requirements of the restored instances are recorded for the run in which they
+	// were launched, and those of the instances that are yet to be launched for the current run.
+	for _, taskID := range taskIDs {
+		_, err := def.GetResourceRequirement(taskID)
+		assert.NoError(t, err)
+	}
+	_, err = def.GetResourceRequirement(restored.tasks[0].InstanceID(restored.runID).String())
+	assert.NoError(t, err)
+	// Retries of the instances launched before the restart are launched in the current run.
+	retry = restored.launchedInstances[taskIDs[1]].retry(true)
+	persistedRetry := toPersistedTask(retry)
+	restored.applyInstanceTerminated(terminatedInstance{
+		TaskID: taskIDs[1],
+		State:  mesos.TaskState_TASK_FAILED.String(),
+		Retry:  &persistedRetry,
+	})
+	_, err = def.GetResourceRequirement(retry.InstanceID(restored.runID).String())
+	assert.NoError(t, err)
+
+	resubmitted = []def.Task{newMinife(1)}
+	restored.indexInstances(resubmitted)
+	assert.Equal(t, 5, resubmitted[0].InstanceID(restored.runID).Instance)
+}
@@ -52,9 +52,14 @@ type SchedPolicyState interface {
 	})
 	// Switch scheduling policy if necessary.
 	SwitchIfNecessary(SchedPolicyContext)
+	// Initialize the characteristics of the scheduling policy.
+	// Scheduling policies implement this by embedding BaseSchedPolicyState.
+	setCharacteristics(BaseSchedPolicyState)
 }
 
-type baseSchedPolicyState struct {
+// State common to all scheduling policies.
+// Needs to be embedded by every scheduling policy.
+type BaseSchedPolicyState struct {
 	SchedPolicyState
 	// Keep track of the number of tasks that have been scheduled.
 	numTasksScheduled int
@@ -165,7 +170,7 @@ func switchRevRoundRobinBased(baseSchedRef *BaseScheduler) string {
 	return baseSchedRef.curSchedPolicy.GetInfo().prevPolicyName
 }
 
-func (bsps *baseSchedPolicyState) SwitchIfNecessary(spc SchedPolicyContext) {
+func (bsps *BaseSchedPolicyState) SwitchIfNecessary(spc SchedPolicyContext) {
 	baseSchedRef := spc.(*BaseScheduler)
 	// Switching scheduling policy only if feature enabled from CLI.
 	if baseSchedRef.schedPolSwitchEnabled {
@@ -244,7 +249,7 @@ func (bsps *baseSchedPolicyState) SwitchIfNecessary(spc SchedPolicyContext) {
 	}
 }
 
-func (bsps *baseSchedPolicyState) GetInfo() (info struct {
+func (bsps *BaseSchedPolicyState) GetInfo() (info struct {
 	taskDist       float64
 	varCpuShare    float64
 	nextPolicyName string
@@ -257,10 +262,41 @@ func (bsps *baseSchedPolicyState) GetInfo() (info struct {
 	return info
 }
 
-func (bsps *baseSchedPolicyState) UpdateLinks(info struct {
+func (bsps *BaseSchedPolicyState) UpdateLinks(info struct {
 	nextPolicyName string
 	prevPolicyName string
 }) {
 	bsps.nextPolicyName = info.nextPolicyName
 	bsps.prevPolicyName = info.prevPolicyName
 }
+
+func (bsps *BaseSchedPolicyState) setCharacteristics(characteristics BaseSchedPolicyState) {
+	bsps.TaskDistribution = characteristics.TaskDistribution
+	bsps.VarianceCpuSharePerTask = characteristics.VarianceCpuSharePerTask
+}
+
+// Whether the scheduling policy has scheduled all the tasks in the scheduling window.
+// If scheduling policy switching is enabled, then scheduling policies need to stop
+// scheduling once this is true.
+func (bsps *BaseSchedPolicyState) SchedWindowFilled(spc SchedPolicyContext) bool {
+	baseSchedRef := spc.(*BaseScheduler)
+	return baseSchedRef.schedPolSwitchEnabled && (bsps.numTasksScheduled >= baseSchedRef.schedWindowSize)
+}
+
+// ScheduleTask creates the TaskInfo for the next instance of the task at index i in the task queue,
+// to be launched on the given offer. The task is removed from the task queue once all its instances
+// have been scheduled.
+// This allows scheduling policies defined outside this package to schedule tasks.
+func (bsps *BaseSchedPolicyState) ScheduleTask(spc SchedPolicyContext, offer *mesos.Offer, i int) *mesos.TaskInfo {
+	baseSchedRef := spc.(*BaseScheduler)
+	task := baseSchedRef.tasks[i]
+	taskToSchedule := baseSchedRef.newTask(offer, task)
+	baseSchedRef.LogSchedTrace(taskToSchedule, offer)
+	*task.Instances--
+	bsps.numTasksScheduled++
+	if *task.Instances <= 0 {
+		// All instances of task have been scheduled, remove it.
+		baseSchedRef.removeTask(i)
+	}
+	return taskToSchedule
+}
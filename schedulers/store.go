@@ -34,14 +34,42 @@ const (
 	bp  = "bin-packing"
 	mgm = "max-greedymins"
 	mm  = "max-min"
+	eb  = "energy-budget"
+	ll  = "least-loaded"
+	ach = "avoid-capped-hosts"
 )
 
-// Scheduling policy factory
-var SchedPolicies map[string]SchedPolicyState = map[string]SchedPolicyState{
-	ff:  &FirstFit{},
-	bp:  &BinPackSortedWatts{},
-	mgm: &MaxGreedyMins{},
-	mm:  &MaxMin{},
+// Creates the state of a scheduling policy.
+type SchedPolicyFactory func() SchedPolicyState
+
+// Scheduling policies that have been registered, keyed by name.
+var SchedPolicies map[string]SchedPolicyState = make(map[string]SchedPolicyState)
+
+func init() {
+	RegisterPolicy(ff, func() SchedPolicyState { return &FirstFit{} })
+	RegisterPolicy(bp, func() SchedPolicyState { return &BinPackSortedWatts{} })
+	RegisterPolicy(mgm, func() SchedPolicyState { return &MaxGreedyMins{} })
+	RegisterPolicy(mm, func() SchedPolicyState { return &MaxMin{} })
+	RegisterPolicy(eb, func() SchedPolicyState { return &EnergyBudget{} })
+	RegisterPolicy(ll, func() SchedPolicyState { return &LeastLoaded{} })
+	RegisterPolicy(ach, func() SchedPolicyState { return &AvoidCappedHosts{} })
+}
+
+// RegisterPolicy makes a scheduling policy available under the given name.
+// Scheduling policies defined outside this package need to embed BaseSchedPolicyState and
+// should be registered from an init function, before the scheduler is built.
+func RegisterPolicy(name string, factory SchedPolicyFactory) error {
+	if name == "" {
+		return errors.New("scheduling policy name cannot be empty")
+	}
+	if factory == nil {
+		return errors.New("scheduling policy factory cannot be nil")
+	}
+	if _, ok := SchedPolicies[name]; ok {
+		return errors.New("scheduling policy " + name + " already registered")
+	}
+	SchedPolicies[name] = factory()
+	return nil
 }
 
 // Scheduling policies to choose when switching
@@ -55,7 +83,7 @@ var schedPoliciesToSwitch map[int]struct {
 
 // Initialize scheduling policy characteristics using the provided config file.
 func InitSchedPolicyCharacteristics(schedPoliciesConfigFilename string) error {
-	var schedPolConfig map[string]baseSchedPolicyState
+	var schedPolConfig map[string]BaseSchedPolicyState
 	if file, err := os.Open(schedPoliciesConfigFilename); err != nil {
 		return errors.Wrap(err, "Error opening file")
 	} else {
@@ -64,23 +92,10 @@ func InitSchedPolicyCharacteristics(schedPoliciesConfigFilename string) error {
 			return errors.Wrap(err, "Error unmarshalling")
 		}
 
-		// Initializing.
-		// TODO: Be able to unmarshal a schedPolConfig JSON into any number of scheduling policies.
+		// Initializing. Scheduling policies that are not present in the config file
+		// are not considered for switching.
 		for schedPolName, schedPolState := range SchedPolicies {
-			switch t := schedPolState.(type) {
-			case *FirstFit:
-				t.TaskDistribution = schedPolConfig[schedPolName].TaskDistribution
-				t.VarianceCpuSharePerTask = schedPolConfig[schedPolName].VarianceCpuSharePerTask
-			case *BinPackSortedWatts:
-				t.TaskDistribution = schedPolConfig[schedPolName].TaskDistribution
-				t.VarianceCpuSharePerTask = schedPolConfig[schedPolName].VarianceCpuSharePerTask
-			case *MaxMin:
-				t.TaskDistribution = schedPolConfig[schedPolName].TaskDistribution
-				t.VarianceCpuSharePerTask = schedPolConfig[schedPolName].VarianceCpuSharePerTask
-			case *MaxGreedyMins:
-				t.TaskDistribution = schedPolConfig[schedPolName].TaskDistribution
-				t.VarianceCpuSharePerTask = schedPolConfig[schedPolName].VarianceCpuSharePerTask
-			}
+			schedPolState.setCharacteristics(schedPolConfig[schedPolName])
 		}
 
 		// Initialize schedPoliciesToSwitch to allow binary searching for scheduling policy switching.
@@ -0,0 +1,71 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	sched "github.com/mesos/mesos-go/api/v0/scheduler"
+	"github.com/spdfg/elektron/schedulers"
+	"github.com/stretchr/testify/assert"
+)
+
+// Scheduling policy defined outside the schedulers package.
+type testPolicy struct {
+	schedulers.BaseSchedPolicyState
+}
+
+func (s *testPolicy) ConsumeOffers(schedulers.SchedPolicyContext, sched.SchedulerDriver, []*mesos.Offer) {
+}
+
+func TestRegisterPolicy(t *testing.T) {
+	assert.NoError(t, schedulers.RegisterPolicy("test-policy",
+		func() schedulers.SchedPolicyState { return &testPolicy{} }))
+	assert.Contains(t, schedulers.SchedPolicies, "test-policy")
+
+	// Built-in scheduling policies are registered.
+	for _, name := range []string{"first-fit", "bin-packing", "max-min", "max-greedymins"} {
+		assert.Contains(t, schedulers.SchedPolicies, name)
+	}
+
+	assert.Error(t, schedulers.RegisterPolicy("test-policy",
+		func() schedulers.SchedPolicyState { return &testPolicy{} }), "duplicate name registered")
+	assert.Error(t, schedulers.RegisterPolicy("",
+		func() schedulers.SchedPolicyState { return &testPolicy{} }), "empty name registered")
+	assert.Error(t, schedulers.RegisterPolicy("nil-policy", nil), "nil factory registered")
+
+	config, err := ioutil.TempFile("", "schedPolConfig")
+	assert.NoError(t, err)
+	defer os.Remove(config.Name())
+	_, err = config.WriteString(`{
+		"test-policy": {"taskDist": 2.5, "varCpuShare": 0.5},
+		"bin-packing": {"taskDist": 10.0}
+	}`)
+	assert.NoError(t, err)
+	assert.NoError(t, config.Close())
+
+	// Characteristics of externally registered scheduling policies are initialized.
+	assert.NoError(t, schedulers.InitSchedPolicyCharacteristics(config.Name()))
+	policy := schedulers.SchedPolicies["test-policy"].(*testPolicy)
+	assert.Equal(t, 2.5, policy.TaskDistribution)
+	assert.Equal(t, 0.5, policy.VarianceCpuSharePerTask)
+}
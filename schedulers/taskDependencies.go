@@ -0,0 +1,162 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/spdfg/elektron/def"
+)
+
+// Add tasks to the task queue.
+// Tasks that depend on tasks that have not yet completed are held back until they complete.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) addTasks(tasks []def.Task) {
+	for _, task := range tasks {
+		// Tasks of the same name are tracked together, and so a task that was submitted before
+		// is only considered afresh once all its instances have finished.
+		if s.unfinishedInstances[task.Name] <= 0 {
+			delete(s.completedTasks, task.Name)
+			delete(s.failedTasks, task.Name)
+			delete(s.runtimes, task.Name)
+		}
+		s.unfinishedInstances[task.Name] += *task.Instances
+		if s.dependenciesCompleted(task) {
+			s.tasks = append(s.tasks, task)
+		} else {
+			s.blockedTasks = append(s.blockedTasks, task)
+		}
+	}
+}
+
+// Whether all the tasks that the given task depends on have completed.
+func (s *BaseScheduler) dependenciesCompleted(task def.Task) bool {
+	for _, parent := range task.DependsOn {
+		if !s.completedTasks[parent] {
+			return false
+		}
+	}
+	return true
+}
+
+// Update the completion of the task corresponding to the status, if the task instance has terminated.
+// A task completes once all its instances have finished or have been killed on request. If any other
+// instance of the task does not finish successfully and cannot be retried, then the task fails.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) updateTaskCompletion(status *mesos.TaskStatus) {
+	instance, ok := s.launchedInstances[status.GetTaskId().GetValue()]
+	if !ok {
+		return
+	}
+	if s.energyBudget != nil {
+		s.energyBudget.instanceTerminated(s.statusTime(status), status.GetTaskId().GetValue())
+	}
+	s.recordRuntime(instance, status)
+	s.recordReport(instance, status)
+	terminated := terminatedInstance{
+		TaskID: status.GetTaskId().GetValue(),
+		State:  status.GetState().String(),
+	}
+	// Instances that were killed on request are not retried.
+	if s.killedInstances[terminated.TaskID] {
+		delete(s.killedInstances, terminated.TaskID)
+		terminated.Killed = status.GetState() != mesos.TaskState_TASK_FINISHED
+	}
+	if !terminated.Killed {
+		if retry, backedOff := s.retryIfFailed(instance, status); retry != nil {
+			persistedRetry := toPersistedTask(*retry)
+			terminated.Retry = &persistedRetry
+			terminated.BackedOff = backedOff
+		}
+	}
+	s.journal(instanceTerminatedEntry, terminated)
+
+	released, failed := s.applyInstanceTerminated(terminated)
+	if terminated.Retry != nil {
+		// The instance is yet to finish.
+		return
+	}
+	for _, task := range released {
+		s.LogTaskReleased(task)
+	}
+	for _, task := range failed {
+		s.LogDependentTaskFailed(task)
+	}
+	s.shutdownIfSchedulingComplete()
+}
+
+// Record that the given number of instances of the task will not need to be run anymore,
+// either because they finished or because they were cancelled.
+// Tasks held back because of this task are released once all its instances have finished.
+// Returns the tasks that were released.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) instancesFinished(taskName string, instances int) []def.Task {
+	s.unfinishedInstances[taskName] -= instances
+	if (s.unfinishedInstances[taskName] > 0) || s.failedTasks[taskName] || s.completedTasks[taskName] {
+		return nil
+	}
+	s.completedTasks[taskName] = true
+
+	// Releasing the tasks all of whose dependencies have completed.
+	var released []def.Task
+	stillBlocked := s.blockedTasks[:0]
+	for _, task := range s.blockedTasks {
+		if s.dependenciesCompleted(task) {
+			s.tasks = append(s.tasks, task)
+			released = append(released, task)
+		} else {
+			stillBlocked = append(stillBlocked, task)
+		}
+	}
+	s.blockedTasks = stillBlocked
+	return released
+}
+
+// Mark the task as failed, along with all the held back tasks that depend on it directly or indirectly.
+// Returns the held back tasks that failed.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) failTask(taskName string) []def.Task {
+	if s.failedTasks[taskName] {
+		return nil
+	}
+	s.failedTasks[taskName] = true
+
+	var failed []def.Task
+	for {
+		failedTask := -1
+		for i, task := range s.blockedTasks {
+			for _, parent := range task.DependsOn {
+				if s.failedTasks[parent] {
+					failedTask = i
+					break
+				}
+			}
+			if failedTask >= 0 {
+				break
+			}
+		}
+		if failedTask < 0 {
+			break
+		}
+		task := s.blockedTasks[failedTask]
+		s.blockedTasks = append(s.blockedTasks[:failedTask], s.blockedTasks[failedTask+1:]...)
+		s.failedTasks[task.Name] = true
+		failed = append(failed, task)
+	}
+	return failed
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/spdfg/elektron/def"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseScheduler_DependenciesReleased(t *testing.T) {
+	newTask := func(name string, instances int, dependsOn ...string) def.Task {
+		return def.Task{Name: name, CPU: 1.0, RAM: 1024, Image: "image", Instances: &instances, DependsOn: dependsOn}
+	}
+	offer := &mesos.Offer{
+		Id:       &mesos.OfferID{Value: proto.String("offer1")},
+		SlaveId:  &mesos.SlaveID{Value: proto.String("agent1")},
+		Hostname: proto.String("host1"),
+	}
+
+	recordPCP := true
+	s := &BaseScheduler{RecordPCP: &recordPCP, Shutdown: make(chan struct{})}
+	// The workload has two entries for minife, all of whose instances need to finish before dgemm is released.
+	s.init(WithTasks([]def.Task{newTask("minife", 2), newTask("minife", 1), newTask("dgemm", 1, "minife")}))
+	s.longRunning = true
+	assert.Equal(t, 3, s.unfinishedInstances["minife"])
+	assert.Equal(t, []string{"dgemm"}, taskNames(s.blockedTasks))
+
+	var taskIDs []string
+	for len(s.tasks) > 0 {
+		taskIDs = append(taskIDs, s.newTask(offer, s.tasks[0]).GetTaskId().GetValue())
+		*s.tasks[0].Instances--
+		if *s.tasks[0].Instances <= 0 {
+			s.removeTask(0)
+		}
+	}
+	finish := func(taskID string) []def.Task {
+		released, failed := s.applyInstanceTerminated(terminatedInstance{
+			TaskID: taskID,
+			State:  mesos.TaskState_TASK_FINISHED.String(),
+		})
+		assert.Empty(t, failed)
+		return released
+	}
+	assert.Len(t, taskIDs, 3)
+	assert.Empty(t, finish(taskIDs[0]))
+	s.runtimes["minife"] = nil
+
+	// Resubmitting minife while some of its instances are still running.
+	resubmitted := []def.Task{newTask("minife", 1)}
+	s.indexInstances(resubmitted)
+	s.addTasks(resubmitted)
+	assert.Equal(t, 3, s.unfinishedInstances["minife"])
+	assert.Contains(t, s.runtimes, "minife", "runtimes of running instances cleared")
+	taskIDs = append(taskIDs, s.newTask(offer, s.tasks[0]).GetTaskId().GetValue())
+	s.removeTask(0)
+
+	assert.Empty(t, finish(taskIDs[1]))
+	assert.Empty(t, finish(taskIDs[2]))
+	assert.Equal(t, []string{"dgemm"}, taskNames(s.blockedTasks))
+	assert.False(t, s.completedTasks["minife"])
+	// dgemm is only released once every instance of minife has finished.
+	assert.Equal(t, []string{"dgemm"}, taskNames(finish(taskIDs[3])))
+	assert.True(t, s.completedTasks["minife"])
+	assert.Empty(t, s.blockedTasks)
+
+	// Resubmitting minife once all its instances have finished.
+	resubmitted = []def.Task{newTask("minife", 1)}
+	s.indexInstances(resubmitted)
+	s.addTasks(resubmitted)
+	assert.Equal(t, 1, s.unfinishedInstances["minife"])
+	assert.False(t, s.completedTasks["minife"])
+	assert.NotContains(t, s.runtimes, "minife")
+}
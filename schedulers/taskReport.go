@@ -0,0 +1,173 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	elekLog "github.com/spdfg/elektron/logging"
+	. "github.com/spdfg/elektron/logging/types"
+	"github.com/spdfg/elektron/pcp"
+)
+
+// Report of a task instance that has terminated.
+type instanceReport struct {
+	TaskID     string `json:"taskID"`
+	Task       string `json:"task"`
+	Instance   int    `json:"instance"`
+	Host       string `json:"host"`
+	PowerClass string `json:"powerClass"`
+	State      string `json:"state"`
+	// Times at which the instance started running and terminated, in RFC 3339 format.
+	// The start time is empty if the instance never ran.
+	Start          string  `json:"start"`
+	End            string  `json:"end"`
+	RuntimeSeconds float64 `json:"runtimeSeconds"`
+	// Power considered for the instance when it was placed.
+	WattsConsidered float64 `json:"wattsConsidered"`
+	// Energy consumed by the host of the instance while it ran, in proportion to the power considered
+	// for the instance out of that of all the instances running on the host.
+	EnergyJoules float64 `json:"energyJoules"`
+}
+
+// Energy attributed to the task instances that are running, as reported by PCP.
+// It has its own lock, so that the samples recorded by PCP are consumed without waiting for the task queue.
+type energyAttribution struct {
+	mutex sync.Mutex
+	// Time at which the latest PCP sample was received, if any.
+	lastSample time.Time
+	// Running task instances, keyed by task ID.
+	running map[string]*attributedInstance
+}
+
+// Task instance that is running, and the energy attributed to it so far.
+type attributedInstance struct {
+	host string
+	// Power considered for the instance when it was placed.
+	watts float64
+	// Energy attributed to the instance so far, in joules.
+	joules float64
+}
+
+func newEnergyAttribution() *energyAttribution {
+	return &energyAttribution{running: make(map[string]*attributedInstance)}
+}
+
+// Record that a task instance has started running on the given host.
+func (e *energyAttribution) instanceStarted(taskID string, host string, watts float64) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if _, ok := e.running[taskID]; !ok {
+		e.running[taskID] = &attributedInstance{host: host, watts: watts}
+	}
+}
+
+// Record that a task instance has terminated.
+// Returns the energy attributed to the instance, in joules.
+func (e *energyAttribution) instanceTerminated(taskID string) float64 {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	instance, ok := e.running[taskID]
+	if !ok {
+		return 0.0
+	}
+	delete(e.running, taskID)
+	return instance.joules
+}
+
+// Attribute the energy consumed by each host since the previous sample to the task instances running
+// on it, in proportion to the power considered for each instance. The energy is split evenly between
+// the instances if no power was considered for any of them.
+func (e *energyAttribution) attribute(now time.Time, sample pcp.Sample) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	previous := e.lastSample
+	e.lastSample = now
+	if previous.IsZero() {
+		return
+	}
+
+	runningOnHost := make(map[string][]*attributedInstance)
+	for _, instance := range e.running {
+		runningOnHost[instance.host] = append(runningOnHost[instance.host], instance)
+	}
+	for host, instances := range runningOnHost {
+		hostSample, ok := sample.Hosts[host]
+		if !ok {
+			continue
+		}
+		joules := hostSample.Watts() * now.Sub(previous).Seconds()
+		totalWatts := 0.0
+		for _, instance := range instances {
+			totalWatts += instance.watts
+		}
+		for _, instance := range instances {
+			if totalWatts > 0.0 {
+				instance.joules += joules * instance.watts / totalWatts
+			} else {
+				instance.joules += joules / float64(len(instances))
+			}
+		}
+	}
+}
+
+// Record the report of the task instance corresponding to the status, which has terminated.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) recordReport(instance launchedInstance, status *mesos.TaskStatus) {
+	end := s.statusTime(status)
+	report := instanceReport{
+		TaskID:          status.GetTaskId().GetValue(),
+		Task:            instance.task.Name,
+		Instance:        instance.instance,
+		Host:            instance.host,
+		PowerClass:      instance.powerClass,
+		State:           status.GetState().String(),
+		End:             end.Format(time.RFC3339),
+		WattsConsidered: instance.watts,
+		EnergyJoules:    s.energyAttribution.instanceTerminated(status.GetTaskId().GetValue()),
+	}
+	if !instance.started.IsZero() {
+		report.Start = instance.started.Format(time.RFC3339)
+		report.RuntimeSeconds = end.Sub(instance.started).Seconds()
+	}
+	s.instanceReports = append(s.instanceReports, report)
+}
+
+// LogTaskReport logs the report of all the task instances that have terminated.
+func (s *BaseScheduler) LogTaskReport() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	reports := s.instanceReports
+	if reports == nil {
+		reports = []instanceReport{}
+	}
+	report, err := json.Marshal(reports)
+	if err != nil {
+		s.LogElectronError(errors.Wrap(err, "Failed to create task report"))
+		return
+	}
+	elekLog.WithField("Instances", fmt.Sprintf("%d", len(reports))).Log(TASK_REPORT, log.InfoLevel,
+		string(report))
+}
@@ -0,0 +1,95 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/spdfg/elektron/def"
+	"github.com/spdfg/elektron/pcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnergyAttribution_Attribute(t *testing.T) {
+	e := newEnergyAttribution()
+	e.instanceStarted("electron-minife-1", "host1", 90.0)
+	e.instanceStarted("electron-dgemm-1", "host1", 30.0)
+	e.instanceStarted("electron-stream-1", "host2", 0.0)
+
+	sample := pcp.Sample{Hosts: map[string]pcp.HostSample{
+		"host1": {PKGWatts: []float64{100.0}, DRAMWatts: []float64{20.0}},
+		"host2": {PKGWatts: []float64{50.0}},
+	}}
+	// Energy is only attributed from the second sample onwards.
+	started := time.Unix(1000, 0)
+	e.attribute(started, sample)
+	e.attribute(started.Add(2*time.Second), sample)
+
+	assert.InDelta(t, 180.0, e.instanceTerminated("electron-minife-1"), 1e-9)
+	assert.InDelta(t, 60.0, e.instanceTerminated("electron-dgemm-1"), 1e-9)
+	assert.InDelta(t, 100.0, e.instanceTerminated("electron-stream-1"), 1e-9)
+	assert.Empty(t, e.running)
+	// Instances that never ran are not attributed any energy.
+	assert.Equal(t, 0.0, e.instanceTerminated("electron-stream-2"))
+}
+
+func TestBaseScheduler_RecordReport(t *testing.T) {
+	s := &BaseScheduler{}
+	s.init()
+	instance := launchedInstance{
+		task:       def.Task{Name: "minife"},
+		instance:   1,
+		host:       "host1",
+		powerClass: "A",
+		started:    time.Unix(1000, 0),
+		watts:      90.0,
+	}
+	s.energyAttribution.instanceStarted("electron-minife-1", "host1", 90.0)
+	s.energyAttribution.running["electron-minife-1"].joules = 1800.0
+	s.recordReport(instance, &mesos.TaskStatus{
+		TaskId:    &mesos.TaskID{Value: proto.String("electron-minife-1")},
+		State:     mesos.TaskState_TASK_FINISHED.Enum(),
+		Timestamp: proto.Float64(1020.0),
+	})
+	// Instances that never ran have no start time.
+	instance.started = time.Time{}
+	s.recordReport(instance, &mesos.TaskStatus{
+		TaskId:    &mesos.TaskID{Value: proto.String("electron-minife-2")},
+		State:     mesos.TaskState_TASK_ERROR.Enum(),
+		Timestamp: proto.Float64(1020.0),
+	})
+
+	assert.Len(t, s.instanceReports, 2)
+	report := s.instanceReports[0]
+	assert.Equal(t, "electron-minife-1", report.TaskID)
+	assert.Equal(t, "minife", report.Task)
+	assert.Equal(t, "A", report.PowerClass)
+	assert.Equal(t, "TASK_FINISHED", report.State)
+	assert.Equal(t, time.Unix(1000, 0).Format(time.RFC3339), report.Start)
+	assert.Equal(t, time.Unix(1020, 0).Format(time.RFC3339), report.End)
+	assert.Equal(t, 20.0, report.RuntimeSeconds)
+	assert.Equal(t, 90.0, report.WattsConsidered)
+	assert.Equal(t, 1800.0, report.EnergyJoules)
+
+	assert.Empty(t, s.instanceReports[1].Start)
+	assert.Equal(t, 0.0, s.instanceReports[1].RuntimeSeconds)
+}
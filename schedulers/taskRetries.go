@@ -0,0 +1,164 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"time"
+
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/spdfg/elektron/def"
+)
+
+// Task instance that has been launched and has not yet terminated.
+type launchedInstance struct {
+	// Task whose instance was launched. Corresponds to a retry, if the instance has been retried.
+	task def.Task
+	// Instance of the task.
+	instance int
+	// Agent on which the instance was launched.
+	slaveID string
+	host    string
+	// Whether the instance was restored from the journal, and is yet to be reconciled.
+	recovered bool
+	// Time at which the instance started running. Zero if it is not yet known to be running.
+	started time.Time
+	// Whether the instance has been killed for running past the timeout of its task.
+	timedOut bool
+	// Whether the instance has been flagged as a straggler.
+	straggler bool
+	// Power class of the host of the instance, and power considered for the instance when it was placed.
+	powerClass string
+	watts      float64
+}
+
+// Number of times the instance has been retried after it failed.
+func (l launchedInstance) retries() int {
+	if l.task.Retry == nil {
+		return 0
+	}
+	return l.task.Retry.Retries
+}
+
+// Whether the instance can still be retried if it fails.
+func (l launchedInstance) canRetry() bool {
+	return l.retries() < l.task.MaxRetries
+}
+
+// Retry of the instance, to be added to the task queue.
+// Retries that do not count towards the maximum number of retries of the task can be created
+// for instances that were lost through no fault of their own.
+func (l launchedInstance) retry(countTowardsMaxRetries bool) def.Task {
+	retry := l.task
+	instances := 1
+	retry.Instances = &instances
+	retry.Retry = &def.Retry{Instance: l.instance}
+	if l.task.Retry != nil {
+		retry.Retry.Attempt = l.task.Retry.Attempt
+		retry.Retry.Retries = l.task.Retry.Retries
+		retry.Retry.FailedHosts = append(retry.Retry.FailedHosts, l.task.Retry.FailedHosts...)
+	}
+	retry.Retry.Attempt++
+	if countTowardsMaxRetries {
+		retry.Retry.Retries++
+	}
+	return retry
+}
+
+// Retry of the task instance corresponding to the status, if it did not finish successfully
+// and it has retries left. The retry is to be added to the task queue once its backoff has elapsed,
+// in which case it is backed off.
+// Instances lost along with their agent are retried right away if requeueOnAgentLoss is set,
+// and this does not count towards the maximum number of retries of the task.
+// Returns nil if the instance is not retried.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) retryIfFailed(instance launchedInstance, status *mesos.TaskStatus) (*def.Task, bool) {
+	if status.GetState() == mesos.TaskState_TASK_FINISHED {
+		return nil, false
+	}
+	taskID := status.GetTaskId().GetValue()
+
+	if s.requeueOnAgentLoss && (status.GetReason() == mesos.TaskStatus_REASON_SLAVE_REMOVED) {
+		retry := instance.retry(false)
+		s.LogTaskRequeued(retry, taskID, instance.host)
+		return &retry, false
+	}
+
+	if !instance.canRetry() {
+		if instance.task.MaxRetries > 0 {
+			s.LogTaskRetriesExhausted(instance.task, instance.instance, taskID, instance.host, status.GetState())
+		}
+		return nil, false
+	}
+
+	retry := instance.retry(true)
+	if retry.AvoidFailedHosts {
+		retry.Retry.FailedHosts = append(retry.Retry.FailedHosts, instance.host)
+		// Hosts are not avoided anymore once the instance has failed on all of them.
+		if s.avoidsAllHosts(retry) {
+			retry.Retry.FailedHosts = nil
+		}
+	}
+	delay := retry.RetryBackoff.Delay(retry.Retry.Retries)
+	retry.Retry.NotBefore = s.now().Add(delay)
+	s.LogTaskRetry(retry, taskID, instance.host, status.GetState(), delay)
+	return &retry, delay > 0
+}
+
+// Whether the given retry avoids all the hosts that have made resource offers.
+func (s *BaseScheduler) avoidsAllHosts(retry def.Task) bool {
+	for host := range s.HostNameToSlaveID {
+		if !retry.AvoidsHost(host) {
+			return false
+		}
+	}
+	return true
+}
+
+// Add the retries whose backoff has elapsed to the task queue.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) releaseRetries() {
+	now := s.now()
+	stillBackedOff := s.backedOffRetries[:0]
+	for _, retry := range s.backedOffRetries {
+		if now.Before(retry.Retry.NotBefore) {
+			stillBackedOff = append(stillBackedOff, retry)
+		} else {
+			s.tasks = append(s.tasks, retry)
+		}
+	}
+	s.backedOffRetries = stillBackedOff
+}
+
+// Whether any task instances are waiting to be retried or could still be retried.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) retriesPending() bool {
+	if len(s.backedOffRetries) > 0 {
+		return true
+	}
+	// Any instance could be lost along with its agent.
+	if s.requeueOnAgentLoss && (len(s.launchedInstances) > 0) {
+		return true
+	}
+	for _, instance := range s.launchedInstances {
+		if instance.canRetry() {
+			return true
+		}
+	}
+	return false
+}
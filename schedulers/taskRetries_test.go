@@ -0,0 +1,145 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/spdfg/elektron/def"
+	elekLog "github.com/spdfg/elektron/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+// Build a logger with all the loggers disabled, so that the scheduler can log in tests.
+// The log directory is created in a temporary directory, which is removed right away.
+func buildTestLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elektron")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	config := filepath.Join(dir, "logConfig.yaml")
+	if err := ioutil.WriteFile(config, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := elekLog.BuildLogger("test", config); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBaseScheduler_RetryBackoff(t *testing.T) {
+	buildTestLogger(t)
+	instances := 1
+	minife := def.Task{
+		Name:             "minife",
+		CPU:              3.0,
+		RAM:              4096,
+		Image:            "rdelvalle/minife:electron1",
+		Instances:        &instances,
+		MaxRetries:       2,
+		RetryBackoff:     def.Backoff{InitialSeconds: 10.0, Multiplier: 2.0},
+		AvoidFailedHosts: true,
+	}
+	offer := func(host string) *mesos.Offer {
+		return &mesos.Offer{
+			Id:       &mesos.OfferID{Value: proto.String("offer-" + host)},
+			SlaveId:  &mesos.SlaveID{Value: proto.String("agent-" + host)},
+			Hostname: proto.String(host),
+		}
+	}
+
+	start := time.Unix(1000, 0)
+	now := start
+	recordPCP := true
+	s := &BaseScheduler{RecordPCP: &recordPCP}
+	s.init(WithClock(func() time.Time { return now }), WithTasks([]def.Task{minife}))
+	s.longRunning = true
+	s.HostNameToSlaveID["host1"] = "agent-host1"
+	s.HostNameToSlaveID["host2"] = "agent-host2"
+
+	// Launch the instance at the head of the task queue on the given host, and fail it.
+	var taskID string
+	launchAndFail := func(host string) {
+		s.newTask(offer(host), s.tasks[0])
+		s.removeTask(0)
+		taskID = s.launchedInstancesOf("minife")[0]
+		s.updateTaskCompletion(&mesos.TaskStatus{
+			TaskId:  &mesos.TaskID{Value: proto.String(taskID)},
+			SlaveId: offer(host).SlaveId,
+			State:   mesos.TaskState_TASK_FAILED.Enum(),
+		})
+	}
+
+	// The first retry is backed off for the initial delay, and avoids the host that the instance failed on.
+	launchAndFail("host1")
+	assert.Empty(t, s.tasks)
+	assert.Len(t, s.backedOffRetries, 1)
+	retry := s.backedOffRetries[0]
+	assert.Equal(t, 1, retry.Retry.Retries)
+	assert.Equal(t, start.Add(10*time.Second), retry.Retry.NotBefore)
+	assert.True(t, retry.AvoidsHost("host1"))
+	assert.False(t, retry.AvoidsHost("host2"))
+	// The resource requirements of the instance are kept for its retries.
+	_, err := def.GetResourceRequirement(taskID)
+	assert.NoError(t, err)
+
+	now = start.Add(5 * time.Second)
+	s.releaseRetries()
+	assert.Empty(t, s.tasks)
+	now = start.Add(10 * time.Second)
+	s.releaseRetries()
+	assert.Empty(t, s.backedOffRetries)
+	assert.Len(t, s.tasks, 1)
+
+	// The second retry is backed off for twice as long. Hosts are not avoided anymore once the
+	// instance has failed on all of them.
+	launchAndFail("host2")
+	assert.Len(t, s.backedOffRetries, 1)
+	retry = s.backedOffRetries[0]
+	assert.Equal(t, 2, retry.Retry.Retries)
+	assert.Equal(t, now.Add(20*time.Second), retry.Retry.NotBefore)
+	assert.False(t, retry.AvoidsHost("host1"))
+	assert.False(t, retry.AvoidsHost("host2"))
+	assert.False(t, s.failedTasks["minife"])
+
+	// The task fails once the instance has no retries left.
+	now = now.Add(20 * time.Second)
+	s.releaseRetries()
+	launchAndFail("host1")
+	assert.Empty(t, s.tasks)
+	assert.Empty(t, s.backedOffRetries)
+	assert.True(t, s.failedTasks["minife"])
+	_, err = def.GetResourceRequirement(taskID)
+	assert.Error(t, err)
+}
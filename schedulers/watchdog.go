@@ -0,0 +1,160 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/pkg/errors"
+	"github.com/spdfg/elektron/def"
+)
+
+// Watchdog checks the runtimes of the running task instances every offer cycle, and periodically
+// until the scheduler is done, as offers might not be received while shutting down.
+// Instances that run past their timeout are killed, and instances that run past the straggler threshold
+// times the median runtime of their sibling instances are flagged as stragglers.
+func (s *BaseScheduler) Watchdog(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.Done:
+			return
+		case <-ticker.C:
+			s.mutex.Lock()
+			s.checkRuntimes(s.now())
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// Kill the instances that have timed out, and log the stragglers.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) checkRuntimes(now time.Time) {
+	timedOut, stragglers := s.overdueInstances(now)
+	for _, taskID := range timedOut {
+		instance := s.launchedInstances[taskID]
+		s.LogTaskTimedOut(instance.task, instance.instance, taskID, instance.host, now.Sub(instance.started))
+		// Unlike instances killed on request, instances that timed out are retried as per the
+		// maxRetries of their task, and fail their task otherwise.
+		if err := s.sendKill(taskID); err != nil {
+			s.LogElectronError(err)
+		}
+	}
+	for _, taskID := range stragglers {
+		instance := s.launchedInstances[taskID]
+		reference, _ := s.referenceRuntime(instance.task)
+		s.LogStraggler(instance.task, instance.instance, taskID, instance.host, now.Sub(instance.started),
+			reference)
+	}
+}
+
+// Task IDs of the running instances that have run past their timeout, and of the running instances
+// that have become stragglers. Every instance is reported only once.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) overdueInstances(now time.Time) ([]string, []string) {
+	var timedOut, stragglers []string
+	for taskID, instance := range s.launchedInstances {
+		if instance.started.IsZero() {
+			continue
+		}
+		runtime := now.Sub(instance.started)
+		if !instance.timedOut && (instance.task.TimeoutSeconds > 0.0) &&
+			(runtime > seconds(instance.task.TimeoutSeconds)) {
+			instance.timedOut = true
+			timedOut = append(timedOut, taskID)
+		}
+		if !instance.straggler && (s.stragglerThreshold > 0.0) {
+			if reference, ok := s.referenceRuntime(instance.task); ok &&
+				(runtime > time.Duration(s.stragglerThreshold*float64(reference))) {
+				instance.straggler = true
+				stragglers = append(stragglers, taskID)
+			}
+		}
+		s.launchedInstances[taskID] = instance
+	}
+	sort.Strings(timedOut)
+	sort.Strings(stragglers)
+	return timedOut, stragglers
+}
+
+// Runtime against which the instances of the task are compared to detect stragglers.
+// This is the median runtime of the instances of the task that have finished, or the expected runtime
+// of the task if none have finished yet.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) referenceRuntime(task def.Task) (time.Duration, bool) {
+	if runtimes := s.runtimes[task.Name]; len(runtimes) > 0 {
+		return median(runtimes), true
+	}
+	if task.ExpectedRuntimeSeconds > 0.0 {
+		return seconds(task.ExpectedRuntimeSeconds), true
+	}
+	return 0, false
+}
+
+// Record the runtime of the task instance corresponding to the status, if it finished successfully.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) recordRuntime(instance launchedInstance, status *mesos.TaskStatus) {
+	if (status.GetState() != mesos.TaskState_TASK_FINISHED) || instance.started.IsZero() {
+		return
+	}
+	s.runtimes[instance.task.Name] = append(s.runtimes[instance.task.Name],
+		s.statusTime(status).Sub(instance.started))
+}
+
+// Kill the launched task instance with the given task ID.
+// Needs to be called with the task queue locked.
+func (s *BaseScheduler) sendKill(taskID string) error {
+	if s.driver == nil {
+		return errors.New("framework has not yet registered")
+	}
+	if _, err := s.driver.KillTask(&mesos.TaskID{Value: proto.String(taskID)}); err != nil {
+		return errors.Wrap(err, "Failed to kill task instance "+taskID)
+	}
+	return nil
+}
+
+// Time at which the status update was generated, or the current time if the status has no timestamp.
+func (s *BaseScheduler) statusTime(status *mesos.TaskStatus) time.Time {
+	if status.Timestamp == nil {
+		return s.now()
+	}
+	sec, frac := math.Modf(status.GetTimestamp())
+	return time.Unix(int64(sec), int64(frac*float64(time.Second)))
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func median(durations []time.Duration) time.Duration {
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
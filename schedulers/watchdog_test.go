@@ -0,0 +1,99 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package schedulers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/spdfg/elektron/def"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseScheduler_OverdueInstances(t *testing.T) {
+	minife := def.Task{Name: "minife", TimeoutSeconds: 100.0}
+	dgemm := def.Task{Name: "dgemm", ExpectedRuntimeSeconds: 10.0}
+	started := time.Unix(1000, 0)
+
+	s := &BaseScheduler{}
+	s.init(WithStragglerThreshold(2.0))
+	s.launchedInstances["electron-minife-1"] = launchedInstance{task: minife, instance: 1}
+	s.launchedInstances["electron-minife-2"] = launchedInstance{task: minife, instance: 2}
+	s.launchedInstances["electron-dgemm-1"] = launchedInstance{task: dgemm, instance: 1}
+
+	// Instances that are not yet running are not checked.
+	timedOut, stragglers := s.overdueInstances(started.Add(time.Hour))
+	assert.Empty(t, timedOut)
+	assert.Empty(t, stragglers)
+
+	for _, taskID := range []string{"electron-minife-1", "electron-minife-2", "electron-dgemm-1"} {
+		s.instanceRunning(&mesos.TaskStatus{
+			TaskId:    &mesos.TaskID{Value: proto.String(taskID)},
+			SlaveId:   &mesos.SlaveID{Value: proto.String("agent1")},
+			State:     mesos.TaskState_TASK_RUNNING.Enum(),
+			Timestamp: proto.Float64(1000.0),
+		})
+	}
+	// The first instance of minife finishes after 30 seconds.
+	s.recordRuntime(s.launchedInstances["electron-minife-1"], &mesos.TaskStatus{
+		TaskId:    &mesos.TaskID{Value: proto.String("electron-minife-1")},
+		State:     mesos.TaskState_TASK_FINISHED.Enum(),
+		Timestamp: proto.Float64(1030.0),
+	})
+	delete(s.launchedInstances, "electron-minife-1")
+
+	// dgemm is compared against its expected runtime, as none of its instances have finished.
+	timedOut, stragglers = s.overdueInstances(started.Add(50 * time.Second))
+	assert.Empty(t, timedOut)
+	assert.Equal(t, []string{"electron-dgemm-1"}, stragglers)
+
+	timedOut, stragglers = s.overdueInstances(started.Add(70 * time.Second))
+	assert.Empty(t, timedOut)
+	assert.Equal(t, []string{"electron-minife-2"}, stragglers)
+
+	// Instances are only reported once.
+	timedOut, stragglers = s.overdueInstances(started.Add(101 * time.Second))
+	assert.Equal(t, []string{"electron-minife-2"}, timedOut)
+	assert.Empty(t, stragglers)
+	timedOut, _ = s.overdueInstances(started.Add(200 * time.Second))
+	assert.Empty(t, timedOut)
+}
+
+func TestBaseScheduler_ReferenceRuntime(t *testing.T) {
+	s := &BaseScheduler{}
+	s.init()
+	minife := def.Task{Name: "minife"}
+	_, ok := s.referenceRuntime(minife)
+	assert.False(t, ok)
+
+	minife.ExpectedRuntimeSeconds = 60.0
+	reference, ok := s.referenceRuntime(minife)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, reference)
+
+	// The median runtime of the finished instances takes precedence over the expected runtime.
+	s.runtimes["minife"] = []time.Duration{40 * time.Second, 10 * time.Second, 20 * time.Second}
+	reference, _ = s.referenceRuntime(minife)
+	assert.Equal(t, 20*time.Second, reference)
+	s.runtimes["minife"] = append(s.runtimes["minife"], 30*time.Second)
+	reference, _ = s.referenceRuntime(minife)
+	assert.Equal(t, 25*time.Second, reference)
+}
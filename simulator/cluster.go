@@ -0,0 +1,127 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Default number of simulated seconds between two offer cycles.
+const defaultOfferInterval = 1.0
+
+// Default number of simulated seconds that a task instance runs for, if no
+// runtime has been specified for the task in the cluster description.
+const defaultRuntime = 60.0
+
+// Host describes a simulated Mesos agent.
+type Host struct {
+	Hostname string  `json:"hostname"`
+	Class    string  `json:"class"`
+	CPU      float64 `json:"cpus"`
+	RAM      float64 `json:"mem"`
+	Watts    float64 `json:"watts"`
+}
+
+// Cluster describes the simulated cluster and the runtime model used to
+// determine when launched task instances finish executing.
+type Cluster struct {
+	Hosts []Host `json:"hosts"`
+	// Number of simulated seconds between two offer cycles.
+	OfferInterval float64 `json:"offerInterval"`
+	// Number of simulated seconds that an instance of a task runs for, keyed by task name.
+	Runtimes map[string]float64 `json:"runtimes"`
+	// Runtime of instances of tasks that are not present in Runtimes.
+	DefaultRuntime float64 `json:"defaultRuntime"`
+}
+
+// ClusterFromJSON reads the cluster description from the given file.
+func ClusterFromJSON(uri string) (*Cluster, error) {
+	file, err := os.Open(uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error opening file")
+	}
+	defer file.Close()
+
+	cluster := &Cluster{}
+	if err := json.NewDecoder(file).Decode(cluster); err != nil {
+		return nil, errors.Wrap(err, "Error unmarshalling")
+	}
+
+	if err := cluster.validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid cluster description")
+	}
+
+	if cluster.OfferInterval == 0.0 {
+		cluster.OfferInterval = defaultOfferInterval
+	}
+	if cluster.DefaultRuntime == 0.0 {
+		cluster.DefaultRuntime = defaultRuntime
+	}
+	return cluster, nil
+}
+
+func (c *Cluster) validate() error {
+	if len(c.Hosts) == 0 {
+		return errors.New("at least one host needs to be provided")
+	}
+
+	hostnames := make(map[string]struct{})
+	for _, host := range c.Hosts {
+		if host.Hostname == "" {
+			return errors.New("hostname cannot be empty string")
+		}
+		if _, ok := hostnames[host.Hostname]; ok {
+			return errors.New(fmt.Sprintf("duplicate hostname %s", host.Hostname))
+		}
+		hostnames[host.Hostname] = struct{}{}
+
+		if host.CPU <= 0.0 || host.RAM <= 0.0 {
+			return errors.New(fmt.Sprintf("cpu and memory for host %s need to be > 0", host.Hostname))
+		}
+		if host.Watts < 0.0 {
+			return errors.New(fmt.Sprintf("watts for host %s cannot be negative", host.Hostname))
+		}
+	}
+
+	if c.OfferInterval < 0.0 {
+		return errors.New("offer interval cannot be negative")
+	}
+	if c.DefaultRuntime < 0.0 {
+		return errors.New("default runtime cannot be negative")
+	}
+	for name, runtime := range c.Runtimes {
+		if runtime <= 0.0 {
+			return errors.New(fmt.Sprintf("runtime for task %s needs to be > 0", name))
+		}
+	}
+	return nil
+}
+
+// Runtime returns the number of simulated seconds that an instance of the
+// given task runs for.
+func (c *Cluster) Runtime(taskName string) float64 {
+	if runtime, ok := c.Runtimes[taskName]; ok {
+		return runtime
+	}
+	return c.DefaultRuntime
+}
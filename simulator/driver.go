@@ -0,0 +1,534 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+/*
+Package simulator provides a scheduler driver that runs a scheduler against a
+simulated cluster, without the need for a Mesos master.
+
+Resource offers are generated from a cluster description. Every offer cycle,
+each host that has unused resources, and that has not been filtered out by a
+previously declined offer, is offered to the scheduler. Launched task instances
+transition to TASK_RUNNING right away and to TASK_FINISHED once their runtime,
+as given by the runtime model in the cluster description, has elapsed.
+
+Time is simulated. Each offer cycle advances the simulated clock by the offer
+interval, so a workload that would take hours to run on a cluster can be
+scheduled in seconds.
+*/
+package simulator
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/mesos/mesos-go/api/v0/mesosutil"
+	sched "github.com/mesos/mesos-go/api/v0/scheduler"
+	"github.com/spdfg/elektron/def"
+)
+
+// Mesos refuses resources for 5 seconds if no filters are provided.
+const defaultRefuseSeconds = 5.0
+
+// Real time to wait between two offer cycles when the simulated cluster is idle.
+// This prevents spinning while waiting for the scheduler to stop the driver.
+const idleWait = 500 * time.Millisecond
+
+// Simulated Mesos agent.
+type agent struct {
+	host    Host
+	slaveID string
+
+	unusedCPU   float64
+	unusedRAM   float64
+	unusedWatts float64
+
+	// Simulated time until which resources of this agent are not offered.
+	refuseUntil float64
+	// Whether this agent currently has an outstanding offer.
+	offered bool
+}
+
+// Task instance launched on a simulated agent.
+type simTask struct {
+	info    *mesos.TaskInfo
+	agent   *agent
+	cpu     float64
+	ram     float64
+	watts   float64
+	endTime float64
+}
+
+// Driver implements sched.SchedulerDriver for a simulated cluster.
+type Driver struct {
+	scheduler sched.Scheduler
+	cluster   *Cluster
+
+	frameworkID *mesos.FrameworkID
+	masterInfo  *mesos.MasterInfo
+
+	// Current simulated time, in seconds.
+	now    float64
+	status mesos.Status
+
+	agents      []*agent
+	agentsByID  map[string]*agent
+	offers      map[string]*agent
+	running     map[string]*simTask
+	nextOfferID int
+	// Whether any task was launched during the current offer cycle.
+	launched bool
+	// Status updates that are yet to be sent to the scheduler.
+	pendingUpdates []*mesos.TaskStatus
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	mutex    sync.Mutex
+}
+
+// NewDriver returns a driver that runs the given scheduler against the given cluster.
+func NewDriver(scheduler sched.Scheduler, cluster *Cluster) *Driver {
+	d := &Driver{
+		scheduler:   scheduler,
+		cluster:     cluster,
+		frameworkID: &mesos.FrameworkID{Value: proto.String("elektron-simulator")},
+		masterInfo: &mesos.MasterInfo{
+			Id:       proto.String("simulated-master"),
+			Ip:       proto.Uint32(0),
+			Port:     proto.Uint32(5050),
+			Hostname: proto.String("localhost"),
+		},
+		status:     mesos.Status_DRIVER_NOT_STARTED,
+		agentsByID: make(map[string]*agent),
+		offers:     make(map[string]*agent),
+		running:    make(map[string]*simTask),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	for i, host := range cluster.Hosts {
+		a := &agent{
+			host:        host,
+			slaveID:     fmt.Sprintf("simulated-agent-%d", i),
+			unusedCPU:   host.CPU,
+			unusedRAM:   host.RAM,
+			unusedWatts: host.Watts,
+		}
+		d.agents = append(d.agents, a)
+		d.agentsByID[a.slaveID] = a
+	}
+	return d
+}
+
+// Now returns the current simulated time in seconds.
+func (d *Driver) Now() float64 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.now
+}
+
+// Time returns the current simulated time, consistent with the timestamps of the status updates.
+func (d *Driver) Time() time.Time {
+	return time.Unix(0, int64(d.Now()*float64(time.Second)))
+}
+
+func (d *Driver) Start() (mesos.Status, error) {
+	d.mutex.Lock()
+	if d.status != mesos.Status_DRIVER_NOT_STARTED {
+		defer d.mutex.Unlock()
+		return d.status, fmt.Errorf("unable to start driver, expecting status %s but got %s",
+			mesos.Status_DRIVER_NOT_STARTED, d.status)
+	}
+	d.status = mesos.Status_DRIVER_RUNNING
+	d.mutex.Unlock()
+
+	go d.run()
+	return mesos.Status_DRIVER_RUNNING, nil
+}
+
+func (d *Driver) Stop(failover bool) (mesos.Status, error) {
+	return d.halt(mesos.Status_DRIVER_STOPPED)
+}
+
+func (d *Driver) Abort() (mesos.Status, error) {
+	return d.halt(mesos.Status_DRIVER_ABORTED)
+}
+
+func (d *Driver) halt(status mesos.Status) (mesos.Status, error) {
+	d.mutex.Lock()
+	if d.status != mesos.Status_DRIVER_RUNNING {
+		defer d.mutex.Unlock()
+		return d.status, fmt.Errorf("unable to stop driver, expecting status %s but got %s",
+			mesos.Status_DRIVER_RUNNING, d.status)
+	}
+	d.status = status
+	d.mutex.Unlock()
+
+	d.stopOnce.Do(func() { close(d.stop) })
+	return status, nil
+}
+
+func (d *Driver) Join() (mesos.Status, error) {
+	d.mutex.Lock()
+	if d.status == mesos.Status_DRIVER_NOT_STARTED {
+		defer d.mutex.Unlock()
+		return d.status, fmt.Errorf("unable to join driver, expecting status %s but got %s",
+			mesos.Status_DRIVER_RUNNING, d.status)
+	}
+	d.mutex.Unlock()
+
+	<-d.done
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.status, nil
+}
+
+func (d *Driver) Run() (mesos.Status, error) {
+	if status, err := d.Start(); err != nil {
+		return status, err
+	}
+	return d.Join()
+}
+
+func (d *Driver) RequestResources(requests []*mesos.Request) (mesos.Status, error) {
+	return d.currentStatus(), nil
+}
+
+func (d *Driver) AcceptOffers(offerIDs []*mesos.OfferID, operations []*mesos.Offer_Operation,
+	filters *mesos.Filters) (mesos.Status, error) {
+
+	tasks := []*mesos.TaskInfo{}
+	for _, operation := range operations {
+		if operation.GetType() == mesos.Offer_Operation_LAUNCH {
+			tasks = append(tasks, operation.GetLaunch().GetTaskInfos()...)
+		}
+	}
+	return d.LaunchTasks(offerIDs, tasks, filters)
+}
+
+func (d *Driver) LaunchTasks(offerIDs []*mesos.OfferID, tasks []*mesos.TaskInfo,
+	filters *mesos.Filters) (mesos.Status, error) {
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, offerID := range offerIDs {
+		a, ok := d.offers[offerID.GetValue()]
+		if !ok {
+			// Offer is no longer valid. Mesos reports such tasks as lost.
+			for _, task := range tasks {
+				d.queueUpdate(task.GetTaskId(), task.GetSlaveId(), mesos.TaskState_TASK_LOST,
+					"Task launched with invalid offer")
+			}
+			return d.status, fmt.Errorf("invalid offer %s", offerID.GetValue())
+		}
+		delete(d.offers, offerID.GetValue())
+		a.offered = false
+		a.refuseUntil = d.now + refuseSeconds(filters)
+	}
+
+	for _, task := range tasks {
+		a, ok := d.agentsByID[task.GetSlaveId().GetValue()]
+		if !ok {
+			d.queueUpdate(task.GetTaskId(), task.GetSlaveId(), mesos.TaskState_TASK_LOST,
+				"Task launched on unknown agent")
+			continue
+		}
+
+		t := &simTask{
+			info:    task,
+			agent:   a,
+			endTime: d.now + d.cluster.Runtime(taskName(task)),
+		}
+		for _, resource := range task.GetResources() {
+			switch resource.GetName() {
+			case "cpus":
+				t.cpu += resource.GetScalar().GetValue()
+			case "mem":
+				t.ram += resource.GetScalar().GetValue()
+			case "watts":
+				t.watts += resource.GetScalar().GetValue()
+			}
+		}
+
+		if (t.cpu > a.unusedCPU) || (t.ram > a.unusedRAM) || (t.watts > a.unusedWatts) {
+			d.queueUpdate(task.GetTaskId(), task.GetSlaveId(), mesos.TaskState_TASK_ERROR,
+				"Insufficient resources to launch task")
+			continue
+		}
+
+		a.unusedCPU -= t.cpu
+		a.unusedRAM -= t.ram
+		a.unusedWatts -= t.watts
+		d.running[task.GetTaskId().GetValue()] = t
+		d.launched = true
+		d.queueUpdate(task.GetTaskId(), task.GetSlaveId(), mesos.TaskState_TASK_RUNNING, "")
+	}
+
+	return d.status, nil
+}
+
+func (d *Driver) KillTask(taskID *mesos.TaskID) (mesos.Status, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if t, ok := d.running[taskID.GetValue()]; ok {
+		d.release(t)
+		d.queueUpdate(taskID, t.info.GetSlaveId(), mesos.TaskState_TASK_KILLED, "")
+	}
+	return d.status, nil
+}
+
+func (d *Driver) DeclineOffer(offerID *mesos.OfferID, filters *mesos.Filters) (mesos.Status, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if a, ok := d.offers[offerID.GetValue()]; ok {
+		delete(d.offers, offerID.GetValue())
+		a.offered = false
+		a.refuseUntil = d.now + refuseSeconds(filters)
+	}
+	return d.status, nil
+}
+
+func (d *Driver) ReviveOffers() (mesos.Status, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, a := range d.agents {
+		a.refuseUntil = d.now
+	}
+	return d.status, nil
+}
+
+func (d *Driver) SendFrameworkMessage(executorID *mesos.ExecutorID, slaveID *mesos.SlaveID,
+	data string) (mesos.Status, error) {
+	return d.currentStatus(), nil
+}
+
+func (d *Driver) ReconcileTasks(statuses []*mesos.TaskStatus) (mesos.Status, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	// Implicit reconciliation. Sending the latest state of all the running tasks.
+	if len(statuses) == 0 {
+		for _, t := range d.runningInOrder() {
+			d.queueUpdate(t.info.GetTaskId(), t.info.GetSlaveId(), mesos.TaskState_TASK_RUNNING, "")
+		}
+		return d.status, nil
+	}
+
+	for _, status := range statuses {
+		if t, ok := d.running[status.GetTaskId().GetValue()]; ok {
+			d.queueUpdate(t.info.GetTaskId(), t.info.GetSlaveId(), mesos.TaskState_TASK_RUNNING, "")
+		} else {
+			d.queueUpdate(status.GetTaskId(), status.GetSlaveId(), mesos.TaskState_TASK_LOST,
+				"Reconciliation: Task is unknown")
+		}
+	}
+	return d.status, nil
+}
+
+func (d *Driver) currentStatus() mesos.Status {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.status
+}
+
+// Run offer cycles until the driver is stopped.
+func (d *Driver) run() {
+	defer close(d.done)
+	d.scheduler.Registered(d, d.frameworkID, d.masterInfo)
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		default:
+		}
+
+		if offers := d.makeOffers(); len(offers) > 0 {
+			d.scheduler.ResourceOffers(d, offers)
+		}
+		idle := d.endOfferCycle()
+		d.sendUpdates()
+
+		d.advance()
+		d.sendUpdates()
+
+		if idle {
+			select {
+			case <-d.stop:
+				return
+			case <-time.After(idleWait):
+			}
+		}
+	}
+}
+
+// Create an offer for each agent that has unused resources and that has not been filtered.
+func (d *Driver) makeOffers() []*mesos.Offer {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.launched = false
+	offers := []*mesos.Offer{}
+	for _, a := range d.agents {
+		if a.offered || (a.refuseUntil > d.now) || (a.unusedCPU <= 0.0) || (a.unusedRAM <= 0.0) {
+			continue
+		}
+
+		d.nextOfferID++
+		offerID := fmt.Sprintf("simulated-offer-%d", d.nextOfferID)
+		resources := []*mesos.Resource{
+			mesosutil.NewScalarResource("cpus", a.unusedCPU),
+			mesosutil.NewScalarResource("mem", a.unusedRAM),
+		}
+		if a.host.Watts > 0.0 {
+			resources = append(resources, mesosutil.NewScalarResource("watts", a.unusedWatts))
+		}
+
+		offers = append(offers, &mesos.Offer{
+			Id:          &mesos.OfferID{Value: proto.String(offerID)},
+			FrameworkId: d.frameworkID,
+			SlaveId:     &mesos.SlaveID{Value: proto.String(a.slaveID)},
+			Hostname:    proto.String(a.host.Hostname),
+			Resources:   resources,
+			Attributes: []*mesos.Attribute{
+				{
+					Name: proto.String("class"),
+					Type: mesos.Value_TEXT.Enum(),
+					Text: &mesos.Value_Text{Value: proto.String(a.host.Class)},
+				},
+			},
+		})
+		d.offers[offerID] = a
+		a.offered = true
+	}
+	return offers
+}
+
+// Reclaim the offers that were neither accepted nor declined.
+// Returns true if the cluster was idle during this offer cycle.
+func (d *Driver) endOfferCycle() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for offerID, a := range d.offers {
+		delete(d.offers, offerID)
+		a.offered = false
+	}
+	return !d.launched && (len(d.running) == 0)
+}
+
+// Advance the simulated clock by one offer interval and finish all the
+// task instances whose runtime has elapsed.
+func (d *Driver) advance() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.now += d.cluster.OfferInterval
+	for _, t := range d.runningInOrder() {
+		if t.endTime > d.now {
+			break
+		}
+		d.release(t)
+		d.queueUpdate(t.info.GetTaskId(), t.info.GetSlaveId(), mesos.TaskState_TASK_FINISHED, "")
+	}
+}
+
+// Send the pending status updates to the scheduler.
+func (d *Driver) sendUpdates() {
+	d.mutex.Lock()
+	updates := d.pendingUpdates
+	d.pendingUpdates = nil
+	d.mutex.Unlock()
+
+	for _, update := range updates {
+		d.scheduler.StatusUpdate(d, update)
+	}
+}
+
+// Running task instances in the order in which they would finish.
+func (d *Driver) runningInOrder() []*simTask {
+	tasks := make([]*simTask, 0, len(d.running))
+	for _, t := range d.running {
+		tasks = append(tasks, t)
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].endTime == tasks[j].endTime {
+			return tasks[i].info.GetTaskId().GetValue() < tasks[j].info.GetTaskId().GetValue()
+		}
+		return tasks[i].endTime < tasks[j].endTime
+	})
+	return tasks
+}
+
+// Return the resources of the given task instance to its agent.
+func (d *Driver) release(t *simTask) {
+	t.agent.unusedCPU += t.cpu
+	t.agent.unusedRAM += t.ram
+	t.agent.unusedWatts += t.watts
+	delete(d.running, t.info.GetTaskId().GetValue())
+}
+
+func (d *Driver) queueUpdate(taskID *mesos.TaskID, slaveID *mesos.SlaveID, state mesos.TaskState,
+	message string) {
+
+	status := &mesos.TaskStatus{
+		TaskId:    taskID,
+		State:     state.Enum(),
+		SlaveId:   slaveID,
+		Timestamp: proto.Float64(d.now),
+		Source:    mesos.TaskStatus_SOURCE_MASTER.Enum(),
+	}
+	if message != "" {
+		status.Message = proto.String(message)
+	}
+	d.pendingUpdates = append(d.pendingUpdates, status)
+}
+
+func refuseSeconds(filters *mesos.Filters) float64 {
+	if filters == nil || filters.RefuseSeconds == nil {
+		return defaultRefuseSeconds
+	}
+	return filters.GetRefuseSeconds()
+}
+
+// Name of the task that the given task instance belongs to.
+// The task is identified by the task ID of the instance if it was launched by Elektron, and otherwise
+// by the name of the instance, as task instances are named <task name>-<instance>.
+func taskName(task *mesos.TaskInfo) string {
+	if id, err := def.ParseInstanceID(task.GetTaskId().GetValue()); err == nil {
+		return id.Task
+	}
+	name := task.GetName()
+	if i := strings.LastIndex(name, "-"); i != -1 {
+		if _, err := strconv.Atoi(name[i+1:]); err == nil {
+			return name[:i]
+		}
+	}
+	return name
+}
@@ -0,0 +1,125 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package simulator
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/mesos/mesos-go/api/v0/mesosutil"
+	sched "github.com/mesos/mesos-go/api/v0/scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+// Scheduler that launches one instance (1 cpu, 1024 MB) of a task on every offer
+// until all instances have been launched, and stops the driver once all instances
+// have finished.
+type testScheduler struct {
+	instances int
+	launched  int
+	finished  int
+	states    map[string][]mesos.TaskState
+}
+
+func (s *testScheduler) Registered(sched.SchedulerDriver, *mesos.FrameworkID, *mesos.MasterInfo) {}
+func (s *testScheduler) Reregistered(sched.SchedulerDriver, *mesos.MasterInfo)                   {}
+func (s *testScheduler) Disconnected(sched.SchedulerDriver)                                      {}
+func (s *testScheduler) OfferRescinded(sched.SchedulerDriver, *mesos.OfferID)                    {}
+func (s *testScheduler) FrameworkMessage(sched.SchedulerDriver, *mesos.ExecutorID, *mesos.SlaveID, string) {
+}
+func (s *testScheduler) SlaveLost(sched.SchedulerDriver, *mesos.SlaveID)                            {}
+func (s *testScheduler) ExecutorLost(sched.SchedulerDriver, *mesos.ExecutorID, *mesos.SlaveID, int) {}
+func (s *testScheduler) Error(sched.SchedulerDriver, string)                                        {}
+
+func (s *testScheduler) ResourceOffers(driver sched.SchedulerDriver, offers []*mesos.Offer) {
+	for _, offer := range offers {
+		if s.launched >= s.instances {
+			driver.DeclineOffer(offer.Id, &mesos.Filters{RefuseSeconds: proto.Float64(1000)})
+			continue
+		}
+		s.launched++
+		name := fmt.Sprintf("task-%d", s.launched)
+		driver.LaunchTasks([]*mesos.OfferID{offer.Id}, []*mesos.TaskInfo{
+			{
+				Name:    proto.String(name),
+				TaskId:  &mesos.TaskID{Value: proto.String("electron-" + name)},
+				SlaveId: offer.SlaveId,
+				Resources: []*mesos.Resource{
+					mesosutil.NewScalarResource("cpus", 1.0),
+					mesosutil.NewScalarResource("mem", 1024.0),
+				},
+			},
+		}, nil)
+	}
+}
+
+func (s *testScheduler) StatusUpdate(driver sched.SchedulerDriver, status *mesos.TaskStatus) {
+	s.states[status.GetTaskId().GetValue()] = append(s.states[status.GetTaskId().GetValue()],
+		status.GetState())
+	if status.GetState() == mesos.TaskState_TASK_FINISHED {
+		s.finished++
+		if s.finished == s.instances {
+			driver.Stop(false)
+		}
+	}
+}
+
+func TestDriver_Run(t *testing.T) {
+	cluster := &Cluster{
+		Hosts: []Host{
+			{Hostname: "host1", Class: "A", CPU: 1.0, RAM: 4096.0},
+			{Hostname: "host2", Class: "B", CPU: 1.0, RAM: 4096.0},
+		},
+		OfferInterval:  1.0,
+		DefaultRuntime: 10.0,
+	}
+	s := &testScheduler{
+		instances: 4,
+		states:    make(map[string][]mesos.TaskState),
+	}
+	driver := NewDriver(s, cluster)
+
+	status, err := driver.Run()
+	assert.NoError(t, err)
+	assert.Equal(t, mesos.Status_DRIVER_STOPPED, status)
+	assert.Equal(t, 4, s.finished)
+
+	// Every instance should have transitioned from TASK_RUNNING to TASK_FINISHED.
+	for taskID, states := range s.states {
+		assert.Equal(t, []mesos.TaskState{mesos.TaskState_TASK_RUNNING, mesos.TaskState_TASK_FINISHED},
+			states, "incorrect state transitions for "+taskID)
+	}
+
+	// Each host can only run one instance at a time. So, two waves of 10 seconds each are needed.
+	assert.True(t, driver.Now() >= 20.0, "instances finished earlier than their runtime")
+	assert.True(t, driver.Now() < 30.0, "instances finished later than their runtime")
+}
+
+func TestTaskName(t *testing.T) {
+	assert.Equal(t, "minife", taskName(&mesos.TaskInfo{Name: proto.String("minife-10")}))
+	assert.Equal(t, "mt-dgemm", taskName(&mesos.TaskInfo{Name: proto.String("mt-dgemm-1")}))
+	assert.Equal(t, "mt-dgemm", taskName(&mesos.TaskInfo{Name: proto.String("mt-dgemm")}))
+	// Task IDs of the instances launched by Elektron identify their task, even if their name does not.
+	assert.Equal(t, "mt-dgemm", taskName(&mesos.TaskInfo{
+		Name:   proto.String("dgemm-2"),
+		TaskId: &mesos.TaskID{Value: proto.String("electron-k5x2-mt-dgemm-2-1")},
+	}))
+}
@@ -0,0 +1,173 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// FileStore is a Store that keeps the journal in a local file, with one JSON encoded entry per line.
+type FileStore struct {
+	path string
+
+	mutex   sync.Mutex
+	file    *os.File
+	entries int
+}
+
+// NewFileStore opens the journal in the given file, creating the file if it does not exist.
+// An entry that was only partially written (for example, because of a crash) is discarded.
+func NewFileStore(path string) (*FileStore, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "Failed to read journal")
+	}
+
+	// Discarding the trailing partial entry, if any.
+	complete := bytes.LastIndexByte(data, '\n') + 1
+	if complete < len(data) {
+		if err := os.Truncate(path, int64(complete)); err != nil {
+			return nil, errors.Wrap(err, "Failed to discard partially written entry")
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to open journal")
+	}
+	return &FileStore{
+		path:    path,
+		file:    file,
+		entries: bytes.Count(data[:complete], []byte{'\n'}),
+	}, nil
+}
+
+func (f *FileStore) Append(entries ...Entry) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	buffer := bytes.Buffer{}
+	for _, entry := range entries {
+		encoded, err := json.Marshal(entry)
+		if err != nil {
+			return errors.Wrap(err, "Failed to marshal entry")
+		}
+		buffer.Write(encoded)
+		buffer.WriteByte('\n')
+	}
+	if _, err := f.file.Write(buffer.Bytes()); err != nil {
+		return errors.Wrap(err, "Failed to append to journal")
+	}
+	if err := f.file.Sync(); err != nil {
+		return errors.Wrap(err, "Failed to sync journal")
+	}
+	f.entries += len(entries)
+	return nil
+}
+
+func (f *FileStore) Replay(fn func(Entry) error) error {
+	f.mutex.Lock()
+	file, err := os.Open(f.path)
+	f.mutex.Unlock()
+	if err != nil {
+		return errors.Wrap(err, "Failed to open journal")
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var entry Entry
+			if err := json.Unmarshal(line, &entry); err != nil {
+				return errors.Wrap(err, "Failed to unmarshal entry")
+			}
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			// Only complete entries are replayed.
+			return nil
+		}
+	}
+}
+
+// Compact writes the snapshot to a new journal, which then atomically replaces the current journal.
+func (f *FileStore) Compact(snapshot Entry) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	encoded, err := json.Marshal(snapshot)
+	if err != nil {
+		return errors.Wrap(err, "Failed to marshal snapshot")
+	}
+
+	tmpPath := f.path + ".tmp"
+	tmpFile, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return errors.Wrap(err, "Failed to create compacted journal")
+	}
+	_, err = tmpFile.Write(append(encoded, '\n'))
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return errors.Wrap(err, "Failed to write compacted journal")
+	}
+	if err := os.Rename(tmpPath, f.path); err != nil {
+		return errors.Wrap(err, "Failed to replace journal")
+	}
+	// Making the rename durable.
+	if dir, err := os.Open(filepath.Dir(f.path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrap(err, "Failed to open compacted journal")
+	}
+	f.file.Close()
+	f.file = file
+	f.entries = 1
+	return nil
+}
+
+func (f *FileStore) Len() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.entries
+}
+
+func (f *FileStore) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.file.Close()
+}
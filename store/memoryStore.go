@@ -0,0 +1,70 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package store
+
+import (
+	"sync"
+)
+
+// MemoryStore is a Store that keeps the journal in memory.
+// The journal does not survive a restart, and so is only useful for testing.
+type MemoryStore struct {
+	mutex   sync.Mutex
+	entries []Entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (m *MemoryStore) Append(entries ...Entry) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.entries = append(m.entries, entries...)
+	return nil
+}
+
+func (m *MemoryStore) Replay(fn func(Entry) error) error {
+	m.mutex.Lock()
+	entries := append([]Entry{}, m.entries...)
+	m.mutex.Unlock()
+	for _, entry := range entries {
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) Compact(snapshot Entry) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.entries = []Entry{snapshot}
+	return nil
+}
+
+func (m *MemoryStore) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.entries)
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+// Package store provides durable storage for the state of the task queue.
+//
+// Changes to the state are appended to a journal as entries. After a crash, the state can be
+// rebuilt by replaying the journal. A journal can be compacted by replacing all its entries with
+// a single entry that contains a snapshot of the state.
+package store
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Entry of the journal.
+type Entry struct {
+	// Type of the change to the state.
+	Type string `json:"type"`
+	// Time at which the change was made.
+	Time time.Time `json:"time"`
+	// Details of the change, in a format specific to the type of the change.
+	Data json.RawMessage `json:"data"`
+}
+
+// NewEntry returns an entry of the given type, with the given details marshalled to JSON.
+func NewEntry(entryType string, data interface{}) (Entry, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Type: entryType, Time: time.Now(), Data: encoded}, nil
+}
+
+// Store journals the changes to the state of the task queue.
+type Store interface {
+	// Append entries to the journal.
+	// The entries are durable once Append returns.
+	Append(entries ...Entry) error
+	// Call fn with every entry in the journal, in the order in which the entries were appended.
+	// Replay stops at the first error returned by fn.
+	Replay(fn func(Entry) error) error
+	// Replace all the entries in the journal with the given snapshot of the state.
+	Compact(snapshot Entry) error
+	// Number of entries in the journal.
+	Len() int
+	Close() error
+}
@@ -0,0 +1,116 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func replayTypes(t *testing.T, s Store) []string {
+	types := []string{}
+	assert.NoError(t, s.Replay(func(entry Entry) error {
+		types = append(types, entry.Type)
+		return nil
+	}))
+	return types
+}
+
+func newEntry(t *testing.T, entryType string, data interface{}) Entry {
+	entry, err := NewEntry(entryType, data)
+	assert.NoError(t, err)
+	return entry
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elektron")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal")
+
+	s, err := NewFileStore(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, s.Len())
+	assert.NoError(t, s.Append(newEntry(t, "a", 1), newEntry(t, "b", 2)))
+	assert.NoError(t, s.Append(newEntry(t, "c", 3)))
+	assert.Equal(t, []string{"a", "b", "c"}, replayTypes(t, s))
+	assert.NoError(t, s.Close())
+
+	// Entries survive reopening the journal.
+	s, err = NewFileStore(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, s.Len())
+	var data []int
+	assert.NoError(t, s.Replay(func(entry Entry) error {
+		var value int
+		assert.NoError(t, json.Unmarshal(entry.Data, &value))
+		data = append(data, value)
+		return nil
+	}))
+	assert.Equal(t, []int{1, 2, 3}, data)
+
+	// Compacting the journal.
+	assert.NoError(t, s.Compact(newEntry(t, "snapshot", 6)))
+	assert.NoError(t, s.Append(newEntry(t, "d", 4)))
+	assert.Equal(t, 2, s.Len())
+	assert.Equal(t, []string{"snapshot", "d"}, replayTypes(t, s))
+	assert.NoError(t, s.Close())
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFileStore_PartialEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elektron")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal")
+
+	s, err := NewFileStore(path)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Append(newEntry(t, "a", 1)))
+	assert.NoError(t, s.Close())
+
+	// Simulating a crash while appending an entry.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	assert.NoError(t, err)
+	_, err = file.WriteString(`{"type":"b","da`)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	s, err = NewFileStore(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, s.Len())
+	assert.NoError(t, s.Append(newEntry(t, "c", 3)))
+	assert.Equal(t, []string{"a", "c"}, replayTypes(t, s))
+	assert.NoError(t, s.Close())
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	assert.NoError(t, s.Append(newEntry(t, "a", 1), newEntry(t, "b", 2)))
+	assert.Equal(t, []string{"a", "b"}, replayTypes(t, s))
+	assert.NoError(t, s.Compact(newEntry(t, "snapshot", 3)))
+	assert.Equal(t, []string{"snapshot"}, replayTypes(t, s))
+	assert.Equal(t, 1, s.Len())
+}
@@ -0,0 +1,95 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package offerUtils
+
+import (
+	"sort"
+	"strings"
+
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+)
+
+// OfferComparator compares two offers.
+// Returns a negative value if offer1 ranks higher than offer2, a positive value if offer2 ranks
+// higher than offer1, and 0 if both offers rank the same.
+type OfferComparator func(offer1, offer2 *mesos.Offer) int
+
+// OfferRanking ranks offers using comparators in order of precedence.
+// Offers that rank the same by a comparator are ranked by the next comparator.
+type OfferRanking []OfferComparator
+
+// Compare the offers using each comparator in turn.
+func (r OfferRanking) compare(offer1, offer2 *mesos.Offer) int {
+	for _, comparator := range r {
+		if c := comparator(offer1, offer2); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// Sort the offers from the highest ranked to the lowest ranked.
+// Offers that rank the same are kept in the order in which they were received.
+func (r OfferRanking) Sort(offers []*mesos.Offer) {
+	sort.SliceStable(offers, func(i, j int) bool {
+		return r.compare(offers[i], offers[j]) < 0
+	})
+}
+
+// ByDescending returns a comparator that ranks offers with a larger value higher.
+func ByDescending(value func(offer *mesos.Offer) float64) OfferComparator {
+	return func(offer1, offer2 *mesos.Offer) int {
+		value1, value2 := value(offer1), value(offer2)
+		if value1 > value2 {
+			return -1
+		} else if value1 < value2 {
+			return 1
+		}
+		return 0
+	}
+}
+
+// ByRemainingWatts ranks offers with more watts higher.
+var ByRemainingWatts = ByDescending(func(offer *mesos.Offer) float64 {
+	_, _, watts := OfferAgg(offer)
+	return watts
+})
+
+// ByCPU ranks offers with more cpus higher.
+var ByCPU = ByDescending(func(offer *mesos.Offer) float64 {
+	cpus, _, _ := OfferAgg(offer)
+	return cpus
+})
+
+// ByMem ranks offers with more memory higher.
+var ByMem = ByDescending(func(offer *mesos.Offer) float64 {
+	_, mem, _ := OfferAgg(offer)
+	return mem
+})
+
+// ByPowerClass ranks offers in the alphabetical order of the power classes of their hosts, and so
+// the hosts of power class A, which have the highest Thermal Design Power, rank the highest.
+// Offers from hosts without a power class rank the lowest.
+func ByPowerClass(offer1, offer2 *mesos.Offer) int {
+	class1, class2 := PowerClass(offer1), PowerClass(offer2)
+	if class1 == "" || class2 == "" {
+		return strings.Compare(class2, class1)
+	}
+	return strings.Compare(class1, class2)
+}
@@ -0,0 +1,75 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package offerUtils
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/mesos/mesos-go/api/v0/mesosutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func offer(host, class string, cpus, mem float64) *mesos.Offer {
+	o := &mesos.Offer{
+		Hostname: proto.String(host),
+		Resources: []*mesos.Resource{
+			mesosutil.NewScalarResource("cpus", cpus),
+			mesosutil.NewScalarResource("mem", mem),
+		},
+	}
+	if class != "" {
+		o.Attributes = []*mesos.Attribute{{
+			Name: proto.String("class"),
+			Type: mesos.Value_TEXT.Enum(),
+			Text: &mesos.Value_Text{Value: proto.String(class)},
+		}}
+	}
+	return o
+}
+
+func hostnames(offers []*mesos.Offer) []string {
+	var hosts []string
+	for _, o := range offers {
+		hosts = append(hosts, o.GetHostname())
+	}
+	return hosts
+}
+
+func TestOfferRanking_Sort(t *testing.T) {
+	offers := []*mesos.Offer{
+		offer("host1", "", 4.0, 1024.0),
+		offer("host2", "B", 8.0, 1024.0),
+		offer("host3", "A", 4.0, 2048.0),
+		offer("host4", "B", 8.0, 4096.0),
+	}
+
+	OfferRanking{ByCPU}.Sort(offers)
+	// Offers that rank the same keep their order.
+	assert.Equal(t, []string{"host2", "host4", "host1", "host3"}, hostnames(offers))
+
+	// Ties are broken by the next comparator.
+	OfferRanking{ByCPU, ByMem}.Sort(offers)
+	assert.Equal(t, []string{"host4", "host2", "host3", "host1"}, hostnames(offers))
+
+	// Offers from hosts without a power class rank the lowest.
+	OfferRanking{ByPowerClass}.Sort(offers)
+	assert.Equal(t, []string{"host3", "host4", "host2", "host1"}, hostnames(offers))
+}
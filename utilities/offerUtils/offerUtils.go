@@ -56,26 +56,6 @@ func PowerClass(offer *mesos.Offer) string {
 	return powerClass
 }
 
-// Implements the sort.Sort interface to sort Offers based on CPU.
-// TODO: Have a generic sorter that sorts based on a defined requirement (CPU, RAM, DISK or Watts).
-type OffersSorter []*mesos.Offer
-
-func (offersSorter OffersSorter) Len() int {
-	return len(offersSorter)
-}
-
-func (offersSorter OffersSorter) Swap(i, j int) {
-	offersSorter[i], offersSorter[j] = offersSorter[j], offersSorter[i]
-}
-
-func (offersSorter OffersSorter) Less(i, j int) bool {
-	// Getting CPU resource availability of offersSorter[i].
-	cpu1, _, _ := OfferAgg(offersSorter[i])
-	// Getting CPU resource availability of offersSorter[j].
-	cpu2, _, _ := OfferAgg(offersSorter[j])
-	return cpu1 <= cpu2
-}
-
 // Is there a mismatch between the task's host requirement and the host corresponding to the offer.
 func HostMismatch(offerHost string, taskHost string) bool {
 	if taskHost != "" && !strings.HasPrefix(offerHost, taskHost) {
@@ -109,3 +89,14 @@ func UpdateEnvironment(offer *mesos.Offer) {
 		}
 	}
 }
+
+// Remove the host from the set of Hosts and from its power class, as it is no longer a part of the cluster.
+func RemoveFromEnvironment(host string) {
+	delete(constants.Hosts, host)
+	for class, hosts := range constants.PowerClasses {
+		delete(hosts, host)
+		if len(hosts) == 0 {
+			delete(constants.PowerClasses, class)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package offerUtils
+
+import (
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/mesos/mesos-go/api/v0/mesosutil"
+)
+
+// Demand is the set of resources required by a task instance.
+type Demand struct {
+	// Amount of each scalar resource, keyed by name (for example, cpus, mem or gpus).
+	Scalars map[string]float64
+	// Number of values of each range resource, keyed by name (for example, ports).
+	Ranges map[string]int
+}
+
+// Allocation keeps track of the resources of an offer that are left after placing task instances on it.
+type Allocation struct {
+	scalars map[string]float64
+	ranges  map[string][]*mesos.Value_Range
+}
+
+// NewAllocation returns an allocation in which all the resources of the offer are left.
+func NewAllocation(offer *mesos.Offer) *Allocation {
+	a := &Allocation{
+		scalars: make(map[string]float64),
+		ranges:  make(map[string][]*mesos.Value_Range),
+	}
+	for _, resource := range offer.GetResources() {
+		switch resource.GetType() {
+		case mesos.Value_SCALAR:
+			a.scalars[resource.GetName()] += resource.GetScalar().GetValue()
+		case mesos.Value_RANGES:
+			for _, r := range resource.GetRanges().GetRange() {
+				a.ranges[resource.GetName()] = append(a.ranges[resource.GetName()],
+					&mesos.Value_Range{Begin: proto.Uint64(r.GetBegin()), End: proto.Uint64(r.GetEnd())})
+			}
+		}
+	}
+	return a
+}
+
+// Scalar returns the amount of the scalar resource that is left.
+func (a *Allocation) Scalar(name string) float64 {
+	return a.scalars[name]
+}
+
+// Number of values of the range resource that are left.
+func (a *Allocation) rangeSize(name string) uint64 {
+	size := uint64(0)
+	for _, r := range a.ranges[name] {
+		size += r.GetEnd() - r.GetBegin() + 1
+	}
+	return size
+}
+
+// Fits returns whether the resources that are left can satisfy the demand.
+func (a *Allocation) Fits(demand Demand) bool {
+	for name, amount := range demand.Scalars {
+		if amount > 0.0 && a.scalars[name] < amount {
+			return false
+		}
+	}
+	for name, count := range demand.Ranges {
+		if count > 0 && a.rangeSize(name) < uint64(count) {
+			return false
+		}
+	}
+	return true
+}
+
+// Allocate takes the resources of the demand from the resources that are left, and returns the
+// resources allocated to the task instance. The lowest values of every range resource are allocated.
+// The demand needs to fit.
+func (a *Allocation) Allocate(demand Demand) []*mesos.Resource {
+	var resources []*mesos.Resource
+	for _, name := range scalarNames(demand.Scalars) {
+		amount := demand.Scalars[name]
+		if amount <= 0.0 {
+			continue
+		}
+		a.scalars[name] -= amount
+		resources = append(resources, mesosutil.NewScalarResource(name, amount))
+	}
+
+	rangeNames := make([]string, 0, len(demand.Ranges))
+	for name := range demand.Ranges {
+		rangeNames = append(rangeNames, name)
+	}
+	sort.Strings(rangeNames)
+	for _, name := range rangeNames {
+		count := uint64(demand.Ranges[name])
+		if count == 0 {
+			continue
+		}
+		var allocated []*mesos.Value_Range
+		left := a.ranges[name]
+		for count > 0 && len(left) > 0 {
+			r := left[0]
+			size := r.GetEnd() - r.GetBegin() + 1
+			if size <= count {
+				allocated = append(allocated, r)
+				left = left[1:]
+				count -= size
+				continue
+			}
+			allocated = append(allocated, mesosutil.NewValueRange(r.GetBegin(), r.GetBegin()+count-1))
+			left[0] = mesosutil.NewValueRange(r.GetBegin()+count, r.GetEnd())
+			count = 0
+		}
+		a.ranges[name] = left
+		resources = append(resources, mesosutil.NewRangesResource(name, allocated))
+	}
+	return resources
+}
+
+// Names of the scalar resources, with cpus, mem and watts listed first.
+func scalarNames(scalars map[string]float64) []string {
+	order := map[string]int{"cpus": 0, "mem": 1, "watts": 2}
+	names := make([]string, 0, len(scalars))
+	for name := range scalars {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		rank1, ok1 := order[names[i]]
+		rank2, ok2 := order[names[j]]
+		if ok1 && ok2 {
+			return rank1 < rank2
+		} else if ok1 || ok2 {
+			return ok1
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
@@ -0,0 +1,75 @@
+// Copyright (C) 2018 spdfg
+//
+// This file is part of Elektron.
+//
+// Elektron is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elektron is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elektron.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package offerUtils
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mesos "github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/mesos/mesos-go/api/v0/mesosutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllocation(t *testing.T) {
+	o := &mesos.Offer{
+		Hostname: proto.String("host1"),
+		Resources: []*mesos.Resource{
+			mesosutil.NewScalarResource("cpus", 8.0),
+			mesosutil.NewScalarResource("mem", 4096.0),
+			mesosutil.NewScalarResource("gpus", 2.0),
+			mesosutil.NewRangesResource("ports", []*mesos.Value_Range{
+				mesosutil.NewValueRange(31000, 31001),
+				mesosutil.NewValueRange(31005, 31010),
+			}),
+		},
+	}
+	allocation := NewAllocation(o)
+	demand := Demand{
+		Scalars: map[string]float64{"cpus": 2.0, "mem": 1024.0, "gpus": 1.0},
+		Ranges:  map[string]int{"ports": 3},
+	}
+	assert.True(t, allocation.Fits(demand))
+
+	resources := allocation.Allocate(demand)
+	assert.Equal(t, []*mesos.Resource{
+		mesosutil.NewScalarResource("cpus", 2.0),
+		mesosutil.NewScalarResource("mem", 1024.0),
+		mesosutil.NewScalarResource("gpus", 1.0),
+		mesosutil.NewRangesResource("ports", []*mesos.Value_Range{
+			mesosutil.NewValueRange(31000, 31001),
+			mesosutil.NewValueRange(31005, 31005),
+		}),
+	}, resources)
+	assert.Equal(t, 6.0, allocation.Scalar("cpus"))
+	// The resources of the offer are left untouched.
+	assert.Len(t, o.Resources[3].GetRanges().GetRange(), 2)
+
+	// Only one gpu is left.
+	assert.True(t, allocation.Fits(demand))
+	allocation.Allocate(demand)
+	assert.False(t, allocation.Fits(demand))
+
+	// Only 2 ports are left.
+	assert.False(t, allocation.Fits(Demand{Ranges: map[string]int{"ports": 3}}))
+	assert.True(t, allocation.Fits(Demand{Ranges: map[string]int{"ports": 2}}))
+	// Resources that are not offered.
+	assert.False(t, allocation.Fits(Demand{Scalars: map[string]float64{"disk": 1.0}}))
+	assert.True(t, allocation.Fits(Demand{Scalars: map[string]float64{"disk": 0.0}}))
+}
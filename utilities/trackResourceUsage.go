@@ -29,6 +29,8 @@ import (
 
 type TrackResourceUsage struct {
 	perHostResourceAvailability map[string]*ResourceCount
+	// Resources allocated to the running tasks of each owner.
+	perOwnerAllocation map[string]*def.TaskResources
 	sync.Mutex
 }
 
@@ -44,6 +46,10 @@ type ResourceCount struct {
 	UnusedCPU   float64
 	UnusedRAM   float64
 	UnusedWatts float64
+
+	// Whether the resources offered by the agent have been recorded.
+	// Agents on which recovered tasks are running are tracked before their first resource offer.
+	offersRecorded bool
 }
 
 // Increment unused resources.
@@ -72,6 +78,28 @@ func getTRUInstance() *TrackResourceUsage {
 func newResourceUsageTracker() *TrackResourceUsage {
 	return &TrackResourceUsage{
 		perHostResourceAvailability: make(map[string]*ResourceCount),
+		perOwnerAllocation:          make(map[string]*def.TaskResources),
+	}
+}
+
+// Allocate resources to the owner of a task.
+func (tru *TrackResourceUsage) allocate(tr def.TaskResources) {
+	allocation, ok := tru.perOwnerAllocation[tr.Owner]
+	if !ok {
+		allocation = &def.TaskResources{Owner: tr.Owner}
+		tru.perOwnerAllocation[tr.Owner] = allocation
+	}
+	allocation.CPU += tr.CPU
+	allocation.Ram += tr.Ram
+	allocation.Watts += tr.Watts
+}
+
+// Release the resources allocated to the owner of a task.
+func (tru *TrackResourceUsage) release(tr def.TaskResources) {
+	if allocation, ok := tru.perOwnerAllocation[tr.Owner]; ok {
+		allocation.CPU -= tr.CPU
+		allocation.Ram -= tr.Ram
+		allocation.Watts -= tr.Watts
 	}
 }
 
@@ -82,7 +110,7 @@ func RecordTotalResourceAvailability(offers []*mesos.Offer) {
 	defer tru.Unlock()
 	for _, offer := range offers {
 		// If first offer received from Mesos Agent.
-		if _, ok := tru.perHostResourceAvailability[*offer.SlaveId.Value]; !ok {
+		if resCount, ok := tru.perHostResourceAvailability[*offer.SlaveId.Value]; !ok {
 			cpu, mem, watts := offerUtils.OfferAgg(offer)
 			tru.perHostResourceAvailability[*offer.SlaveId.Value] = &ResourceCount{
 				TotalCPU:   cpu,
@@ -93,11 +121,29 @@ func RecordTotalResourceAvailability(offers []*mesos.Offer) {
 				UnusedCPU:   cpu,
 				UnusedRAM:   mem,
 				UnusedWatts: watts,
+
+				offersRecorded: true,
 			}
+		} else if !resCount.offersRecorded {
+			// Resources used by recovered tasks have already been recorded.
+			cpu, mem, watts := offerUtils.OfferAgg(offer)
+			resCount.TotalCPU += cpu
+			resCount.TotalRAM += mem
+			resCount.TotalWatts += watts
+			resCount.IncrUnusedResources(def.TaskResources{CPU: cpu, Ram: mem, Watts: watts})
+			resCount.offersRecorded = true
 		}
 	}
 }
 
+// Stop tracking the resource availability of an agent, as it is no longer a part of the cluster.
+func RemoveResourceAvailability(slaveID string) {
+	tru := getTRUInstance()
+	tru.Lock()
+	defer tru.Unlock()
+	delete(tru.perHostResourceAvailability, slaveID)
+}
+
 // Resource availability update scenarios.
 var resourceAvailabilityUpdateScenario = map[string]func(mesos.TaskID, mesos.SlaveID) error{
 	"ON_TASK_TERMINAL_STATE": func(taskID mesos.TaskID, slaveID mesos.SlaveID) error {
@@ -107,6 +153,8 @@ var resourceAvailabilityUpdateScenario = map[string]func(mesos.TaskID, mesos.Sla
 		if taskResources, err := def.GetResourceRequirement(*taskID.Value); err != nil {
 			return err
 		} else {
+			// Resources are released even if the agent is no longer a part of the cluster.
+			tru.release(taskResources)
 			// Checking if first resource offer already recorded for slaveID.
 			if resCount, ok := tru.perHostResourceAvailability[*slaveID.Value]; ok {
 				resCount.IncrUnusedResources(taskResources)
@@ -125,6 +173,7 @@ var resourceAvailabilityUpdateScenario = map[string]func(mesos.TaskID, mesos.Sla
 		if taskResources, err := def.GetResourceRequirement(*taskID.Value); err != nil {
 			return err
 		} else {
+			tru.allocate(taskResources)
 			// Checking if first resource offer already recorded for slaveID.
 			if resCount, ok := tru.perHostResourceAvailability[*slaveID.Value]; ok {
 				resCount.DecrUnusedResources(taskResources)
@@ -136,14 +185,33 @@ var resourceAvailabilityUpdateScenario = map[string]func(mesos.TaskID, mesos.Sla
 			return nil
 		}
 	},
+	// Tasks that were launched before the scheduler was restarted use resources that are not offered.
+	"ON_TASK_RECOVERED": func(taskID mesos.TaskID, slaveID mesos.SlaveID) error {
+		tru := getTRUInstance()
+		tru.Lock()
+		defer tru.Unlock()
+		if taskResources, err := def.GetResourceRequirement(*taskID.Value); err != nil {
+			return err
+		} else {
+			resCount, ok := tru.perHostResourceAvailability[*slaveID.Value]
+			if !ok {
+				resCount = &ResourceCount{}
+				tru.perHostResourceAvailability[*slaveID.Value] = resCount
+			}
+			resCount.TotalCPU += taskResources.CPU
+			resCount.TotalRAM += taskResources.Ram
+			resCount.TotalWatts += taskResources.Watts
+			tru.allocate(taskResources)
+			return nil
+		}
+	},
 }
 
 // Updating cluster resource availability based on the given scenario.
 func ResourceAvailabilityUpdate(scenario string, taskID mesos.TaskID, slaveID mesos.SlaveID) error {
 	if updateFunc, ok := resourceAvailabilityUpdateScenario[scenario]; ok {
 		// Applying the update function
-		updateFunc(taskID, slaveID)
-		return nil
+		return updateFunc(taskID, slaveID)
 	} else {
 		// Incorrect scenario specified.
 		return errors.New("Incorrect scenario specified for resource availability update: " + scenario)
@@ -178,3 +246,15 @@ func GetPerHostResourceAvailability() map[string]*ResourceCount {
 	defer tru.Unlock()
 	return tru.perHostResourceAvailability
 }
+
+// Retrieve the resources allocated to the running tasks of each owner.
+func GetPerOwnerAllocation() map[string]def.TaskResources {
+	tru := getTRUInstance()
+	tru.Lock()
+	defer tru.Unlock()
+	perOwnerAllocation := make(map[string]def.TaskResources)
+	for owner, allocation := range tru.perOwnerAllocation {
+		perOwnerAllocation[owner] = *allocation
+	}
+	return perOwnerAllocation
+}